@@ -4,11 +4,17 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/kishininfosec/infra-gen/infra-gen/internal/generators/ansible"
 	"github.com/kishininfosec/infra-gen/infra-gen/internal/generators/docker"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/generators/helm"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/generators/kubernetes"
 	"github.com/kishininfosec/infra-gen/infra-gen/internal/generators/terraform"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/interpolation"
 	"github.com/kishininfosec/infra-gen/infra-gen/internal/presets"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/secrets"
 	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
 	"github.com/spf13/cobra"
 )
@@ -17,8 +23,8 @@ import (
 var generateCmd = &cobra.Command{
 	Use:   "generate [target]",
 	Short: "Generate infrastructure configurations",
-	Long: `Generate infrastructure configurations for Docker Compose, Ansible, or Terraform
-based on the current project configuration. Use 'all' to generate all targets.`,
+	Long: `Generate infrastructure configurations for Docker Compose, Ansible, Terraform,
+Kubernetes, or Helm based on the current project configuration. Use 'all' to generate all targets.`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		configFile, _ := cmd.Flags().GetString("config")
@@ -29,91 +35,71 @@ based on the current project configuration. Use 'all' to generate all targets.`,
 			target = args[0]
 		}
 
-		// Load project configuration
-		presetManager := presets.NewManager()
-		config, err := presetManager.LoadProject(configFile)
-		if err != nil {
-			fmt.Printf("Error loading project config: %v\n", err)
-			os.Exit(1)
-		}
-
-		// Validate project config
-		err = presetManager.ValidateProject(config)
-		if err != nil {
-			fmt.Printf("Validation error: %v\n", err)
+		// Load project configuration, with the --env overlay and --set overrides
+		config, resolver, ok := loadGenerateProject(cmd, configFile)
+		if !ok {
 			os.Exit(1)
 		}
 
 		// Create output directory
 		if outputDir != "" {
-			err = os.MkdirAll(outputDir, 0755)
+			err := os.MkdirAll(outputDir, 0755)
 			if err != nil {
 				fmt.Printf("Error creating output directory: %v\n", err)
 				os.Exit(1)
 			}
 		}
 
-		// Generate configurations
-		generatedFiles := 0
-		targets := []types.Target{}
-
-		switch target {
-		case "all":
-			targets = []types.Target{types.TargetDocker, types.TargetAnsible, types.TargetTerraform}
-		case "docker":
-			targets = []types.Target{types.TargetDocker}
-		case "ansible":
-			targets = []types.Target{types.TargetAnsible}
-		case "terraform":
-			targets = []types.Target{types.TargetTerraform}
-		default:
+		targets, ok := generateTargets(target)
+		if !ok {
 			fmt.Printf("Unknown target: %s\n", target)
 			os.Exit(1)
 		}
 
-		for _, t := range targets {
-			var generator types.Generator
+		vaultPassword, err := vaultPassword(cmd)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
 
-			switch t {
-			case types.TargetDocker:
-				generator = docker.NewGenerator()
-			case types.TargetAnsible:
-				generator = ansible.NewGenerator()
-			case types.TargetTerraform:
-				generator = terraform.NewGenerator()
-			}
+		// Keep generated secret values from the store or an earlier run unless
+		// rotating
+		rotate, _ := cmd.Flags().GetBool("rotate")
+		store, err := generateSecretStore(cmd, configFile, targets, vaultPassword)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		secretValues, newSecrets, ok := generateSecretValues(config, store, outputDir, vaultPassword, rotate)
+		if !ok {
+			os.Exit(1)
+		}
 
-			files, err := generator.Generate(config)
-			if err != nil {
-				fmt.Printf("Error generating %s: %v\n", t, err)
-				continue
-			}
+		// Generate configurations
+		generators := newGenerators(resolver)
+		generators[types.TargetDocker] = docker.NewGenerator().WithResolver(resolver).WithSecretValues(secretValues)
+		generators[types.TargetAnsible] = ansible.NewGenerator().WithResolver(resolver).WithVault(vaultPassword, secretValues)
+		files := generateFiles(config, targets, generators)
 
-			// Write files
-			for _, file := range files {
-				filePath := filepath.Join(outputDir, file.Path)
-
-				// Create directory if needed
-				dir := filepath.Dir(filePath)
-				if dir != "." {
-					err = os.MkdirAll(dir, 0755)
-					if err != nil {
-						fmt.Printf("Error creating directory %s: %v\n", dir, err)
-						continue
-					}
-				}
-
-				err = os.WriteFile(filePath, []byte(file.Content), 0644)
-				if err != nil {
-					fmt.Printf("Error writing file %s: %v\n", filePath, err)
-					continue
-				}
-
-				fmt.Printf("Generated: %s\n", filePath)
-				generatedFiles++
+		// Scan for secrets before anything is written
+		if allowSecrets, _ := cmd.Flags().GetBool("allow-secrets"); !allowSecrets {
+			if !scanGeneratedFiles(config, resolver, store, secretValues, files) {
+				os.Exit(1)
 			}
 		}
 
+		// Write files
+		generatedFiles := writeFiles(outputDir, files, newSecrets, rotate)
+
+		// Version generated values in the store, including ones only .env had
+		if store != nil && generatedFiles > 0 {
+			storeGeneratedSecrets(store, config, secretValues)
+		}
+
+		if vaultPassword == nil && slices.Contains(targets, types.TargetAnsible) && len(ansible.VaultNames(config)) > 0 {
+			fmt.Printf("Note: no vault password, so group_vars/all/vault.yml was not written; pass --vault-password-file or set %s\n", vaultPasswordFileEnv)
+		}
+
 		if generatedFiles > 0 {
 			fmt.Printf("\nGenerated %d files for project '%s'\n", generatedFiles, config.Name)
 		} else {
@@ -122,10 +108,328 @@ based on the current project configuration. Use 'all' to generate all targets.`,
 	},
 }
 
+// loadGenerateProject loads and validates the project to generate, printing
+// what went wrong when it cannot
+func loadGenerateProject(cmd *cobra.Command, configFile string) (*types.ProjectConfig, *interpolation.Resolver, bool) {
+	presetManager := presets.NewManager()
+	config, err := loadProject(cmd, presetManager, configFile)
+	if err != nil {
+		fmt.Printf("Error loading project config: %v\n", err)
+		return nil, nil, false
+	}
+
+	// Parse variable overrides
+	resolver, err := newResolver(cmd, config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return nil, nil, false
+	}
+
+	// Validate project config
+	err = presetManager.ValidateProject(config)
+	if err != nil {
+		fmt.Printf("Validation error: %v\n", locate(err, config))
+		return nil, nil, false
+	}
+	return config, resolver, true
+}
+
+// generateTargets returns the targets generated for a generate argument, and
+// whether the argument names any
+func generateTargets(target string) ([]types.Target, bool) {
+	switch target {
+	case "all":
+		return []types.Target{types.TargetDocker, types.TargetAnsible, types.TargetTerraform, types.TargetKubernetes, types.TargetHelm}, true
+	case "docker":
+		return []types.Target{types.TargetDocker}, true
+	case "ansible":
+		return []types.Target{types.TargetAnsible}, true
+	case "terraform":
+		return []types.Target{types.TargetTerraform}, true
+	case "kubernetes":
+		return []types.Target{types.TargetKubernetes}, true
+	case "helm":
+		return []types.Target{types.TargetHelm}, true
+	default:
+		return nil, false
+	}
+}
+
+// generateSecretStore opens the secret store when one of the targets writes
+// secret values, so it is not decrypted for the others
+func generateSecretStore(cmd *cobra.Command, configFile string, targets []types.Target, vaultPassword []byte) (*secrets.Store, error) {
+	if !writesSecretValues(targets, vaultPassword) {
+		return nil, nil
+	}
+	return openSecretStore(cmd, configFile)
+}
+
+// generateSecretValues returns the values of the secrets used by the
+// project and the names of the ones generated by this run. Generated values
+// come from the store, then .env, then the vault, and are only replaced when
+// rotating; env secrets take their value from the store when it has one.
+func generateSecretValues(config *types.ProjectConfig, store *secrets.Store, outputDir string, vaultPassword []byte, rotate bool) (map[string]string, []string, bool) {
+	existing, err := secrets.ReadEnv(filepath.Join(outputDir, ".env"))
+	if err != nil {
+		fmt.Printf("Error reading secrets: %v\n", err)
+		return nil, nil, false
+	}
+
+	if store != nil {
+		for name, value := range store.Values {
+			if secret, exists := config.Secrets[name]; exists {
+				existing[secrets.EnvVar(name, secret)] = value
+			}
+		}
+	}
+
+	if vaultPassword != nil {
+		vault, err := readVault(filepath.Join(outputDir, "group_vars", "all", "vault.yml"), vaultPassword)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return nil, nil, false
+		}
+		for _, name := range secrets.Used(config) {
+			secret := config.Secrets[name]
+			value, exists := vault["vault_"+name]
+			if _, known := existing[secrets.EnvVar(name, secret)]; exists && !known {
+				existing[secrets.EnvVar(name, secret)] = value
+			}
+		}
+	}
+
+	secretValues, newSecrets, err := secrets.Values(config, existing, rotate)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return nil, nil, false
+	}
+	if store != nil {
+		for _, name := range secrets.Used(config) {
+			value, exists := store.Values[name]
+			if exists && secrets.Source(config.Secrets[name]) == secrets.Env {
+				secretValues[name] = value
+			}
+		}
+	}
+	return secretValues, newSecrets, true
+}
+
+// generateFiles generates the files of every target, printing the targets
+// that fail and leaving them out
+func generateFiles(config *types.ProjectConfig, targets []types.Target, generators map[types.Target]types.Generator) []types.GeneratedFile {
+	var files []types.GeneratedFile
+	for _, t := range targets {
+		targetFiles, err := generators[t].Generate(config)
+		if err != nil {
+			fmt.Printf("Error generating %s: %v\n", t, locate(err, config))
+			continue
+		}
+		files = append(files, targetFiles...)
+	}
+	return files
+}
+
+// scanGeneratedFiles reports whether the generated files are free of
+// secrets, printing what was found otherwise. The scanner also knows the
+// values of the store and of this run's secrets.
+func scanGeneratedFiles(config *types.ProjectConfig, resolver *interpolation.Resolver, store *secrets.Store, secretValues map[string]string, files []types.GeneratedFile) bool {
+	known := make(map[string]string)
+	if store != nil {
+		for name, value := range store.Values {
+			known[name] = value
+		}
+	}
+	for name, value := range secretValues {
+		known[name] = value
+	}
+	scanner, err := secrets.NewScanner(config, resolver, known)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return false
+	}
+
+	var findings []secrets.Finding
+	for _, file := range files {
+		findings = append(findings, scanner.Scan(file)...)
+	}
+	if len(findings) > 0 {
+		fmt.Printf("Error: generated files would contain secrets, nothing was written:\n")
+		for _, finding := range findings {
+			fmt.Printf("  %s\n", finding)
+		}
+		fmt.Printf("Move the values to secrets, or pass --allow-secrets to write them anyway\n")
+		return false
+	}
+	return true
+}
+
+// writeFiles writes the generated files to the output directory and returns
+// how many were written. The secrets generated for .env are listed after it.
+func writeFiles(outputDir string, files []types.GeneratedFile, newSecrets []string, rotate bool) int {
+	written := 0
+	for _, file := range files {
+		filePath := filepath.Join(outputDir, file.Path)
+
+		// Create directory if needed
+		dir := filepath.Dir(filePath)
+		if dir != "." {
+			err := os.MkdirAll(dir, 0755)
+			if err != nil {
+				fmt.Printf("Error creating directory %s: %v\n", dir, err)
+				continue
+			}
+		}
+
+		err := writeFile(outputDir, file)
+		if err != nil {
+			fmt.Printf("Error writing file %s: %v\n", filePath, err)
+			continue
+		}
+
+		fmt.Printf("Generated: %s\n", filePath)
+		written++
+
+		if file.Path == ".env" && len(newSecrets) > 0 {
+			verb := "Generated"
+			if rotate {
+				verb = "Rotated"
+			}
+			fmt.Printf("%s secrets: %s\n", verb, strings.Join(newSecrets, ", "))
+		}
+	}
+	return written
+}
+
+// storeGeneratedSecrets saves the values of generated secrets that the store
+// does not have yet
+func storeGeneratedSecrets(store *secrets.Store, config *types.ProjectConfig, secretValues map[string]string) {
+	changed := false
+	for _, name := range secrets.Used(config) {
+		value, exists := secretValues[name]
+		if exists && secrets.Source(config.Secrets[name]) == secrets.Generate && store.Values[name] != value {
+			store.Values[name] = value
+			changed = true
+		}
+	}
+	if changed {
+		saveStore(store)
+		fmt.Printf("Saved secrets to: %s\n", store.Path())
+	}
+}
+
+// writesSecretValues reports whether any of the targets writes the values of
+// secrets to a sensitive file: the Compose .env, or the Ansible vault when
+// there is a vault password
+func writesSecretValues(targets []types.Target, vaultPassword []byte) bool {
+	return slices.Contains(targets, types.TargetDocker) ||
+		slices.Contains(targets, types.TargetAnsible) && vaultPassword != nil
+}
+
+// writeFile writes a generated file to the output directory. Sensitive files
+// are only readable by their owner and listed in the directory's .gitignore.
+func writeFile(outputDir string, file types.GeneratedFile) error {
+	filePath := filepath.Join(outputDir, file.Path)
+	if !file.Sensitive {
+		return os.WriteFile(filePath, []byte(file.Content), 0644)
+	}
+
+	if err := os.WriteFile(filePath, []byte(file.Content), 0600); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(filePath, 0600); err != nil {
+		return err
+	}
+	return ignoreFile(outputDir, file.Path)
+}
+
+// ignoreFile adds a path to the .gitignore of a directory, creating it when
+// needed
+func ignoreFile(dir, path string) error {
+	ignorePath := filepath.Join(dir, ".gitignore")
+	entry := "/" + filepath.ToSlash(path)
+
+	content, err := os.ReadFile(ignorePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == entry || line == strings.TrimPrefix(entry, "/") {
+			return nil
+		}
+	}
+
+	if len(content) == 0 {
+		content = []byte("# Files with secrets, written by infra-gen\n")
+	} else if !strings.HasSuffix(string(content), "\n") {
+		content = append(content, '\n')
+	}
+	content = append(content, entry+"\n"...)
+	return os.WriteFile(ignorePath, content, 0644)
+}
+
+// loadProject loads the project config, merging the --env overlay when given
+func loadProject(cmd *cobra.Command, presetManager *presets.Manager, configFile string) (*types.ProjectConfig, error) {
+	environment, _ := cmd.Flags().GetString("env")
+	if environment != "" {
+		return presetManager.LoadProjectEnvironment(configFile, environment)
+	}
+	return presetManager.LoadProject(configFile)
+}
+
+// locate adds file positions to validation errors of a loaded project
+func locate(err error, config *types.ProjectConfig) error {
+	if errors, ok := err.(types.ValidationErrors); ok {
+		return errors.Locate(config.Sources)
+	}
+	return err
+}
+
+// newGenerators creates a generator for every target, sharing one resolver
+func newGenerators(resolver *interpolation.Resolver) map[types.Target]types.Generator {
+	return map[types.Target]types.Generator{
+		types.TargetDocker:     docker.NewGenerator().WithResolver(resolver),
+		types.TargetAnsible:    ansible.NewGenerator().WithResolver(resolver),
+		types.TargetTerraform:  terraform.NewGenerator().WithResolver(resolver),
+		types.TargetKubernetes: kubernetes.NewGenerator().WithResolver(resolver),
+		types.TargetHelm:       helm.NewGenerator().WithResolver(resolver),
+	}
+}
+
+// newResolver creates a variable resolver for the project with the --set overrides
+func newResolver(cmd *cobra.Command, config *types.ProjectConfig) (*interpolation.Resolver, error) {
+	values, _ := cmd.Flags().GetStringArray("set")
+	overrides, err := parseAssignments("--set", values)
+	if err != nil {
+		return nil, err
+	}
+	return interpolation.NewResolver(config.Variables, overrides), nil
+}
+
+// parseAssignments parses repeated key=value flag values
+func parseAssignments(flag string, values []string) (map[string]string, error) {
+	assignments := make(map[string]string)
+	for _, assignment := range values {
+		key, value, found := strings.Cut(assignment, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid %s %q, expected key=value", flag, assignment)
+		}
+		assignments[key] = value
+	}
+	return assignments, nil
+}
+
 func init() {
 	rootCmd.AddCommand(generateCmd)
 
 	// Flags
 	generateCmd.Flags().StringP("config", "c", "infra-gen.yml", "Project configuration file")
 	generateCmd.Flags().StringP("output", "o", "", "Output directory (default: current directory)")
+	generateCmd.Flags().String("env", "", "Environment overlay to merge (e.g. production for infra-gen.production.yml)")
+	generateCmd.Flags().StringArray("set", nil, "Override a variable as KEY=VALUE for ${KEY} references (repeatable)")
+	generateCmd.Flags().Bool("allow-secrets", false, "Write generated files even when they appear to contain secrets")
+	generateCmd.Flags().Bool("rotate", false, "Replace the values of generated secrets in .env and secrets.enc.yml with new ones")
+	generateCmd.Flags().String("key-file", "", "File holding the passphrase of secrets.enc.yml")
+	generateCmd.Flags().String("vault-password-file", "", "File holding the Ansible Vault password for group_vars/all/vault.yml")
 }
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/secrets"
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+func TestParseAssignments(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "none", values: nil, want: map[string]string{}},
+		{name: "pairs", values: []string{"api_port=9090", "node_version=22"}, want: map[string]string{"api_port": "9090", "node_version": "22"}},
+		{name: "value with equals sign", values: []string{"DSN=user=app"}, want: map[string]string{"DSN": "user=app"}},
+		{name: "empty value", values: []string{"TAG="}, want: map[string]string{"TAG": ""}},
+		{name: "later value wins", values: []string{"a=1", "a=2"}, want: map[string]string{"a": "2"}},
+		{name: "missing equals sign", values: []string{"api_port"}, wantErr: true},
+		{name: "missing key", values: []string{"=9090"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseAssignments("--param", test.values)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseAssignments() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if len(got) != len(test.want) {
+				t.Errorf("parseAssignments() = %v, want %v", got, test.want)
+			}
+			for key, value := range test.want {
+				if got[key] != value {
+					t.Errorf("%s = %q, want %q", key, got[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateTargets(t *testing.T) {
+	tests := []struct {
+		target string
+		want   []types.Target
+	}{
+		{target: "all", want: []types.Target{types.TargetDocker, types.TargetAnsible, types.TargetTerraform, types.TargetKubernetes, types.TargetHelm}},
+		{target: "docker", want: []types.Target{types.TargetDocker}},
+		{target: "helm", want: []types.Target{types.TargetHelm}},
+		{target: "compose"},
+	}
+	for _, tt := range tests {
+		got, ok := generateTargets(tt.target)
+		if ok != (tt.want != nil) || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("generateTargets(%q) = %v, %v, want %v", tt.target, got, ok, tt.want)
+		}
+	}
+}
+
+func TestGenerateSecretValues(t *testing.T) {
+	config := &types.ProjectConfig{
+		Secrets: map[string]types.SecretConfig{
+			"db_password": {Generate: &types.GenerateConfig{}},
+			"session_key": {Generate: &types.GenerateConfig{}, Env: "SESSION"},
+			"api_token":   {Env: "API_TOKEN"},
+		},
+		Services: []types.ServiceConfig{{
+			Name:    "api",
+			Enabled: true,
+			Secrets: []types.SecretRef{{Name: "db_password"}, {Name: "session_key"}, {Name: "api_token"}},
+		}},
+	}
+	outputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outputDir, ".env"), []byte("DB_PASSWORD=from-env\nSESSION=from-env\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	store := &secrets.Store{Values: map[string]string{"session_key": "from-store", "api_token": "stored-token"}}
+
+	values, generated, ok := generateSecretValues(config, store, outputDir, nil, false)
+	if !ok {
+		t.Fatal("generateSecretValues() failed")
+	}
+	want := map[string]string{"db_password": "from-env", "session_key": "from-store", "api_token": "stored-token"}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("generateSecretValues() = %v, want %v", values, want)
+	}
+	if len(generated) != 0 {
+		t.Errorf("generated = %v, want none", generated)
+	}
+
+	values, generated, ok = generateSecretValues(config, store, outputDir, nil, true)
+	if !ok {
+		t.Fatal("generateSecretValues() failed")
+	}
+	if values["db_password"] == "from-env" || values["session_key"] == "from-store" || len(generated) != 2 {
+		t.Errorf("rotated values = %v, generated = %v, want both generated secrets replaced", values, generated)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
-	"github.com/kishininfosec/infra-gen/infra-gen/internal/presets"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/secrets"
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
 	"github.com/spf13/cobra"
 )
 
@@ -14,33 +16,111 @@ var initCmd = &cobra.Command{
 	Use:   "init [preset]",
 	Short: "Initialize a new project from a preset",
 	Long: `Initialize a new project infrastructure configuration using a preset template.
-Available presets include web-app, microservice, database, and more.`,
-	Args: cobra.ExactArgs(1),
+Available presets include web-app, microservice, database, and more.
+
+Run without a preset on a terminal to start a guided wizard, or pass --answers
+with a YAML answers file to make the same choices non-interactively.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		presetID := args[0]
-		projectName, _ := cmd.Flags().GetString("name")
-		environment, _ := cmd.Flags().GetString("environment")
 		outputDir, _ := cmd.Flags().GetString("output")
+		answersFile, _ := cmd.Flags().GetString("answers")
 
-		if projectName == "" {
-			fmt.Println("Error: --name is required")
-			os.Exit(1)
+		// Collect answers from flags, optionally on top of an answers file
+		answers := &initAnswers{}
+		if answersFile != "" {
+			loaded, err := loadAnswers(answersFile)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			answers = loaded
 		}
 
-		// Create preset manager
-		presetManager := presets.NewManager()
+		if len(args) > 0 {
+			answers.Preset = args[0]
+		}
+		if cmd.Flags().Changed("name") || answers.Name == "" {
+			answers.Name, _ = cmd.Flags().GetString("name")
+		}
+		if cmd.Flags().Changed("environment") || answers.Environment == "" {
+			answers.Environment, _ = cmd.Flags().GetString("environment")
+		}
+		if cmd.Flags().Changed("with") {
+			answers.Mixins, _ = cmd.Flags().GetStringSlice("with")
+		}
+		if cmd.Flags().Changed("include") {
+			answers.Include, _ = cmd.Flags().GetStringSlice("include")
+		}
+		if cmd.Flags().Changed("exclude") {
+			answers.Exclude, _ = cmd.Flags().GetStringSlice("exclude")
+		}
 
-		// Check if preset exists
-		preset, err := presetManager.GetPreset(presetID)
+		// Parse preset parameters
+		paramFlags, _ := cmd.Flags().GetStringArray("param")
+		params, err := parseAssignments("--param", paramFlags)
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
+		for key, value := range params {
+			if answers.Params == nil {
+				answers.Params = make(map[string]string)
+			}
+			answers.Params[key] = value
+		}
+
+		// Create preset manager
+		presetManager := newPresetManager(cmd)
+
+		var config *types.ProjectConfig
+
+		if answers.Preset == "" {
+			// Guided wizard when no preset is given on a terminal
+			if answersFile != "" || !isTerminal(os.Stdin) {
+				fmt.Println("Error: a preset is required (run on a terminal for the wizard, or pass --answers)")
+				os.Exit(1)
+			}
 
-		// Create project from preset
-		config, err := presetManager.CreateProjectFromPreset(presetID, projectName, environment)
+			answers, config, err = runWizard(presetManager, *answers)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			if config == nil {
+				fmt.Println("Aborted, nothing was written")
+				return
+			}
+		} else {
+			if answers.Name == "" {
+				fmt.Println("Error: --name is required")
+				os.Exit(1)
+			}
+
+			opts := answers.options()
+
+			// Offer a checklist of optional services when running interactively
+			if len(opts.Include) == 0 && answersFile == "" && isTerminal(os.Stdin) {
+				opts.SelectOptional = func(optional []types.PresetService) ([]string, error) {
+					var names, descriptions []string
+					for _, service := range optional {
+						names = append(names, service.Name)
+						descriptions = append(descriptions, service.Description)
+					}
+					return newPrompter().checklist("Optional services:", names, descriptions)
+				}
+			}
+
+			// Create project from preset
+			config, err = presetManager.CreateProjectFromPreset(answers.Preset, opts)
+			if err != nil {
+				fmt.Printf("Error creating project: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		preset, err := presetManager.GetPreset(answers.Preset)
 		if err != nil {
-			fmt.Printf("Error creating project: %v\n", err)
+			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
 
@@ -53,6 +133,9 @@ Available presets include web-app, microservice, database, and more.`,
 			}
 		}
 
+		// Turn placeholder passwords into generated secrets
+		adopted := secrets.Adopt(config)
+
 		// Save project config
 		configFile := filepath.Join(outputDir, "infra-gen.yml")
 		err = presetManager.SaveProject(config, configFile)
@@ -61,14 +144,22 @@ Available presets include web-app, microservice, database, and more.`,
 			os.Exit(1)
 		}
 
-		fmt.Printf("Project '%s' initialized successfully!\n", projectName)
+		fmt.Printf("Project '%s' initialized successfully!\n", config.Name)
 		fmt.Printf("Configuration saved to: %s\n", configFile)
 		fmt.Printf("Preset: %s - %s\n", preset.Name, preset.Description)
+		if len(answers.Mixins) > 0 {
+			fmt.Printf("Mixins: %s\n", strings.Join(answers.Mixins, ", "))
+		}
 		fmt.Printf("Services: %d\n", len(config.Services))
+		if len(adopted) > 0 {
+			fmt.Printf("Generated secrets: %s (values are created by generate)\n", strings.Join(adopted, ", "))
+		}
 		fmt.Printf("\nNext steps:\n")
 		fmt.Printf("  infra-gen generate docker\n")
 		fmt.Printf("  infra-gen generate ansible\n")
 		fmt.Printf("  infra-gen generate terraform\n")
+		fmt.Printf("  infra-gen generate kubernetes\n")
+		fmt.Printf("  infra-gen generate helm\n")
 	},
 }
 
@@ -76,7 +167,12 @@ func init() {
 	rootCmd.AddCommand(initCmd)
 
 	// Flags
-	initCmd.Flags().StringP("name", "n", "", "Project name (required)")
+	initCmd.Flags().StringP("name", "n", "", "Project name (required unless using the wizard)")
 	initCmd.Flags().StringP("environment", "e", "development", "Environment (development, staging, production)")
 	initCmd.Flags().StringP("output", "o", "", "Output directory (default: current directory)")
+	initCmd.Flags().StringArray("param", nil, "Preset parameter as key=value (repeatable)")
+	initCmd.Flags().StringSlice("include", nil, "Optional preset services to include (e.g. mysql,mongodb)")
+	initCmd.Flags().StringSlice("exclude", nil, "Preset services to leave out (e.g. frontend)")
+	initCmd.Flags().StringSlice("with", nil, "Add-on mixins to apply, in order (e.g. redis-cache,prometheus-monitoring)")
+	initCmd.Flags().String("answers", "", "YAML answers file with the wizard choices (preset, name, environment, mixins, include, exclude, params, variables)")
 }
@@ -14,8 +14,9 @@ var listCmd = &cobra.Command{
 	Use:   "list [type]",
 	Short: "List available presets and project information",
 	Long: `List available project presets, categories, or current project information.
-Use 'presets' to see all available presets, 'categories' to see preset categories,
-or 'project' to see current project details.`,
+Use 'presets' to see all available presets, 'mixins' to see add-ons that can be
+applied with 'init --with', 'categories' to see preset categories, or 'project' to
+see current project details.`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		listType := "presets"
@@ -23,18 +24,20 @@ or 'project' to see current project details.`,
 			listType = args[0]
 		}
 
-		presetManager := presets.NewManager()
+		presetManager := newPresetManager(cmd)
 
 		switch listType {
 		case "presets":
 			listPresets(presetManager)
+		case "mixins":
+			listMixins(presetManager)
 		case "categories":
 			listCategories(presetManager)
 		case "project":
 			listProject(cmd)
 		default:
 			fmt.Printf("Unknown list type: %s\n", listType)
-			fmt.Println("Available types: presets, categories, project")
+			fmt.Println("Available types: presets, mixins, categories, project")
 		}
 	},
 }
@@ -62,12 +65,53 @@ func listPresets(presetManager *presets.Manager) {
 			if len(preset.Tags) > 0 {
 				fmt.Printf("      Tags: %s\n", strings.Join(preset.Tags, ", "))
 			}
+			for _, param := range preset.Parameters {
+				detail := string(param.Type)
+				if param.Type == types.ParameterTypeEnum {
+					detail = strings.Join(param.Values, "|")
+				}
+				if param.Required {
+					detail += ", required"
+				} else {
+					detail += ", default " + param.Default
+				}
+				fmt.Printf("      Param: %s (%s)\n", param.Name, detail)
+			}
+			if preset.Source != "embedded" {
+				fmt.Printf("      Source: %s\n", preset.Source)
+			}
 		}
 	}
 
 	fmt.Printf("\nTotal: %d presets across %d categories\n", len(presets), len(categories))
 }
 
+func listMixins(presetManager *presets.Manager) {
+	mixins := presetManager.ListMixins()
+
+	if len(mixins) == 0 {
+		fmt.Println("No mixins available")
+		return
+	}
+
+	fmt.Println("Available Mixins:")
+	fmt.Println(strings.Repeat("=", 50))
+
+	for _, mixin := range mixins {
+		var services []string
+		for _, service := range mixin.Services {
+			services = append(services, service.Name)
+		}
+		fmt.Printf("  %-22s - %s\n", mixin.ID, mixin.Description)
+		fmt.Printf("      Services: %s\n", strings.Join(services, ", "))
+		if mixin.Source != "embedded" {
+			fmt.Printf("      Source: %s\n", mixin.Source)
+		}
+	}
+
+	fmt.Printf("\nTotal: %d mixins\n", len(mixins))
+}
+
 func listCategories(presetManager *presets.Manager) {
 	presets := presetManager.ListPresets()
 
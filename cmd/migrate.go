@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/migrations"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade a project configuration to the current schema version",
+	Long: `Rewrite infra-gen.yml in place so it uses the current schema_version,
+applying every registered migration between its version and this release.
+Comments and key order are kept. Use --dry-run to print the diff instead.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		configFile, _ := cmd.Flags().GetString("config")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			fmt.Printf("Error reading project config: %v\n", err)
+			os.Exit(1)
+		}
+
+		var root yaml.Node
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			fmt.Printf("Error parsing project config: %v\n", err)
+			os.Exit(1)
+		}
+		if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+			fmt.Printf("Error: %s is not a project configuration\n", configFile)
+			os.Exit(1)
+		}
+
+		from, err := migrations.Version(root.Content[0])
+		if err != nil {
+			fmt.Printf("Error: %s: %v\n", configFile, err)
+			os.Exit(1)
+		}
+
+		applied, err := migrations.Upgrade(root.Content[0], configFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(applied) == 0 {
+			fmt.Printf("%s is already at schema_version %d\n", configFile, from)
+			return
+		}
+
+		migrated, err := migrations.Encode(&root, migrations.DetectIndent(data))
+		if err != nil {
+			fmt.Printf("Error rendering migrated config: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Migrating %s from schema_version %d to %d:\n", configFile, from, migrations.CurrentVersion)
+		for _, migration := range applied {
+			fmt.Printf("  %d -> %d: %s\n", migration.From, migration.From+1, migration.Description)
+		}
+
+		if dryRun {
+			fmt.Println()
+			fmt.Print(migrations.Diff(configFile, configFile+" (migrated)", string(data), string(migrated)))
+			return
+		}
+
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(configFile); err == nil {
+			mode = info.Mode().Perm()
+		}
+		if err := os.WriteFile(configFile, migrated, mode); err != nil {
+			fmt.Printf("Error writing migrated config: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Configuration saved to: %s\n", configFile)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+
+	// Flags
+	migrateCmd.Flags().StringP("config", "c", "infra-gen.yml", "Project configuration file")
+	migrateCmd.Flags().Bool("dry-run", false, "Print the changes as a diff without writing the file")
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// isTerminal reports whether the file is an interactive terminal.
+// /dev/null is a character device too, so it is ruled out explicitly.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+
+	if null, err := os.Stat(os.DevNull); err == nil && os.SameFile(info, null) {
+		return false
+	}
+	return true
+}
+
+// prompter asks questions on an interactive terminal
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newPrompter() *prompter {
+	return &prompter{
+		in:  bufio.NewReader(os.Stdin),
+		out: os.Stdout,
+	}
+}
+
+// ask prints a question and returns the trimmed answer, or def when blank
+func (p *prompter) ask(question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+
+	line, err := p.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// askSecret asks a question without echoing the answer when stdin is a
+// terminal. It falls back to visible input when echo cannot be disabled.
+func (p *prompter) askSecret(question string) (string, error) {
+	if isTerminal(os.Stdin) && stty("-echo") == nil {
+		defer func() {
+			stty("echo")
+			fmt.Fprintln(p.out)
+		}()
+	}
+
+	return p.ask(question, "")
+}
+
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+// checklist lets the user pick any number of options by number and returns
+// the chosen options in list order
+func (p *prompter) checklist(title string, options, descriptions []string) ([]string, error) {
+	fmt.Fprintf(p.out, "%s\n", title)
+	for i, option := range options {
+		fmt.Fprintf(p.out, "  [%d] %-15s %s\n", i+1, option, descriptions[i])
+	}
+
+	for {
+		answer, err := p.ask("Select (comma-separated numbers, 'all', or blank for none)", "")
+		if err != nil {
+			return nil, err
+		}
+
+		if answer == "" {
+			return nil, nil
+		}
+		if answer == "all" {
+			return append([]string{}, options...), nil
+		}
+
+		chosen := make(map[int]bool)
+		valid := true
+		for _, field := range strings.Split(answer, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || n < 1 || n > len(options) {
+				fmt.Fprintf(p.out, "Invalid selection %q, enter numbers between 1 and %d\n", strings.TrimSpace(field), len(options))
+				valid = false
+				break
+			}
+			chosen[n-1] = true
+		}
+		if !valid {
+			continue
+		}
+
+		var selected []string
+		for i, option := range options {
+			if chosen[i] {
+				selected = append(selected, option)
+			}
+		}
+		return selected, nil
+	}
+}
@@ -5,8 +5,10 @@ Copyright © 2026 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"fmt"
 	"os"
 
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/presets"
 	"github.com/spf13/cobra"
 )
 
@@ -30,12 +32,31 @@ func Execute() {
 	}
 }
 
+// newPresetManager creates a preset manager with custom presets loaded from
+// --preset-dir, INFRA_GEN_PRESET_PATH and the user config directory
+func newPresetManager(cmd *cobra.Command) *presets.Manager {
+	presetDirs, _ := cmd.Flags().GetStringSlice("preset-dir")
+
+	presetManager := presets.NewManager()
+	if err := presetManager.LoadCustomPresets(presetDirs); err != nil {
+		fmt.Printf("Error loading custom presets: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, warning := range presetManager.Warnings() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	return presetManager
+}
+
 func init() {
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.infra-gen.yaml)")
+	rootCmd.PersistentFlags().StringSlice("preset-dir", nil, "Additional preset directory (repeatable, takes precedence over INFRA_GEN_PRESET_PATH)")
 
 
 }
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/schema"
+	"github.com/spf13/cobra"
+)
+
+// schemaCmd represents the schema command
+var schemaCmd = &cobra.Command{
+	Use:   "schema [project|preset]",
+	Short: "Print the JSON Schema of infra-gen files",
+	Long: `Print the JSON Schema of infra-gen.yml (project, the default) or of preset
+and mixin definition files (preset). Point your editor's YAML language server at
+the schema for autocompletion and inline errors. The same schema is used by
+'infra-gen validate'.`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: []string{"project", "preset"},
+	Run: func(cmd *cobra.Command, args []string) {
+		outputFile, _ := cmd.Flags().GetString("output")
+		kind := "project"
+
+		if len(args) > 0 {
+			kind = args[0]
+		}
+
+		var document *schema.Schema
+		switch kind {
+		case "project":
+			document = schema.Project()
+		case "preset":
+			document = schema.Preset()
+		default:
+			fmt.Printf("Unknown schema: %s\n", kind)
+			fmt.Println("Available schemas: project, preset")
+			os.Exit(1)
+		}
+
+		data, err := json.MarshalIndent(document, "", "  ")
+		if err != nil {
+			fmt.Printf("Error rendering schema: %v\n", err)
+			os.Exit(1)
+		}
+		data = append(data, '\n')
+
+		if outputFile == "" {
+			os.Stdout.Write(data)
+			return
+		}
+
+		if err := os.WriteFile(outputFile, data, 0644); err != nil {
+			fmt.Printf("Error writing schema: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Schema written to: %s\n", outputFile)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+
+	// Flags
+	schemaCmd.Flags().StringP("output", "o", "", "Write the schema to a file instead of stdout")
+}
@@ -0,0 +1,484 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/presets"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/secrets"
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables that supply the key of the secrets store and the
+// Ansible Vault password
+const (
+	secretsKeyFileEnv    = "INFRA_GEN_SECRETS_KEY_FILE"
+	secretsPassphraseEnv = "INFRA_GEN_SECRETS_PASSPHRASE"
+	vaultPasswordFileEnv = "ANSIBLE_VAULT_PASSWORD_FILE"
+	vaultPasswordEnv     = "INFRA_GEN_VAULT_PASSWORD"
+)
+
+// secretsCmd represents the secrets command
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage the encrypted secrets file",
+	Long: `Manage secrets.enc.yml, the encrypted file next to infra-gen.yml that holds the
+values of generate and env secrets. It is encrypted with AES-256-GCM under a key
+derived from a passphrase or key file, and safe to commit.
+
+The key is read from --key-file, the file named by INFRA_GEN_SECRETS_KEY_FILE,
+or INFRA_GEN_SECRETS_PASSPHRASE, and asked for on a terminal otherwise.`,
+}
+
+// secretsEditCmd opens the decrypted values in an editor
+var secretsEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit secret values in $EDITOR",
+	Long: `Decrypt the secret values into a private temporary file, open it in $VISUAL or
+$EDITOR (vi by default), and encrypt the result. The temporary file is
+overwritten and removed afterwards. Creates secrets.enc.yml when missing.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config, store := openSecretsCommand(cmd, true)
+
+		edited, err := editValues(config, store.Values)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, name := range sortedNames(edited) {
+			if err := checkStorable(config, name); err != nil {
+				fmt.Printf("Error: %v; nothing was saved\n", err)
+				os.Exit(1)
+			}
+		}
+
+		store.Values = edited
+		saveStore(store)
+		fmt.Printf("Saved %d secret(s) to %s\n", len(edited), store.Path())
+	},
+}
+
+// secretsSetCmd sets one value
+var secretsSetCmd = &cobra.Command{
+	Use:   "set <name> [value]",
+	Short: "Set the value of a secret",
+	Long: `Set the value of a generate or env secret. Without a value argument the value
+is read from stdin, which keeps it out of the shell history.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		config, store := openSecretsCommand(cmd, true)
+		if err := checkStorable(config, name); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var value string
+		if len(args) == 2 {
+			value = args[1]
+		} else {
+			var err error
+			if value, err = readSecretValue(name); err != nil {
+				fmt.Printf("Error reading value: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if value == "" {
+			fmt.Println("Error: empty value")
+			os.Exit(1)
+		}
+
+		store.Values[name] = value
+		saveStore(store)
+		fmt.Printf("Set %s in %s\n", name, store.Path())
+	},
+}
+
+// secretsGetCmd prints one value
+var secretsGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Print the value of a secret",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, store := openSecretsCommand(cmd, false)
+
+		value, exists := store.Values[args[0]]
+		if !exists {
+			fmt.Fprintf(os.Stderr, "Error: no value for secret '%s' in %s\n", args[0], store.Path())
+			os.Exit(1)
+		}
+		fmt.Println(value)
+	},
+}
+
+// secretsRotateCmd replaces generated values
+var secretsRotateCmd = &cobra.Command{
+	Use:   "rotate [name...]",
+	Short: "Generate new values for generate secrets",
+	Long: `Generate new random values for the named generate secrets, or for all of them,
+and save them to secrets.enc.yml. Run 'infra-gen generate' afterwards to write
+them to .env.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config, store := openSecretsCommand(cmd, true)
+
+		names := args
+		if len(names) == 0 {
+			for name, secret := range config.Secrets {
+				if secrets.Source(secret) == secrets.Generate {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+		}
+
+		for _, name := range names {
+			secret, exists := config.Secrets[name]
+			if !exists {
+				fmt.Printf("Error: unknown secret '%s'\n", name)
+				os.Exit(1)
+			}
+			if secrets.Source(secret) != secrets.Generate {
+				fmt.Printf("Error: secret '%s' is not generated; use 'infra-gen secrets set'\n", name)
+				os.Exit(1)
+			}
+			value, err := secrets.NewValue(secret.Generate)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			store.Values[name] = value
+		}
+
+		if len(names) == 0 {
+			fmt.Println("No generate secrets to rotate")
+			return
+		}
+		saveStore(store)
+		fmt.Printf("Rotated: %s\n", strings.Join(names, ", "))
+		fmt.Println("Run 'infra-gen generate' to write the new values")
+	},
+}
+
+// secretsVaultViewCmd prints a decrypted Ansible vault
+var secretsVaultViewCmd = &cobra.Command{
+	Use:   "vault-view [file]",
+	Short: "Print a decrypted Ansible Vault file",
+	Long: `Decrypt an Ansible Vault 1.1 file, group_vars/all/vault.yml by default, and
+print it. The password is read from --vault-password-file, the file named by
+ANSIBLE_VAULT_PASSWORD_FILE, or INFRA_GEN_VAULT_PASSWORD.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := filepath.Join("group_vars", "all", "vault.yml")
+		if len(args) > 0 {
+			path = args[0]
+		}
+
+		password, err := vaultPassword(cmd)
+		if err == nil && password == nil {
+			err = fmt.Errorf("no vault password: pass --vault-password-file, or set %s or %s", vaultPasswordFileEnv, vaultPasswordEnv)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		plaintext, err := secrets.DecryptVault(string(content), password)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(plaintext)
+	},
+}
+
+// openSecretsCommand loads the project and its store for a secrets
+// subcommand, exiting on errors. A missing store is created when create is
+// set.
+func openSecretsCommand(cmd *cobra.Command, create bool) (*types.ProjectConfig, *secrets.Store) {
+	configFile, _ := cmd.Flags().GetString("config")
+
+	config, err := presets.NewManager().LoadProject(configFile)
+	if err != nil {
+		fmt.Printf("Error loading project config: %v\n", err)
+		os.Exit(1)
+	}
+
+	store, err := openSecretStore(cmd, configFile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if store != nil {
+		return config, store
+	}
+
+	path := secrets.StorePath(configFile)
+	if !create {
+		fmt.Printf("Error: %s does not exist; create it with 'infra-gen secrets set' or 'infra-gen secrets edit'\n", path)
+		os.Exit(1)
+	}
+	passphrase, err := secretsKey(cmd, true)
+	if err == nil {
+		store, err = secrets.NewStore(path, passphrase)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return config, store
+}
+
+// openSecretStore opens the store next to a project file, or returns nil
+// when there is none
+func openSecretStore(cmd *cobra.Command, configFile string) (*secrets.Store, error) {
+	path := secrets.StorePath(configFile)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	passphrase, err := secretsKey(cmd, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return secrets.OpenStore(path, passphrase)
+}
+
+func saveStore(store *secrets.Store) {
+	if err := store.Save(); err != nil {
+		fmt.Printf("Error saving %s: %v\n", store.Path(), err)
+		os.Exit(1)
+	}
+}
+
+// secretsKey returns the passphrase of the secrets store: the contents of
+// --key-file or of the file named by INFRA_GEN_SECRETS_KEY_FILE, the value of
+// INFRA_GEN_SECRETS_PASSPHRASE, or an answer to a prompt on a terminal, asked
+// twice when confirm is set
+func secretsKey(cmd *cobra.Command, confirm bool) ([]byte, error) {
+	keyFile, _ := cmd.Flags().GetString("key-file")
+	if keyFile == "" {
+		keyFile = os.Getenv(secretsKeyFileEnv)
+	}
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		key := bytes.TrimRight(data, "\r\n")
+		if len(key) == 0 {
+			return nil, fmt.Errorf("key file %s is empty", keyFile)
+		}
+		return key, nil
+	}
+
+	if passphrase := os.Getenv(secretsPassphraseEnv); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+
+	if !isTerminal(os.Stdin) {
+		return nil, fmt.Errorf("no key: pass --key-file, or set %s or %s", secretsKeyFileEnv, secretsPassphraseEnv)
+	}
+	p := newPrompter()
+	passphrase, err := p.askSecret("Secrets passphrase")
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	if confirm {
+		again, err := p.askSecret("Repeat passphrase")
+		if err != nil {
+			return nil, err
+		}
+		if again != passphrase {
+			return nil, fmt.Errorf("passphrases do not match")
+		}
+	}
+	return []byte(passphrase), nil
+}
+
+// vaultPassword returns the Ansible Vault password: the contents of
+// --vault-password-file or of the file named by ANSIBLE_VAULT_PASSWORD_FILE,
+// or the value of INFRA_GEN_VAULT_PASSWORD. It returns nil when none is set.
+func vaultPassword(cmd *cobra.Command) ([]byte, error) {
+	passwordFile, _ := cmd.Flags().GetString("vault-password-file")
+	if passwordFile == "" {
+		passwordFile = os.Getenv(vaultPasswordFileEnv)
+	}
+	if passwordFile != "" {
+		data, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vault password file: %w", err)
+		}
+		password := bytes.TrimRight(data, "\r\n")
+		if len(password) == 0 {
+			return nil, fmt.Errorf("vault password file %s is empty", passwordFile)
+		}
+		return password, nil
+	}
+
+	if password := os.Getenv(vaultPasswordEnv); password != "" {
+		return []byte(password), nil
+	}
+	return nil, nil
+}
+
+// readVault decrypts a vault of string variables; a missing file has none
+func readVault(path string, password []byte) (map[string]string, error) {
+	values := make(map[string]string)
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := secrets.DecryptVault(string(content), password)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(plaintext, &values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+// checkStorable reports names that are not generate or env secrets of the
+// project; file and external secrets keep their values elsewhere
+func checkStorable(config *types.ProjectConfig, name string) error {
+	secret, exists := config.Secrets[name]
+	if !exists {
+		return fmt.Errorf("unknown secret '%s'; declare it under secrets in infra-gen.yml", name)
+	}
+	if source := secrets.Source(secret); source != secrets.Generate && source != secrets.Env {
+		return fmt.Errorf("secret '%s' is a %s secret; only generate and env secrets have stored values", name, source)
+	}
+	return nil
+}
+
+// readSecretValue reads a value without echo on a terminal, or all of stdin
+// without the trailing newline otherwise
+func readSecretValue(name string) (string, error) {
+	if isTerminal(os.Stdin) {
+		return newPrompter().askSecret("Value of " + name)
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// editValues writes values to a private temporary file, opens it in the
+// user's editor and returns the edited values
+func editValues(config *types.ProjectConfig, values map[string]string) (map[string]string, error) {
+	file, err := os.CreateTemp("", "infra-gen-secrets-*.yml")
+	if err != nil {
+		return nil, err
+	}
+	path := file.Name()
+	defer func() {
+		// Overwrite the plaintext before removing it
+		if info, err := os.Stat(path); err == nil {
+			os.WriteFile(path, make([]byte, info.Size()), 0600)
+		}
+		os.Remove(path)
+	}()
+
+	var content bytes.Buffer
+	content.WriteString("# Secret values as name: value. Saved encrypted when the editor exits.\n")
+	var declared []string
+	for _, name := range sortedSecretNames(config) {
+		if checkStorable(config, name) == nil {
+			declared = append(declared, name)
+		}
+	}
+	fmt.Fprintf(&content, "# Generate and env secrets: %s\n", strings.Join(declared, ", "))
+	if len(values) > 0 {
+		data, err := yaml.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+		content.Write(data)
+	}
+	if _, err := file.Write(content.Bytes()); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	fields := strings.Fields(editor)
+	command := exec.Command(fields[0], append(fields[1:], path)...)
+	command.Stdin, command.Stdout, command.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := command.Run(); err != nil {
+		return nil, fmt.Errorf("editor failed: %w; nothing was saved", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	edited := make(map[string]string)
+	if err := yaml.Unmarshal(data, &edited); err != nil {
+		return nil, fmt.Errorf("invalid secret values: %w; nothing was saved", err)
+	}
+	if edited == nil {
+		edited = make(map[string]string)
+	}
+	return edited, nil
+}
+
+func sortedSecretNames(config *types.ProjectConfig) []string {
+	names := make([]string, 0, len(config.Secrets))
+	for name := range config.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedNames(values map[string]string) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	rootCmd.AddCommand(secretsCmd)
+	secretsCmd.AddCommand(secretsEditCmd, secretsSetCmd, secretsGetCmd, secretsRotateCmd, secretsVaultViewCmd)
+
+	// Flags
+	secretsCmd.PersistentFlags().StringP("config", "c", "infra-gen.yml", "Project configuration file")
+	secretsCmd.PersistentFlags().String("key-file", "", "File holding the passphrase of secrets.enc.yml")
+	secretsVaultViewCmd.Flags().String("vault-password-file", "", "File holding the Ansible Vault password")
+}
@@ -1,12 +1,13 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 
-	"github.com/kishininfosec/infra-gen/infra-gen/internal/generators/ansible"
-	"github.com/kishininfosec/infra-gen/infra-gen/internal/generators/docker"
-	"github.com/kishininfosec/infra-gen/infra-gen/internal/generators/terraform"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/ports"
 	"github.com/kishininfosec/infra-gen/infra-gen/internal/presets"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/secrets"
 	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
 	"github.com/spf13/cobra"
 )
@@ -15,25 +16,43 @@ import (
 var validateCmd = &cobra.Command{
 	Use:   "validate",
 	Short: "Validate project configuration",
-	Long: `Validate the current project configuration for Docker Compose, Ansible, and Terraform
-generation. Checks for required fields, service configurations, and potential issues.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Validate the current project configuration for Docker Compose, Ansible, Terraform,
+Kubernetes, and Helm generation. Checks for required fields, service configurations, and potential issues.`,
+	// Problems are printed as they are found; the returned error only sets
+	// the exit status
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		configFile, _ := cmd.Flags().GetString("config")
 		target, _ := cmd.Flags().GetString("target")
 
 		// Load project configuration
 		presetManager := presets.NewManager()
-		config, err := presetManager.LoadProject(configFile)
+		config, err := loadProject(cmd, presetManager, configFile)
 		if err != nil {
-			fmt.Printf("❌ Error loading project config: %v\n", err)
-			return
+			fmt.Printf("❌ Error loading project config:\n")
+			printDiagnostics(err, nil)
+			return errValidationFailed
+		}
+
+		// Parse variable overrides
+		resolver, err := newResolver(cmd, config)
+		if err != nil {
+			return err
 		}
 
 		// Validate project configuration
 		err = presetManager.ValidateProject(config)
 		if err != nil {
-			fmt.Printf("❌ Project validation failed:\n%v\n", err)
-			return
+			fmt.Printf("❌ Project validation failed:\n")
+			printDiagnostics(err, config.Sources)
+			return errValidationFailed
+		}
+
+		// Host ports must not collide in any environment either
+		if environment, _ := cmd.Flags().GetString("env"); environment == "" {
+			if !checkEnvironmentPorts(presetManager, configFile, config) {
+				return errValidationFailed
+			}
 		}
 
 		fmt.Printf("Project configuration is valid\n")
@@ -41,33 +60,37 @@ generation. Checks for required fields, service configurations, and potential is
 		fmt.Printf("Services: %d\n", len(config.Services))
 
 		// Validate specific targets
-		validators := map[string]types.Generator{
-			"docker":    docker.NewGenerator(),
-			"ansible":   ansible.NewGenerator(),
-			"terraform": terraform.NewGenerator(),
+		validators := make(map[string]types.Generator)
+		for t, generator := range newGenerators(resolver) {
+			validators[string(t)] = generator
+		}
+
+		// Generated files are scanned for secrets as generate does, without
+		// the values only the secret store and .env know
+		var scanner *secrets.Scanner
+		if allowSecrets, _ := cmd.Flags().GetBool("allow-secrets"); !allowSecrets {
+			scanner, err = secrets.NewScanner(config, resolver, nil)
+			if err != nil {
+				return err
+			}
 		}
 
 		allValid := true
 		if target == "all" {
-			for name, validator := range validators {
-				if err := validator.Validate(config); err != nil {
-					fmt.Printf("%s validation failed: %v\n", name, err)
+			names := make([]string, 0, len(validators))
+			for name := range validators {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if !validateTarget(name, validators[name], config, scanner) {
 					allValid = false
-				} else {
-					fmt.Printf("%s configuration is valid\n", name)
 				}
 			}
 		} else if validator, exists := validators[target]; exists {
-			if err := validator.Validate(config); err != nil {
-				fmt.Printf("%s validation failed: %v\n", target, err)
-				allValid = false
-			} else {
-				fmt.Printf("%s configuration is valid\n", target)
-			}
+			allValid = validateTarget(target, validator, config, scanner)
 		} else {
-			fmt.Printf("Unknown target: %s\n", target)
-			fmt.Println("Available targets: all, docker, ansible, terraform")
-			return
+			return fmt.Errorf("unknown target: %s (available targets: all, docker, ansible, terraform, kubernetes, helm)", target)
 		}
 
 		if allValid {
@@ -79,9 +102,93 @@ generation. Checks for required fields, service configurations, and potential is
 		// Show warnings and recommendations
 		fmt.Println("\nRecommendations:")
 		showRecommendations(config)
+
+		if !allValid {
+			return errValidationFailed
+		}
+		return nil
 	},
 }
 
+// errValidationFailed makes validate exit non-zero once its problems have
+// been printed
+var errValidationFailed = errors.New("validation failed")
+
+// validateTarget validates the project for one target and, with a scanner,
+// checks that the files it generates hold no secrets
+func validateTarget(name string, validator types.Generator, config *types.ProjectConfig, scanner *secrets.Scanner) bool {
+	if err := validator.Validate(config); err != nil {
+		fmt.Printf("%s validation failed:\n", name)
+		printDiagnostics(err, config.Sources)
+		return false
+	}
+
+	if scanner != nil {
+		files, err := validator.Generate(config)
+		if err != nil {
+			fmt.Printf("%s generation failed:\n", name)
+			printDiagnostics(err, config.Sources)
+			return false
+		}
+		var findings []secrets.Finding
+		for _, file := range files {
+			findings = append(findings, scanner.Scan(file)...)
+		}
+		if len(findings) > 0 {
+			fmt.Printf("%s files would contain secrets:\n", name)
+			for _, finding := range findings {
+				fmt.Printf("  %s\n", finding)
+			}
+			return false
+		}
+	}
+
+	fmt.Printf("%s configuration is valid\n", name)
+	return true
+}
+
+// checkEnvironmentPorts reports host port conflicts in each environment
+// overlay of the project, returning false when there are any
+func checkEnvironmentPorts(presetManager *presets.Manager, configFile string, config *types.ProjectConfig) bool {
+	valid := true
+	for _, environment := range presets.ProjectEnvironments(configFile, config) {
+		envConfig, err := presetManager.LoadProjectEnvironment(configFile, environment)
+		if err != nil {
+			fmt.Printf("❌ Error loading environment '%s':\n", environment)
+			printDiagnostics(err, nil)
+			valid = false
+			continue
+		}
+		if conflicts := ports.Conflicts(envConfig); conflicts.HasErrors() {
+			fmt.Printf("❌ Host port conflicts in environment '%s':\n", environment)
+			printDiagnostics(conflicts, envConfig.Sources)
+			valid = false
+		}
+	}
+	return valid
+}
+
+// printDiagnostics prints validation errors compiler-style, one per line as
+// file:line:col: field: message
+func printDiagnostics(err error, sources types.SourceMap) {
+	errors, ok := err.(types.ValidationErrors)
+	if !ok {
+		fmt.Printf("  %v\n", err)
+		return
+	}
+
+	for _, e := range errors.Locate(sources) {
+		message := e.Message
+		if e.Field != "" {
+			message = e.Field + ": " + message
+		}
+		if e.Position.IsValid() {
+			message = e.Position.String() + ": " + message
+		}
+		fmt.Printf("  %s\n", message)
+	}
+}
+
 func showRecommendations(config *types.ProjectConfig) {
 	// Check for common issues
 	for _, service := range config.Services {
@@ -98,37 +205,19 @@ func showRecommendations(config *types.ProjectConfig) {
 		}
 	}
 
-	// Check for security concerns
-	for key := range config.Variables {
-		if containsSensitiveKeywords(key) {
-			fmt.Printf("  SECURITY: Variable '%s' contains sensitive data - consider using environment variables\n", key)
-		}
-	}
-
+	// Check for security concerns; secret-like variables are already
+	// refused by validation
 	for _, service := range config.Services {
-		for key := range service.Environment {
+		for _, key := range sortedNames(service.Environment) {
 			if containsSensitiveKeywords(key) {
-				fmt.Printf("  SECURITY: Service '%s' environment variable '%s' contains sensitive data\n", service.Name, key)
+				fmt.Printf("  SECURITY: Service '%s' environment variable '%s' contains sensitive data - reference a secret instead\n", service.Name, key)
 			}
 		}
 	}
 }
 
 func containsSensitiveKeywords(key string) bool {
-	key = string([]byte(key))
-	key = string([]byte(key))
-	sensitiveKeywords := []string{"password", "secret", "key", "token", "auth"}
-
-	for _, keyword := range sensitiveKeywords {
-		if len(key) >= len(keyword) {
-			for i := 0; i <= len(key)-len(keyword); i++ {
-				if key[i:i+len(keyword)] == keyword {
-					return true
-				}
-			}
-		}
-	}
-	return false
+	return secrets.Sensitive(key)
 }
 
 func init() {
@@ -136,5 +225,8 @@ func init() {
 
 	// Flags
 	validateCmd.Flags().StringP("config", "c", "infra-gen.yml", "Project configuration file")
-	validateCmd.Flags().StringP("target", "t", "all", "Target to validate (all, docker, ansible, terraform)")
+	validateCmd.Flags().StringP("target", "t", "all", "Target to validate (all, docker, ansible, terraform, kubernetes, helm)")
+	validateCmd.Flags().String("env", "", "Environment overlay to merge (e.g. production for infra-gen.production.yml)")
+	validateCmd.Flags().StringArray("set", nil, "Override a variable as KEY=VALUE for ${KEY} references (repeatable)")
+	validateCmd.Flags().Bool("allow-secrets", false, "Do not fail when generated files appear to contain secrets")
 }
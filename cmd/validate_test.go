@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/presets"
+)
+
+// runValidate runs the validate command on a project and returns its output
+func runValidate(t *testing.T, project string) (string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "infra-gen.yml")
+	if err := os.WriteFile(path, []byte(project), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+
+	rootCmd.SetErr(io.Discard)
+	rootCmd.SetArgs([]string{"validate", "-c", path})
+	err = rootCmd.Execute()
+	writer.Close()
+	return <-output, err
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		project string
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			project: "schema_version: 2\nname: app\ntype: web-app\nservices:\n  - name: api\n    type: api\n    image: node:18\n",
+		},
+		{
+			name:    "target fails",
+			project: "schema_version: 2\nname: app\ntype: web-app\nservices:\n  - name: api\n    type: api\n",
+			wantErr: true,
+		},
+		{
+			name:    "project fails",
+			project: "schema_version: 2\nname: app\ntype: web-app\nservices:\n  - name: api\n    type: api\n    image: node:18\n    depends_on: [db]\n",
+			wantErr: true,
+		},
+	}
+
+	targetLine := regexp.MustCompile(`(?m)^([a-z]+) (configuration is valid|validation failed|files would contain secrets)`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := runValidate(t, tt.project)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate error = %v, want error %v\n%s", err, tt.wantErr, output)
+			}
+
+			var targets []string
+			for _, match := range targetLine.FindAllStringSubmatch(output, -1) {
+				targets = append(targets, match[1])
+			}
+			if len(targets) > 0 {
+				want := []string{"ansible", "docker", "helm", "kubernetes", "terraform"}
+				if len(targets) != len(want) {
+					t.Fatalf("targets = %v, want %v", targets, want)
+				}
+				for i := range want {
+					if targets[i] != want[i] {
+						t.Fatalf("targets = %v, want %v", targets, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCheckEnvironmentPorts(t *testing.T) {
+	const base = `schema_version: 2
+name: shop
+type: web-app
+services:
+  - name: web
+    type: web
+    image: nginx
+    ports: [{host: 80, container: 80}]
+  - name: api
+    type: api
+    image: app:1
+    ports: [{host: 8080, container: 8080}]
+`
+	tests := []struct {
+		name    string
+		inline  string
+		overlay string
+		want    bool
+	}{
+		{name: "no environments", want: true},
+		{
+			name:    "overlay moves a port",
+			overlay: "services:\n  - name: api\n    ports: [{host: 9090, container: 8080}]\n",
+			want:    true,
+		},
+		{
+			name:    "overlay file takes a port in use",
+			overlay: "services:\n  - name: api\n    ports: [{host: 80, container: 8080}]\n",
+			want:    false,
+		},
+		{
+			name:   "environments block takes a port in use",
+			inline: "environments:\n  staging:\n    services:\n      - name: web\n        ports: [{host: 8080, container: 80}]\n",
+			want:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "infra-gen.yml")
+			if err := os.WriteFile(path, []byte(base+test.inline), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if test.overlay != "" {
+				if err := os.WriteFile(presets.OverlayPath(path, "production"), []byte(test.overlay), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			manager := presets.NewManager()
+			config, err := manager.LoadProject(path)
+			if err != nil {
+				t.Fatalf("LoadProject() error = %v", err)
+			}
+			if got := checkEnvironmentPorts(manager, path, config); got != test.want {
+				t.Errorf("checkEnvironmentPorts() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
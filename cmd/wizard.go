@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/presets"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/secrets"
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// initAnswers holds every choice made by the init wizard. The same structure
+// is read from --answers so scripted runs produce the same project.
+type initAnswers struct {
+	Preset      string            `yaml:"preset"`
+	Name        string            `yaml:"name"`
+	Environment string            `yaml:"environment,omitempty"`
+	Mixins      []string          `yaml:"mixins,omitempty"`
+	Include     []string          `yaml:"include,omitempty"`
+	Exclude     []string          `yaml:"exclude,omitempty"`
+	Params      map[string]string `yaml:"params,omitempty"`
+	Variables   map[string]string `yaml:"variables,omitempty"`
+}
+
+// loadAnswers reads an answers file, rejecting unknown keys
+func loadAnswers(path string) (*initAnswers, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read answers file: %w", err)
+	}
+	defer file.Close()
+
+	var answers initAnswers
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&answers); err != nil {
+		return nil, fmt.Errorf("failed to parse answers file: %w", err)
+	}
+
+	return &answers, nil
+}
+
+// options converts answers into preset manager options
+func (a *initAnswers) options() presets.ProjectOptions {
+	environment := a.Environment
+	if environment == "" {
+		environment = "development"
+	}
+
+	return presets.ProjectOptions{
+		Name:        a.Name,
+		Environment: environment,
+		Params:      a.Params,
+		Mixins:      a.Mixins,
+		Include:     a.Include,
+		Exclude:     a.Exclude,
+		Variables:   a.Variables,
+	}
+}
+
+// wizardParameters returns the parameters of a preset followed by those of
+// the selected mixins. They share one namespace, so a name declared twice is
+// asked once.
+func wizardParameters(presetManager *presets.Manager, preset *types.Preset, mixins []string) ([]types.PresetParameter, error) {
+	parameters := append([]types.PresetParameter{}, preset.Parameters...)
+	declared := make(map[string]bool)
+	for _, param := range parameters {
+		declared[param.Name] = true
+	}
+
+	for _, id := range mixins {
+		mixin, err := presetManager.GetPreset(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, param := range mixin.Parameters {
+			if !declared[param.Name] {
+				declared[param.Name] = true
+				parameters = append(parameters, param)
+			}
+		}
+	}
+
+	return parameters, nil
+}
+
+// runWizard asks for every init choice interactively, previews the resulting
+// configuration and returns it once confirmed. It returns nil when the user
+// declines to save.
+func runWizard(presetManager *presets.Manager, defaults initAnswers) (*initAnswers, *types.ProjectConfig, error) {
+	p := newPrompter()
+	answers := defaults
+
+	// Preset, grouped by category
+	available := presetManager.ListPresets()
+	if len(available) == 0 {
+		return nil, nil, fmt.Errorf("no presets available")
+	}
+
+	byCategory := make(map[string][]types.Preset)
+	var categories []string
+	for _, preset := range available {
+		if _, exists := byCategory[preset.Category]; !exists {
+			categories = append(categories, preset.Category)
+		}
+		byCategory[preset.Category] = append(byCategory[preset.Category], preset)
+	}
+	sort.Strings(categories)
+
+	var ordered []types.Preset
+	fmt.Println("Available presets:")
+	for _, category := range categories {
+		fmt.Printf("\n%s:\n", category)
+		for _, preset := range byCategory[category] {
+			ordered = append(ordered, preset)
+			fmt.Printf("  [%d] %-14s %s\n", len(ordered), preset.ID, preset.Description)
+		}
+	}
+	fmt.Println()
+
+	for {
+		answer, err := p.ask("Preset (number or id)", "1")
+		if err != nil {
+			return nil, nil, err
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(ordered) {
+			answers.Preset = ordered[n-1].ID
+			break
+		}
+		if _, err := presetManager.GetPreset(answer); err == nil {
+			answers.Preset = answer
+			break
+		}
+		fmt.Printf("Unknown preset %q\n", answer)
+	}
+
+	preset, err := presetManager.GetPreset(answers.Preset)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Project name and environment
+	for answers.Name == "" {
+		if answers.Name, err = p.ask("Project name", ""); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	environment := answers.Environment
+	if environment == "" {
+		environment = "development"
+	}
+	if answers.Environment, err = p.ask("Environment (development, staging, production)", environment); err != nil {
+		return nil, nil, err
+	}
+
+	// Add-on mixins
+	if mixins := presetManager.ListMixins(); len(mixins) > 0 && len(answers.Mixins) == 0 {
+		var names, descriptions []string
+		for _, mixin := range mixins {
+			names = append(names, mixin.ID)
+			descriptions = append(descriptions, mixin.Description)
+		}
+		if answers.Mixins, err = p.checklist("\nAdd-on mixins:", names, descriptions); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Parameters of the preset and the selected mixins
+	parameters, err := wizardParameters(presetManager, preset, answers.Mixins)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(parameters) > 0 {
+		fmt.Println("\nPreset parameters:")
+		if answers.Params == nil {
+			answers.Params = make(map[string]string)
+		}
+		for _, param := range parameters {
+			question := param.Name
+			if param.Description != "" {
+				question = fmt.Sprintf("%s - %s", param.Name, param.Description)
+			}
+			if param.Type == types.ParameterTypeEnum {
+				question += fmt.Sprintf(" (%s)", strings.Join(param.Values, "|"))
+			}
+
+			def := param.Default
+			if value, exists := answers.Params[param.Name]; exists {
+				def = value
+			}
+
+			value, err := p.ask(question, def)
+			if err != nil {
+				return nil, nil, err
+			}
+			if value != "" || param.Required {
+				answers.Params[param.Name] = value
+			}
+		}
+	}
+
+	// Optional services
+	if len(answers.Include) == 0 {
+		var names, descriptions []string
+		for _, service := range preset.Services {
+			if service.Optional {
+				names = append(names, service.Name)
+				descriptions = append(descriptions, service.Description)
+			}
+		}
+		if len(names) > 0 {
+			if answers.Include, err = p.checklist("\nOptional services:", names, descriptions); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	// Build once to learn the variables of the composed preset
+	config, err := presetManager.CreateProjectFromPreset(answers.Preset, answers.options())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(config.Variables) > 0 {
+		fmt.Println("\nVariables:")
+		if answers.Variables == nil {
+			answers.Variables = make(map[string]string)
+		}
+
+		var keys []string
+		for key := range config.Variables {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			// Variables may not hold secrets; secret-like ones become
+			// generated secrets below
+			if containsSensitiveKeywords(key) {
+				fmt.Printf("%s: generated secret\n", key)
+				continue
+			}
+			value, err := p.ask(key, config.Variables[key])
+			if err != nil {
+				return nil, nil, err
+			}
+			if value != config.Variables[key] {
+				answers.Variables[key] = value
+			}
+		}
+
+		if config, err = presetManager.CreateProjectFromPreset(answers.Preset, answers.options()); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Placeholder secrets get generated values at generate time
+	secrets.Adopt(config)
+
+	// Preview before saving
+	preview, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to render preview: %w", err)
+	}
+
+	fmt.Println("\nProject configuration preview:")
+	fmt.Println(strings.Repeat("=", 40))
+	fmt.Print(maskSecrets(string(preview), config))
+	fmt.Println(strings.Repeat("=", 40))
+
+	confirm, err := p.ask("Save this configuration? (y/n)", "y")
+	if err != nil {
+		return nil, nil, err
+	}
+	if !strings.EqualFold(confirm, "y") && !strings.EqualFold(confirm, "yes") {
+		return nil, nil, nil
+	}
+
+	return &answers, config, nil
+}
+
+// maskSecrets hides the values of secret-like variables in a preview
+func maskSecrets(preview string, config *types.ProjectConfig) string {
+	lines := strings.Split(preview, "\n")
+	for i, line := range lines {
+		key, value, found := strings.Cut(strings.TrimSpace(line), ":")
+		if !found || strings.TrimSpace(value) == "" {
+			continue
+		}
+		if _, isVariable := config.Variables[key]; isVariable && containsSensitiveKeywords(key) {
+			lines[i] = line[:strings.Index(line, ":")+1] + " '********'"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/presets"
+)
+
+func TestWizardParameters(t *testing.T) {
+	dir := t.TempDir()
+	mixin := `id: queue
+name: Queue
+kind: mixin
+description: Message queue
+category: Add-ons
+parameters:
+  - name: queue_image
+    type: string
+    required: true
+  - name: api_port
+    type: int
+    default: "9090"
+services:
+  - name: queue
+    type: queue
+    image: "{{ params.queue_image }}"
+`
+	if err := os.WriteFile(filepath.Join(dir, "queue.yaml"), []byte(mixin), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	presetManager := presets.NewManager()
+	if err := presetManager.LoadCustomPresets([]string{dir}); err != nil {
+		t.Fatal(err)
+	}
+	preset, err := presetManager.GetPreset("web-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parameters, err := wizardParameters(presetManager, preset, []string{"queue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, param := range parameters {
+		names = append(names, param.Name)
+	}
+	want := []string{"node_version", "api_port", "queue_image"}
+	if len(names) != len(want) {
+		t.Fatalf("parameters = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("parameters = %v, want %v", names, want)
+		}
+	}
+	if parameters[1].Default != "8080" {
+		t.Errorf("api_port default = %q, want the preset's 8080", parameters[1].Default)
+	}
+
+	if _, err := wizardParameters(presetManager, preset, []string{"missing"}); err == nil {
+		t.Error("wizardParameters() with an unknown mixin error = nil")
+	}
+}
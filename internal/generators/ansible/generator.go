@@ -2,8 +2,18 @@ package ansible
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/graph"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/health"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/interpolation"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/ports"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/scaling"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/secrets"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/sizing"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/validation"
 	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
 	"gopkg.in/yaml.v3"
 )
@@ -26,6 +36,12 @@ type AnsibleTask struct {
 	State     string                 `yaml:"state,omitempty"`
 	WithItems []interface{}          `yaml:"with_items,omitempty"`
 	Vars      map[string]interface{} `yaml:"vars,omitempty"`
+	Args      map[string]string      `yaml:"args,omitempty"`
+	Register  string                 `yaml:"register,omitempty"`
+	Until     string                 `yaml:"until,omitempty"`
+	Retries   int                    `yaml:"retries,omitempty"`
+	Delay     int                    `yaml:"delay,omitempty"`
+	NoLog     bool                   `yaml:"no_log,omitempty"`
 }
 
 // AnsibleInventory represents an Ansible inventory structure
@@ -39,7 +55,11 @@ type AnsibleInventory struct {
 }
 
 // Generator implements Ansible playbook generation
-type Generator struct{}
+type Generator struct {
+	resolver      *interpolation.Resolver
+	vaultPassword []byte
+	secretValues  map[string]string
+}
 
 // NewGenerator creates a new Ansible generator
 func NewGenerator() *Generator {
@@ -51,12 +71,38 @@ func (g *Generator) GetTarget() types.Target {
 	return types.TargetAnsible
 }
 
+// WithResolver sets the resolver used for ${VAR} references
+func (g *Generator) WithResolver(resolver *interpolation.Resolver) *Generator {
+	g.resolver = resolver
+	return g
+}
+
+// WithVault sets the password group_vars/all/vault.yml is encrypted with and
+// the values of generated secrets, keyed by secret name, stored in it.
+// Without a password no vault is written.
+func (g *Generator) WithVault(password []byte, values map[string]string) *Generator {
+	g.vaultPassword = password
+	g.secretValues = values
+	return g
+}
+
 // Generate generates Ansible files from project config
 func (g *Generator) Generate(config *types.ProjectConfig) ([]types.GeneratedFile, error) {
 	if err := g.Validate(config); err != nil {
 		return nil, err
 	}
 
+	// Render project variables as Jinja2 expressions, resolve the rest
+	config, err := interpolation.ResolverFor(g.resolver, config).Apply(config, interpolation.AnsibleFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate services in dependency order
+	if config.Services, err = graph.New(config).Order(); err != nil {
+		return nil, err
+	}
+
 	files := []types.GeneratedFile{}
 
 	// Generate main playbook
@@ -85,6 +131,33 @@ func (g *Generator) Generate(config *types.ProjectConfig) ([]types.GeneratedFile
 		Encoding: "utf-8",
 	})
 
+	// Generate group variables, and the encrypted vault of generated secrets
+	if len(config.Variables) > 0 {
+		varsContent, err := g.generateGroupVars(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate group variables: %w", err)
+		}
+		files = append(files, types.GeneratedFile{
+			Path:     "group_vars/all/vars.yml",
+			Content:  varsContent,
+			Type:     types.TargetAnsible,
+			Encoding: "utf-8",
+		})
+	}
+
+	if vault := g.vaultValues(config); len(vault) > 0 && len(g.vaultPassword) > 0 {
+		vaultContent, err := g.generateVault(vault)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate vault: %w", err)
+		}
+		files = append(files, types.GeneratedFile{
+			Path:     "group_vars/all/vault.yml",
+			Content:  vaultContent,
+			Type:     types.TargetAnsible,
+			Encoding: "utf-8",
+		})
+	}
+
 	// Generate requirements.yml if needed
 	requirementsContent := g.generateRequirements(config)
 	if requirementsContent != "" {
@@ -101,25 +174,7 @@ func (g *Generator) Generate(config *types.ProjectConfig) ([]types.GeneratedFile
 
 // Validate validates the project config for Ansible generation
 func (g *Generator) Validate(config *types.ProjectConfig) error {
-	var errors types.ValidationErrors
-
-	if config.Name == "" {
-		errors.Add("name", "project name is required", config.Name)
-	}
-
-	if len(config.Services) == 0 {
-		errors.Add("services", "at least one service is required", len(config.Services))
-	}
-
-	// Validate each service
-	for i, service := range config.Services {
-		if service.Name == "" {
-			errors.Add(fmt.Sprintf("services[%d].name", i), "service name is required", service.Name)
-		}
-		if service.Type == "" {
-			errors.Add(fmt.Sprintf("services[%d].type", i), "service type is required", service.Type)
-		}
-	}
+	errors := validation.Project(config, interpolation.ResolverFor(g.resolver, config))
 
 	if errors.HasErrors() {
 		return errors
@@ -147,7 +202,12 @@ func (g *Generator) generatePlaybook(config *types.ProjectConfig) (string, error
 			builder.WriteString("    ")
 			builder.WriteString(key)
 			builder.WriteString(": ")
-			builder.WriteString(fmt.Sprintf("%v", value))
+			if text, ok := value.(string); ok && strings.Contains(text, "{{") {
+				// Jinja2 expressions must be quoted to stay valid YAML
+				builder.WriteString(strconv.Quote(text))
+			} else {
+				builder.WriteString(fmt.Sprintf("%v", value))
+			}
 			builder.WriteString("\n")
 		}
 	}
@@ -173,6 +233,32 @@ func (g *Generator) generatePlaybook(config *types.ProjectConfig) (string, error
 				builder.WriteString(task.State)
 				builder.WriteString("\n")
 			}
+			for _, key := range sortedKeys(task.Args) {
+				builder.WriteString("        ")
+				builder.WriteString(key)
+				builder.WriteString(": ")
+				builder.WriteString(strconv.Quote(task.Args[key]))
+				builder.WriteString("\n")
+			}
+		}
+		if task.Register != "" {
+			builder.WriteString("      register: ")
+			builder.WriteString(task.Register)
+			builder.WriteString("\n")
+		}
+		if task.Until != "" {
+			builder.WriteString("      until: ")
+			builder.WriteString(task.Until)
+			builder.WriteString("\n")
+		}
+		if task.Retries > 0 {
+			builder.WriteString(fmt.Sprintf("      retries: %d\n", task.Retries))
+		}
+		if task.Delay > 0 {
+			builder.WriteString(fmt.Sprintf("      delay: %d\n", task.Delay))
+		}
+		if task.NoLog {
+			builder.WriteString("      no_log: true\n")
 		}
 	}
 
@@ -183,10 +269,7 @@ func (g *Generator) generatePlaybook(config *types.ProjectConfig) (string, error
 func (g *Generator) generateVars(config *types.ProjectConfig) map[string]interface{} {
 	vars := make(map[string]interface{})
 
-	// Add project-level variables
-	for key, value := range config.Variables {
-		vars[key] = value
-	}
+	// Project variables are group variables, see generateGroupVars
 
 	// Add service variables
 	for _, service := range config.Services {
@@ -195,11 +278,48 @@ func (g *Generator) generateVars(config *types.ProjectConfig) map[string]interfa
 		vars[fmt.Sprintf("%s_ports", prefix)] = g.extractPorts(service.Ports)
 		vars[fmt.Sprintf("%s_volumes", prefix)] = g.extractVolumes(service.Volumes)
 		vars[fmt.Sprintf("%s_enabled", prefix)] = service.Enabled
+		if scaling.Scaled(service) {
+			vars[fmt.Sprintf("%s_replicas", prefix)] = scaling.Replicas(service)
+		}
+		if size, ok := sizing.Resolve(service.Resources); ok {
+			if size.CPU > 0 {
+				vars[fmt.Sprintf("%s_cpus", prefix)] = sizing.FormatCPU(size.CPU)
+			}
+			if size.Memory > 0 {
+				vars[fmt.Sprintf("%s_memory", prefix)] = sizing.FormatMemory(size.Memory)
+			}
+		}
+
+		// Network allowed to reach internal ports
+		for _, port := range service.Ports {
+			if service.Enabled && ports.Exposure(port) == ports.Internal {
+				vars["internal_network"] = "10.0.0.0/8"
+			}
+		}
+	}
+
+	// Add secrets, read when the playbook runs
+	for _, name := range secrets.Used(config) {
+		vars[name] = secretLookup(name, config.Secrets[name])
 	}
 
 	return vars
 }
 
+// secretLookup returns the Jinja2 expression for the value of a secret.
+// Generated secrets are Ansible Vault variables named vault_<name>.
+func secretLookup(name string, secret types.SecretConfig) string {
+	switch secrets.Source(secret) {
+	case secrets.Env:
+		return fmt.Sprintf("{{ lookup('env', '%s') }}", secret.Env)
+	case secrets.File:
+		return fmt.Sprintf("{{ lookup('file', '%s') }}", strings.ReplaceAll(secret.File, "'", "\\'"))
+	case secrets.External:
+		return fmt.Sprintf("{{ lookup('amazon.aws.aws_secret', '%s') }}", strings.ReplaceAll(secret.External, "'", "\\'"))
+	}
+	return "{{ vault_" + name + " }}"
+}
+
 // generateTasks generates tasks for the playbook
 func (g *Generator) generateTasks(config *types.ProjectConfig) []AnsibleTask {
 	var tasks []AnsibleTask
@@ -255,6 +375,12 @@ func (g *Generator) generateTasks(config *types.ProjectConfig) []AnsibleTask {
 		}
 	}
 
+	// Open the firewall for public and internal ports
+	tasks = append(tasks, g.generateFirewallTasks(config)...)
+
+	// Write secrets where Docker Compose reads them
+	tasks = append(tasks, g.generateSecretTasks(config)...)
+
 	// Create docker-compose file
 	tasks = append(tasks, AnsibleTask{
 		Name:    "Create docker-compose.yml",
@@ -270,6 +396,171 @@ func (g *Generator) generateTasks(config *types.ProjectConfig) []AnsibleTask {
 		State:   "present",
 	})
 
+	// Wait for services to become healthy
+	tasks = append(tasks, g.generateHealthTasks(config)...)
+
+	return tasks
+}
+
+// generateHealthTasks waits for each enabled service with a healthcheck after
+// deployment, in dependency order. HTTP and TCP probes of ports published on
+// the host are checked from the host; other checks run in the container.
+func (g *Generator) generateHealthTasks(config *types.ProjectConfig) []AnsibleTask {
+	var tasks []AnsibleTask
+
+	for _, service := range config.Services {
+		check := service.Healthcheck
+		if !service.Enabled || check == nil {
+			continue
+		}
+
+		name := fmt.Sprintf("Wait for %s to become healthy", service.Name)
+		register := strings.ReplaceAll(service.Name, "-", "_") + "_health"
+		delay := max(health.Seconds(health.Interval(check)), 1)
+		retries := (health.Seconds(health.Deadline(check)) + delay - 1) / delay
+		hostPort := publishedPort(service, health.Port(check))
+
+		switch {
+		case check.HTTP != nil && hostPort > 0:
+			tasks = append(tasks, AnsibleTask{
+				Name:     name,
+				Module:   "uri",
+				Args:     map[string]string{"url": fmt.Sprintf("http://localhost:%d%s", hostPort, health.Path(check.HTTP))},
+				Register: register,
+				Until:    register + " is succeeded",
+				Retries:  retries,
+				Delay:    delay,
+			})
+		case check.TCP != nil && hostPort > 0:
+			tasks = append(tasks, AnsibleTask{
+				Name:   name,
+				Module: "wait_for",
+				Args: map[string]string{
+					"host":    "localhost",
+					"port":    strconv.Itoa(hostPort),
+					"timeout": strconv.Itoa(health.Seconds(health.Deadline(check))),
+				},
+			})
+		default:
+			tasks = append(tasks, AnsibleTask{
+				Name:   name,
+				Module: "command",
+				Args: map[string]string{
+					"cmd": fmt.Sprintf("docker compose -f /opt/{{ project_name }}/docker-compose.yml exec -T %s sh -c %s", service.Name, shellQuote(health.ShellCommand(check))),
+				},
+				Register: register,
+				Until:    register + " is succeeded",
+				Retries:  retries,
+				Delay:    delay,
+			})
+		}
+	}
+
+	return tasks
+}
+
+// publishedPort returns the host port a container port is published on, or
+// 0 when it is not published
+func publishedPort(service types.ServiceConfig, containerPort int) int {
+	for _, port := range service.Ports {
+		if port.Host == 0 || ports.Exposure(port) != ports.Public {
+			continue
+		}
+		if containerPort >= port.Container && containerPort <= ports.ContainerEnd(port) {
+			return port.Host + containerPort - port.Container
+		}
+	}
+	return 0
+}
+
+// shellQuote quotes a string as a single POSIX shell word
+func shellQuote(text string) string {
+	return "'" + strings.ReplaceAll(text, "'", `'\''`) + "'"
+}
+
+// generateFirewallTasks generates ufw rules for the ports of enabled
+// services. Public ports are allowed from anywhere and internal ones from
+// internal_network; ports bound to a loopback address or not exposed get no
+// rule.
+func (g *Generator) generateFirewallTasks(config *types.ProjectConfig) []AnsibleTask {
+	var tasks []AnsibleTask
+
+	for _, service := range config.Services {
+		if !service.Enabled {
+			continue
+		}
+
+		for _, port := range service.Ports {
+			args := map[string]string{
+				"rule":  "allow",
+				"proto": ports.Protocol(port),
+			}
+			switch ports.Exposure(port) {
+			case ports.Public:
+				if ports.Loopback(port) {
+					continue
+				}
+				if port.Host > 0 {
+					args["port"] = ufwPort(port.Host, ports.HostEnd(port))
+				} else {
+					args["port"] = ufwPort(port.Container, ports.ContainerEnd(port))
+				}
+			case ports.Internal:
+				args["port"] = ufwPort(port.Container, ports.ContainerEnd(port))
+				args["from_ip"] = "{{ internal_network }}"
+			default:
+				continue
+			}
+
+			tasks = append(tasks, AnsibleTask{
+				Name:   fmt.Sprintf("Allow %s/%s for %s", args["port"], args["proto"], service.Name),
+				Module: "community.general.ufw",
+				Args:   args,
+			})
+		}
+	}
+
+	return tasks
+}
+
+// generateSecretTasks writes the secrets of enabled services on the hosts:
+// file secrets to their file and all others to the project's .env, which
+// Docker Compose reads. Both are only readable by their owner and the values
+// are kept out of the logs.
+func (g *Generator) generateSecretTasks(config *types.ProjectConfig) []AnsibleTask {
+	var tasks []AnsibleTask
+
+	for _, name := range secrets.Used(config) {
+		secret := config.Secrets[name]
+		task := AnsibleTask{
+			Name:  fmt.Sprintf("Write %s secret", name),
+			NoLog: true,
+		}
+		if secrets.Source(secret) == secrets.File {
+			dest := secret.File
+			if !strings.HasPrefix(dest, "/") {
+				dest = "/opt/{{ project_name }}/" + strings.TrimPrefix(dest, "./")
+			}
+			task.Module = "copy"
+			task.Args = map[string]string{
+				"content": "{{ " + name + " }}",
+				"dest":    dest,
+				"mode":    "0600",
+			}
+		} else {
+			variable := secrets.EnvVar(name, secret)
+			task.Module = "lineinfile"
+			task.Args = map[string]string{
+				"path":   "/opt/{{ project_name }}/.env",
+				"regexp": "^" + variable + "=",
+				"line":   variable + "={{ " + name + " }}",
+				"create": "true",
+				"mode":   "0600",
+			}
+		}
+		tasks = append(tasks, task)
+	}
+
 	return tasks
 }
 
@@ -310,17 +601,31 @@ func (g *Generator) generateInventory(config *types.ProjectConfig) (string, erro
 		}
 	}
 
+	// Add a host group sized to the replicas of each scaled service
+	for _, service := range config.Services {
+		if !service.Enabled || !scaling.Scaled(service) {
+			continue
+		}
+		prefix := strings.ReplaceAll(service.Name, "-", "_")
+		hosts := make(map[string]map[string]interface{})
+		for i := 1; i <= scaling.Replicas(service); i++ {
+			host := fmt.Sprintf("%s%d", prefix, i)
+			hosts[host] = map[string]interface{}{
+				"ansible_host": fmt.Sprintf("{{ %s_ip | default('127.0.0.1') }}", host),
+				"ansible_user": "{{ ansible_user | default('ubuntu') }}",
+			}
+		}
+		inventory.All.Children[prefix+"_servers"] = struct {
+			Hosts map[string]map[string]interface{} `yaml:"hosts"`
+		}{Hosts: hosts}
+	}
+
 	// Add global variables
 	inventory.All.Vars = map[string]interface{}{
 		"project_name": config.Name,
 		"environment":  config.Environment,
 	}
 
-	// Add project variables
-	for key, value := range config.Variables {
-		inventory.All.Vars[key] = value
-	}
-
 	yamlData, err := yaml.Marshal(inventory)
 	if err != nil {
 		return "", err
@@ -329,25 +634,89 @@ func (g *Generator) generateInventory(config *types.ProjectConfig) (string, erro
 	return string(yamlData), nil
 }
 
+// VaultNames returns the sorted names of the vault variables a project
+// needs: vault_<name> for each generated secret that services use
+func VaultNames(config *types.ProjectConfig) []string {
+	var names []string
+	for _, name := range secrets.Used(config) {
+		if secrets.Source(config.Secrets[name]) == secrets.Generate {
+			names = append(names, "vault_"+name)
+		}
+	}
+	return names
+}
+
+// vaultValues returns the values of the vault variables that are known
+func (g *Generator) vaultValues(config *types.ProjectConfig) map[string]string {
+	values := make(map[string]string)
+	for _, name := range secrets.Used(config) {
+		value, exists := g.secretValues[name]
+		if exists && secrets.Source(config.Secrets[name]) == secrets.Generate {
+			values["vault_"+name] = value
+		}
+	}
+	return values
+}
+
+// generateGroupVars generates group_vars/all/vars.yml with the project
+// variables, which hold no secrets
+func (g *Generator) generateGroupVars(config *types.ProjectConfig) (string, error) {
+	yamlData, err := yaml.Marshal(config.Variables)
+	if err != nil {
+		return "", err
+	}
+	return "---\n# Project variables; secrets are in vault.yml\n" + string(yamlData), nil
+}
+
+// generateVault generates group_vars/all/vault.yml, encrypted with Ansible
+// Vault
+func (g *Generator) generateVault(values map[string]string) (string, error) {
+	yamlData, err := yaml.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return secrets.EncryptVault(append([]byte("---\n"), yamlData...), g.vaultPassword)
+}
+
 // generateRequirements generates Ansible requirements if needed
 func (g *Generator) generateRequirements(config *types.ProjectConfig) string {
-	// For now, return empty. Can be extended to include collections/roles
+	// External secrets are read with the amazon.aws collection
+	for _, name := range secrets.Used(config) {
+		if secrets.Source(config.Secrets[name]) == secrets.External {
+			return "---\ncollections:\n  - name: amazon.aws\n"
+		}
+	}
 	return ""
 }
 
 // Helper functions
-func (g *Generator) extractPorts(ports []types.PortConfig) []string {
+func (g *Generator) extractPorts(servicePorts []types.PortConfig) []string {
 	var portStrings []string
-	for _, port := range ports {
-		if port.Host > 0 {
-			portStrings = append(portStrings, fmt.Sprintf("%d:%d", port.Host, port.Container))
-		} else {
-			portStrings = append(portStrings, fmt.Sprintf("%d", port.Container))
+	for _, port := range servicePorts {
+		if ports.Exposure(port) == ports.Public {
+			portStrings = append(portStrings, ports.Mapping(port))
 		}
 	}
 	return portStrings
 }
 
+// ufwPort formats a port range in ufw's from:to syntax
+func ufwPort(from, to int) string {
+	if to > from {
+		return fmt.Sprintf("%d:%d", from, to)
+	}
+	return fmt.Sprintf("%d", from)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (g *Generator) extractVolumes(volumes []types.VolumeConfig) []string {
 	var volumeStrings []string
 	for _, volume := range volumes {
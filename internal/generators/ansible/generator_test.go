@@ -0,0 +1,69 @@
+package ansible
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/secrets"
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+func vaultProject() *types.ProjectConfig {
+	return &types.ProjectConfig{
+		Name:      "demo",
+		Type:      "web-app",
+		Variables: map[string]string{"API_URL": "http://api"},
+		Secrets: map[string]types.SecretConfig{
+			"db_password": {Generate: &types.GenerateConfig{}},
+			"api_key":     {Env: "API_KEY"},
+			"unused":      {Generate: &types.GenerateConfig{}},
+		},
+		Services: []types.ServiceConfig{
+			{
+				Name:    "api",
+				Type:    "api",
+				Image:   "app:1",
+				Secrets: []types.SecretRef{{Name: "db_password"}, {Name: "api_key"}},
+				Enabled: true,
+			},
+		},
+	}
+}
+
+func TestVaultNames(t *testing.T) {
+	if got, want := VaultNames(vaultProject()), []string{"vault_db_password"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("VaultNames() = %v, want %v", got, want)
+	}
+}
+
+func TestGenerateVault(t *testing.T) {
+	password := []byte("vault password")
+	files, err := NewGenerator().
+		WithVault(password, map[string]string{"db_password": "Generated-Value-123"}).
+		Generate(vaultProject())
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	contents := make(map[string]string)
+	for _, file := range files {
+		contents[file.Path] = file.Content
+	}
+	vars := contents["group_vars/all/vars.yml"]
+	if !strings.Contains(vars, "API_URL: http://api") || strings.Contains(vars, "vault_") {
+		t.Errorf("vars.yml = %q, want the project variables only", vars)
+	}
+	plain, err := secrets.DecryptVault(contents["group_vars/all/vault.yml"], password)
+	if err != nil {
+		t.Fatalf("vault.yml does not decrypt: %v", err)
+	}
+	if !strings.Contains(string(plain), "vault_db_password: Generated-Value-123") {
+		t.Errorf("vault.yml = %q, want vault_db_password", plain)
+	}
+	for path, content := range contents {
+		if path != "group_vars/all/vault.yml" && strings.Contains(content, "Generated-Value-123") {
+			t.Errorf("%s contains the secret value", path)
+		}
+	}
+}
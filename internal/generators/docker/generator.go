@@ -2,13 +2,26 @@ package docker
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/graph"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/health"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/interpolation"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/ports"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/scaling"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/secrets"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/sizing"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/validation"
 	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
 )
 
 // Generator implements Docker Compose generation
-type Generator struct{}
+type Generator struct {
+	resolver     *interpolation.Resolver
+	secretValues map[string]string
+}
 
 // NewGenerator creates a new Docker Compose generator
 func NewGenerator() *Generator {
@@ -20,12 +33,36 @@ func (g *Generator) GetTarget() types.Target {
 	return types.TargetDocker
 }
 
+// WithResolver sets the resolver used for ${VAR} references
+func (g *Generator) WithResolver(resolver *interpolation.Resolver) *Generator {
+	g.resolver = resolver
+	return g
+}
+
+// WithSecretValues sets the values of generated and env secrets, keyed by
+// secret name, that are written to .env. Without them .env leaves them out.
+func (g *Generator) WithSecretValues(values map[string]string) *Generator {
+	g.secretValues = values
+	return g
+}
+
 // Generate generates Docker Compose files from project config
 func (g *Generator) Generate(config *types.ProjectConfig) ([]types.GeneratedFile, error) {
 	if err := g.Validate(config); err != nil {
 		return nil, err
 	}
 
+	// Keep project variables as Compose ${} references, resolve the rest
+	config, err := interpolation.ResolverFor(g.resolver, config).Apply(config, interpolation.ComposeFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate services in dependency order
+	if config.Services, err = graph.New(config).Order(); err != nil {
+		return nil, err
+	}
+
 	// Generate docker-compose.yml
 	yamlContent, err := g.generateComposeYAML(config)
 	if err != nil {
@@ -46,8 +83,19 @@ func (g *Generator) Generate(config *types.ProjectConfig) ([]types.GeneratedFile
 
 	if envContent != "" {
 		files = append(files, types.GeneratedFile{
-			Path:     ".env",
-			Content:  envContent,
+			Path:      ".env",
+			Content:   envContent,
+			Type:      types.TargetDocker,
+			Encoding:  "utf-8",
+			Sensitive: true,
+		})
+	}
+
+	// Generate .env.example, listing the keys of .env without values
+	if exampleContent := g.generateEnvExample(config); exampleContent != "" {
+		files = append(files, types.GeneratedFile{
+			Path:     ".env.example",
+			Content:  exampleContent,
 			Type:     types.TargetDocker,
 			Encoding: "utf-8",
 		})
@@ -58,25 +106,7 @@ func (g *Generator) Generate(config *types.ProjectConfig) ([]types.GeneratedFile
 
 // Validate validates the project config for Docker Compose generation
 func (g *Generator) Validate(config *types.ProjectConfig) error {
-	var errors types.ValidationErrors
-
-	if config.Name == "" {
-		errors.Add("name", "project name is required", config.Name)
-	}
-
-	if len(config.Services) == 0 {
-		errors.Add("services", "at least one service is required", len(config.Services))
-	}
-
-	// Validate each service
-	for i, service := range config.Services {
-		if service.Name == "" {
-			errors.Add(fmt.Sprintf("services[%d].name", i), "service name is required", service.Name)
-		}
-		if service.Type == "" {
-			errors.Add(fmt.Sprintf("services[%d].type", i), "service type is required", service.Type)
-		}
-	}
+	errors := validation.Project(config, interpolation.ResolverFor(g.resolver, config))
 
 	if errors.HasErrors() {
 		return errors
@@ -107,22 +137,31 @@ func (g *Generator) generateComposeYAML(config *types.ProjectConfig) (string, er
 			builder.WriteString("\n")
 		}
 
-		// Generate ports
-		if len(service.Ports) > 0 {
+		// Generate ports: public ports are published, internal ones are only
+		// exposed to the other services and none are left out
+		var published, exposed []string
+		for _, port := range service.Ports {
+			switch ports.Exposure(port) {
+			case ports.Public:
+				published = append(published, ports.Mapping(port))
+			case ports.Internal:
+				exposed = append(exposed, ports.Expose(port))
+			}
+		}
+		if len(published) > 0 {
 			builder.WriteString("    ports:\n")
-			for _, port := range service.Ports {
+			for _, mapping := range published {
 				builder.WriteString("      - \"")
-				if port.Host > 0 {
-					builder.WriteString(fmt.Sprintf("%d", port.Host))
-					builder.WriteString(":")
-				}
-				builder.WriteString(fmt.Sprintf("%d", port.Container))
-				builder.WriteString("\"")
-				if port.Protocol != "" {
-					builder.WriteString(" # ")
-					builder.WriteString(port.Protocol)
-				}
-				builder.WriteString("\n")
+				builder.WriteString(mapping)
+				builder.WriteString("\"\n")
+			}
+		}
+		if len(exposed) > 0 {
+			builder.WriteString("    expose:\n")
+			for _, mapping := range exposed {
+				builder.WriteString("      - \"")
+				builder.WriteString(mapping)
+				builder.WriteString("\"\n")
 			}
 		}
 
@@ -141,8 +180,11 @@ func (g *Generator) generateComposeYAML(config *types.ProjectConfig) (string, er
 			}
 		}
 
-		// Generate environment variables
-		if len(service.Environment) > 0 {
+		// Generate environment variables. Secrets are interpolated by Compose
+		// from the environment or .env, except file secrets, which are
+		// passed as the path of the mounted file in <VAR>_FILE.
+		secretEnv := secretEnvironment(config, service)
+		if len(service.Environment) > 0 || len(secretEnv) > 0 {
 			builder.WriteString("    environment:\n")
 			for key, value := range service.Environment {
 				builder.WriteString("      ")
@@ -151,18 +193,89 @@ func (g *Generator) generateComposeYAML(config *types.ProjectConfig) (string, er
 				builder.WriteString(value)
 				builder.WriteString("\n")
 			}
+			for _, entry := range secretEnv {
+				builder.WriteString("      ")
+				builder.WriteString(entry)
+				builder.WriteString("\n")
+			}
 		}
 
-		// Generate dependencies
-		if len(service.DependsOn) > 0 {
-			builder.WriteString("    depends_on:\n")
-			for _, dep := range service.DependsOn {
+		// Generate secrets, mounted at /run/secrets/<name>
+		if len(service.Secrets) > 0 {
+			builder.WriteString("    secrets:\n")
+			for _, ref := range service.Secrets {
 				builder.WriteString("      - ")
-				builder.WriteString(dep)
+				builder.WriteString(ref.Name)
 				builder.WriteString("\n")
 			}
 		}
 
+		// Generate healthcheck
+		if check := service.Healthcheck; check != nil {
+			builder.WriteString("    healthcheck:\n")
+			builder.WriteString("      test: [\"CMD-SHELL\", ")
+			// $ is doubled so the container's shell expands it, not Compose
+			builder.WriteString(strconv.Quote(strings.ReplaceAll(health.ShellCommand(check), "$", "$$")))
+			builder.WriteString("]\n")
+			if check.Interval != "" {
+				builder.WriteString("      interval: " + check.Interval + "\n")
+			}
+			if check.Timeout != "" {
+				builder.WriteString("      timeout: " + check.Timeout + "\n")
+			}
+			if check.Retries > 0 {
+				builder.WriteString(fmt.Sprintf("      retries: %d\n", check.Retries))
+			}
+			if check.StartPeriod != "" {
+				builder.WriteString("      start_period: " + check.StartPeriod + "\n")
+			}
+		}
+
+		// Generate replicas, resource limits and reservations. Compose does
+		// not autoscale, so autoscaled services start their minimum.
+		size, sized := sizing.Resolve(service.Resources)
+		sized = sized && (size.CPU > 0 || size.Memory > 0)
+		replicas := scaling.Replicas(service)
+		if replicas > 1 || sized {
+			builder.WriteString("    deploy:\n")
+		}
+		if replicas > 1 {
+			builder.WriteString(fmt.Sprintf("      replicas: %d\n", replicas))
+		}
+		if sized {
+			builder.WriteString("      resources:\n")
+			builder.WriteString("        limits:\n")
+			writeResources(&builder, size)
+			builder.WriteString("        reservations:\n")
+			writeResources(&builder, sizing.Reservation(size))
+		}
+
+		// Generate dependencies. Services with a healthcheck are waited
+		// for until healthy, which needs the long syntax.
+		if len(service.DependsOn) > 0 {
+			builder.WriteString("    depends_on:\n")
+			if hasHealthyDependency(config, service) {
+				for _, dep := range service.DependsOn {
+					condition := "service_started"
+					if healthcheck(config, dep) != nil {
+						condition = "service_healthy"
+					}
+					builder.WriteString("      ")
+					builder.WriteString(dep)
+					builder.WriteString(":\n")
+					builder.WriteString("        condition: ")
+					builder.WriteString(condition)
+					builder.WriteString("\n")
+				}
+			} else {
+				for _, dep := range service.DependsOn {
+					builder.WriteString("      - ")
+					builder.WriteString(dep)
+					builder.WriteString("\n")
+				}
+			}
+		}
+
 		builder.WriteString("\n")
 	}
 
@@ -188,28 +301,65 @@ func (g *Generator) generateComposeYAML(config *types.ProjectConfig) (string, er
 		}
 	}
 
+	// Generate secrets: file secrets are read from their file, all others
+	// from the variable that carries them
+	if used := secrets.Used(config); len(used) > 0 {
+		if hasVolumes {
+			builder.WriteString("\n")
+		}
+		builder.WriteString("secrets:\n")
+		for _, name := range used {
+			secret := config.Secrets[name]
+			builder.WriteString("  ")
+			builder.WriteString(name)
+			builder.WriteString(":\n")
+			if secrets.Source(secret) == secrets.File {
+				builder.WriteString("    file: ")
+				builder.WriteString(secret.File)
+			} else {
+				builder.WriteString("    environment: ")
+				builder.WriteString(secrets.EnvVar(name, secret))
+			}
+			builder.WriteString("\n")
+		}
+	}
+
 	return builder.String(), nil
 }
 
-// generateEnvFile generates .env file content
+// secretEnvironment returns the environment entries of a service's secrets
+// that set an environment variable
+func secretEnvironment(config *types.ProjectConfig, service types.ServiceConfig) []string {
+	var entries []string
+	for _, ref := range service.Secrets {
+		secret, exists := config.Secrets[ref.Name]
+		if !exists || ref.Env == "" {
+			continue
+		}
+		if secrets.Source(secret) == secrets.File {
+			entries = append(entries, ref.Env+"_FILE: /run/secrets/"+ref.Name)
+		} else {
+			entries = append(entries, ref.Env+": ${"+secrets.EnvVar(ref.Name, secret)+"}")
+		}
+	}
+	return entries
+}
+
+// generateEnvFile generates .env file content: project variables, which
+// Compose interpolates, and the values of generated and stored env secrets
 func (g *Generator) generateEnvFile(config *types.ProjectConfig) string {
 	var envVars []string
 
 	// Add project-level variables
-	for key, value := range config.Variables {
-		envVars = append(envVars, fmt.Sprintf("%s=%s", key, value))
+	for _, key := range sortedKeys(config.Variables) {
+		envVars = append(envVars, secrets.EnvLine(key, config.Variables[key]))
 	}
 
-	// Add service-level environment variables that should be external
-	for _, service := range config.Services {
-		for key, value := range service.Environment {
-			// Only include variables that look like they should be external
-			if strings.Contains(strings.ToUpper(key), "PASSWORD") ||
-				strings.Contains(strings.ToUpper(key), "SECRET") ||
-				strings.Contains(strings.ToUpper(key), "KEY") ||
-				strings.Contains(strings.ToUpper(key), "TOKEN") {
-				envVars = append(envVars, fmt.Sprintf("%s_%s=%s", strings.ToUpper(service.Name), key, value))
-			}
+	// Add secret values
+	for _, name := range secrets.Used(config) {
+		secret := config.Secrets[name]
+		if value, exists := g.secretValues[name]; exists && (secrets.Source(secret) == secrets.Generate || secrets.Source(secret) == secrets.Env) {
+			envVars = append(envVars, secrets.EnvLine(secrets.EnvVar(name, secret), value))
 		}
 	}
 
@@ -219,3 +369,89 @@ func (g *Generator) generateEnvFile(config *types.ProjectConfig) string {
 
 	return strings.Join(envVars, "\n") + "\n"
 }
+
+// generateEnvExample generates .env.example content: every key Compose reads
+// from .env or the environment, with a description and no value
+func (g *Generator) generateEnvExample(config *types.ProjectConfig) string {
+	var builder strings.Builder
+
+	for _, key := range sortedKeys(config.Variables) {
+		builder.WriteString("\n# Project variable\n")
+		builder.WriteString(key)
+		builder.WriteString("=\n")
+	}
+
+	for _, name := range secrets.Used(config) {
+		secret := config.Secrets[name]
+		description := secret.Description
+		if description == "" {
+			description = "Secret " + name
+		}
+		switch secrets.Source(secret) {
+		case secrets.Generate:
+			description += " (generated by infra-gen generate)"
+		case secrets.Env:
+			description += " (set in the environment or with infra-gen secrets set)"
+		case secrets.External:
+			description += " (from " + secret.External + ", set at deploy time)"
+		default:
+			// File secrets are read by Compose from their file
+			continue
+		}
+		builder.WriteString("\n# ")
+		builder.WriteString(description)
+		builder.WriteString("\n")
+		builder.WriteString(secrets.EnvVar(name, secret))
+		builder.WriteString("=\n")
+	}
+
+	if builder.Len() == 0 {
+		return ""
+	}
+
+	return "# Keys of .env, which holds their values and is not committed\n" + builder.String()
+}
+
+// healthcheck returns the healthcheck of the named service, or nil
+func healthcheck(config *types.ProjectConfig, name string) *types.HealthcheckConfig {
+	for _, service := range config.Services {
+		if service.Name == name {
+			return service.Healthcheck
+		}
+	}
+	return nil
+}
+
+// hasHealthyDependency reports whether a service depends on a service with a
+// healthcheck
+func hasHealthyDependency(config *types.ProjectConfig, service types.ServiceConfig) bool {
+	for _, dep := range service.DependsOn {
+		if healthcheck(config, dep) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// writeResources writes the cpus and memory of a deploy.resources section
+func writeResources(builder *strings.Builder, size sizing.Size) {
+	if size.CPU > 0 {
+		builder.WriteString("          cpus: \"")
+		builder.WriteString(sizing.FormatCPU(size.CPU))
+		builder.WriteString("\"\n")
+	}
+	if size.Memory > 0 {
+		builder.WriteString("          memory: ")
+		builder.WriteString(sizing.FormatMemory(size.Memory))
+		builder.WriteString("\n")
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
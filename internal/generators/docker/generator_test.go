@@ -0,0 +1,46 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+func TestGenerateHealthcheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		check types.HealthcheckConfig
+		want  string
+	}{
+		{
+			name:  "shell variables escaped for Compose",
+			check: types.HealthcheckConfig{Command: `pg_isready -U "$POSTGRES_USER" -d ${POSTGRES_DB}`},
+			want:  `test: ["CMD-SHELL", "pg_isready -U \"$$POSTGRES_USER\" -d $${POSTGRES_DB}"]`,
+		},
+		{
+			name:  "http probe",
+			check: types.HealthcheckConfig{HTTP: &types.HTTPProbe{Port: 8080, Path: "/healthz"}, Interval: "10s", Retries: 5},
+			want:  "test: [\"CMD-SHELL\", \"wget -q -O /dev/null http://localhost:8080/healthz || exit 1\"]\n      interval: 10s\n      retries: 5\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &types.ProjectConfig{
+				Name: "demo",
+				Type: "web-app",
+				Services: []types.ServiceConfig{
+					{Name: "db", Type: "postgres", Image: "postgres:16", Healthcheck: &tt.check, Enabled: true},
+				},
+			}
+			files, err := NewGenerator().Generate(config)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if compose := files[0].Content; !strings.Contains(compose, tt.want) {
+				t.Errorf("docker-compose.yml does not contain %s:\n%s", tt.want, compose)
+			}
+		})
+	}
+}
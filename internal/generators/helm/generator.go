@@ -0,0 +1,515 @@
+package helm
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/generators/kubernetes"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/graph"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/interpolation"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/ports"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/scaling"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/sizing"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/validation"
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// valuesReference matches every .Values path used inside a chart template
+var valuesReference = regexp.MustCompile(`\.Values((?:\.[A-Za-z_][A-Za-z0-9_]*)+)`)
+
+// ChartValues represents the values.yaml of a generated chart
+type ChartValues struct {
+	Global    GlobalValues             `yaml:"global"`
+	Variables map[string]string        `yaml:"variables"`
+	Services  map[string]ServiceValues `yaml:"services"`
+}
+
+// GlobalValues holds project-wide chart values
+type GlobalValues struct {
+	Project     string `yaml:"project"`
+	Environment string `yaml:"environment"`
+}
+
+// ServiceValues holds the overridable values of a single service
+type ServiceValues struct {
+	Enabled      bool              `yaml:"enabled"`
+	ReplicaCount int               `yaml:"replicaCount"`
+	Image        string            `yaml:"image"`
+	Ports        []PortValues      `yaml:"ports"`
+	Env          map[string]string `yaml:"env"`
+	SecretEnv    map[string]string `yaml:"secretEnv"`
+	Resources    ResourceValues    `yaml:"resources"`
+}
+
+// ResourceValues holds the container resource limits and requests of a
+// service; both are empty when it sets no resources
+type ResourceValues struct {
+	Limits   map[string]string `yaml:"limits,omitempty"`
+	Requests map[string]string `yaml:"requests,omitempty"`
+}
+
+// PortValues holds a container port and the Service port exposing it
+type PortValues struct {
+	Name          string `yaml:"name"`
+	ContainerPort int    `yaml:"containerPort"`
+	ServicePort   int    `yaml:"servicePort"`
+	Protocol      string `yaml:"protocol"`
+}
+
+// Generator implements Helm chart generation
+type Generator struct {
+	resolver *interpolation.Resolver
+}
+
+// NewGenerator creates a new Helm chart generator
+func NewGenerator() *Generator {
+	return &Generator{}
+}
+
+// GetTarget returns the target type
+func (g *Generator) GetTarget() types.Target {
+	return types.TargetHelm
+}
+
+// WithResolver sets the resolver used for ${VAR} references
+func (g *Generator) WithResolver(resolver *interpolation.Resolver) *Generator {
+	g.resolver = resolver
+	return g
+}
+
+// Generate generates a Helm chart from project config
+func (g *Generator) Generate(config *types.ProjectConfig) ([]types.GeneratedFile, error) {
+	if err := g.Validate(config); err != nil {
+		return nil, err
+	}
+
+	// Resolve every ${VAR} reference to its value, except that env values
+	// look project variables up in .Values.variables, so a values file that
+	// overrides a variable changes the env values using it too
+	resolver := interpolation.ResolverFor(g.resolver, config)
+	resolved, err := resolver.Apply(config, nil)
+	if err != nil {
+		return nil, err
+	}
+	env, err := templateEnv(config, resolver)
+	if err != nil {
+		return nil, err
+	}
+	config = resolved
+
+	// Generate services in dependency order
+	if config.Services, err = graph.New(config).Order(); err != nil {
+		return nil, err
+	}
+
+	chartName := kubernetes.ResourceName(config.Name)
+	values, err := g.buildValues(config, env)
+	if err != nil {
+		return nil, err
+	}
+
+	valuesContent, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal values.yaml: %w", err)
+	}
+
+	files := []types.GeneratedFile{
+		g.file(chartName, "Chart.yaml", g.generateChartYAML(config)),
+		g.file(chartName, "values.yaml", string(valuesContent)),
+		g.file(chartName, "templates/_helpers.tpl", g.generateHelpers()),
+		g.file(chartName, "templates/configmap.yaml", g.generateVariablesConfigMap()),
+	}
+
+	for _, service := range config.Services {
+		name := kubernetes.ResourceName(service.Name)
+		key := valuesKey(service.Name)
+		files = append(files, g.file(chartName, "templates/"+name+"-deployment.yaml", g.generateDeployment(name, key)))
+		if len(exposedPorts(service)) > 0 {
+			files = append(files, g.file(chartName, "templates/"+name+"-service.yaml", g.generateService(name, key)))
+		}
+	}
+
+	if err := g.checkChart(files, string(valuesContent)); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// Validate validates the project config for Helm chart generation
+func (g *Generator) Validate(config *types.ProjectConfig) error {
+	errors := validation.Project(config, interpolation.ResolverFor(g.resolver, config))
+
+	// Check images and values keys
+	keys := make(map[string]string)
+	for i, service := range config.Services {
+		if service.Name == "" {
+			continue
+		}
+		if service.Enabled && service.Image == "" {
+			errors.Add(fmt.Sprintf("services[%d].image", i), "image is required for Helm deployments", service.Image)
+		}
+
+		key := valuesKey(service.Name)
+		if other, exists := keys[key]; exists && other != service.Name {
+			errors.Add(fmt.Sprintf("services[%d].name", i), fmt.Sprintf("values key '%s' collides with service '%s'", key, other), service.Name)
+		}
+		keys[key] = service.Name
+	}
+
+	if errors.HasErrors() {
+		return errors
+	}
+
+	return nil
+}
+
+// buildValues converts the project config into chart values, with the env
+// values of each service from env
+func (g *Generator) buildValues(config *types.ProjectConfig, env map[string]map[string]string) (ChartValues, error) {
+	values := ChartValues{
+		Global: GlobalValues{
+			Project:     config.Name,
+			Environment: config.Environment,
+		},
+		Variables: make(map[string]string),
+		Services:  make(map[string]ServiceValues),
+	}
+
+	for key, value := range config.Variables {
+		values.Variables[key] = value
+	}
+
+	for _, service := range config.Services {
+		serviceValues := ServiceValues{
+			Enabled:      service.Enabled,
+			ReplicaCount: scaling.Replicas(service),
+			Image:        service.Image,
+			Ports:        []PortValues{},
+			Env:          make(map[string]string),
+			SecretEnv:    make(map[string]string),
+		}
+
+		for _, port := range exposedPorts(service) {
+			servicePort := port.Container
+			if port.Host > 0 {
+				servicePort = port.Host
+			}
+			protocol, err := kubernetes.Protocol(port.Protocol)
+			if err != nil {
+				return ChartValues{}, err
+			}
+			serviceValues.Ports = append(serviceValues.Ports, PortValues{
+				Name:          fmt.Sprintf("%s-%d", strings.ToLower(protocol), port.Container),
+				ContainerPort: port.Container,
+				ServicePort:   servicePort,
+				Protocol:      protocol,
+			})
+		}
+
+		for key, value := range env[service.Name] {
+			serviceValues.Env[key] = value
+		}
+
+		// Secrets are Kubernetes Secrets created outside of the chart
+		for _, ref := range service.Secrets {
+			if _, exists := config.Secrets[ref.Name]; exists && ref.Env != "" {
+				serviceValues.SecretEnv[ref.Env] = kubernetes.ResourceName(ref.Name)
+			}
+		}
+
+		if size, ok := sizing.Resolve(service.Resources); ok && (size.CPU > 0 || size.Memory > 0) {
+			serviceValues.Resources = ResourceValues{
+				Limits:   resourceQuantities(size),
+				Requests: resourceQuantities(sizing.Reservation(size)),
+			}
+		}
+
+		values.Services[valuesKey(service.Name)] = serviceValues
+	}
+
+	return values, nil
+}
+
+// templateEnv returns the env values of each service, by service name, as
+// text for tpl: references to project variables become lookups of
+// .Values.variables, other references are resolved and the rest renders as
+// written. Disabled services are not resolved.
+func templateEnv(config *types.ProjectConfig, resolver *interpolation.Resolver) (map[string]map[string]string, error) {
+	env := make(map[string]map[string]string)
+	for _, service := range config.Services {
+		values := make(map[string]string)
+		for key, value := range service.Environment {
+			value = strings.ReplaceAll(value, "{{", `{{ "{{" }}`)
+			if service.Enabled {
+				rendered, err := resolver.Passthrough(value, interpolation.HelmFormat)
+				if err != nil {
+					return nil, fmt.Errorf("service %s: %w", service.Name, err)
+				}
+				value = strings.ReplaceAll(rendered, "$${", "${")
+			}
+			values[key] = value
+		}
+		env[service.Name] = values
+	}
+	return env, nil
+}
+
+// generateChartYAML generates the Chart.yaml metadata file
+func (g *Generator) generateChartYAML(config *types.ProjectConfig) string {
+	var builder strings.Builder
+
+	version := config.Version
+	if version == "" {
+		version = "0.1.0"
+	}
+
+	builder.WriteString("apiVersion: v2\n")
+	builder.WriteString("name: ")
+	builder.WriteString(kubernetes.ResourceName(config.Name))
+	builder.WriteString("\n")
+	if config.Description != "" {
+		builder.WriteString(fmt.Sprintf("description: %q\n", config.Description))
+	}
+	builder.WriteString("type: application\n")
+	builder.WriteString(fmt.Sprintf("version: %q\n", version))
+	builder.WriteString(fmt.Sprintf("appVersion: %q\n", version))
+
+	return builder.String()
+}
+
+// generateHelpers generates the shared template helpers
+func (g *Generator) generateHelpers() string {
+	var builder strings.Builder
+
+	builder.WriteString("{{/* Common labels */}}\n")
+	builder.WriteString("{{- define \"chart.labels\" -}}\n")
+	builder.WriteString("app.kubernetes.io/part-of: {{ .Chart.Name }}\n")
+	builder.WriteString("app.kubernetes.io/managed-by: {{ .Release.Service }}\n")
+	builder.WriteString("helm.sh/chart: {{ .Chart.Name }}-{{ .Chart.Version }}\n")
+	builder.WriteString("environment: {{ .Values.global.environment | quote }}\n")
+	builder.WriteString("{{- end }}\n")
+
+	return builder.String()
+}
+
+// generateVariablesConfigMap generates the ConfigMap holding project variables
+func (g *Generator) generateVariablesConfigMap() string {
+	var builder strings.Builder
+
+	builder.WriteString("apiVersion: v1\n")
+	builder.WriteString("kind: ConfigMap\n")
+	builder.WriteString("metadata:\n")
+	builder.WriteString("  name: {{ .Release.Name }}-variables\n")
+	builder.WriteString("  labels:\n")
+	builder.WriteString("    {{- include \"chart.labels\" . | nindent 4 }}\n")
+	builder.WriteString("data:\n")
+	builder.WriteString("  {{- range $key, $value := .Values.variables }}\n")
+	builder.WriteString("  {{ $key }}: {{ $value | quote }}\n")
+	builder.WriteString("  {{- end }}\n")
+
+	return builder.String()
+}
+
+// generateDeployment generates a values-driven Deployment template for a service
+func (g *Generator) generateDeployment(name, key string) string {
+	var builder strings.Builder
+	values := ".Values.services." + key
+
+	builder.WriteString("{{- if " + values + ".enabled }}\n")
+	builder.WriteString("apiVersion: apps/v1\n")
+	builder.WriteString("kind: Deployment\n")
+	builder.WriteString("metadata:\n")
+	builder.WriteString("  name: {{ .Release.Name }}-" + name + "\n")
+	builder.WriteString("  labels:\n")
+	builder.WriteString("    app.kubernetes.io/name: " + name + "\n")
+	builder.WriteString("    {{- include \"chart.labels\" . | nindent 4 }}\n")
+	builder.WriteString("spec:\n")
+	builder.WriteString("  replicas: {{ " + values + ".replicaCount }}\n")
+	builder.WriteString("  selector:\n")
+	builder.WriteString("    matchLabels:\n")
+	builder.WriteString("      app.kubernetes.io/name: " + name + "\n")
+	builder.WriteString("      app.kubernetes.io/instance: {{ .Release.Name }}\n")
+	builder.WriteString("  template:\n")
+	builder.WriteString("    metadata:\n")
+	builder.WriteString("      labels:\n")
+	builder.WriteString("        app.kubernetes.io/name: " + name + "\n")
+	builder.WriteString("        app.kubernetes.io/instance: {{ .Release.Name }}\n")
+	builder.WriteString("    spec:\n")
+	builder.WriteString("      containers:\n")
+	builder.WriteString("        - name: " + name + "\n")
+	builder.WriteString("          image: {{ " + values + ".image | quote }}\n")
+	builder.WriteString("          {{- with " + values + ".ports }}\n")
+	builder.WriteString("          ports:\n")
+	builder.WriteString("            {{- range . }}\n")
+	builder.WriteString("            - name: {{ .name }}\n")
+	builder.WriteString("              containerPort: {{ .containerPort }}\n")
+	builder.WriteString("              protocol: {{ .protocol }}\n")
+	builder.WriteString("            {{- end }}\n")
+	builder.WriteString("          {{- end }}\n")
+	builder.WriteString("          {{- with " + values + ".resources }}\n")
+	builder.WriteString("          resources:\n")
+	builder.WriteString("            {{- toYaml . | nindent 12 }}\n")
+	builder.WriteString("          {{- end }}\n")
+	builder.WriteString("          envFrom:\n")
+	builder.WriteString("            - configMapRef:\n")
+	builder.WriteString("                name: {{ .Release.Name }}-variables\n")
+	builder.WriteString("          {{- if or " + values + ".env " + values + ".secretEnv }}\n")
+	builder.WriteString("          env:\n")
+	builder.WriteString("            {{- range $key, $value := " + values + ".env }}\n")
+	builder.WriteString("            - name: {{ $key }}\n")
+	builder.WriteString("              value: {{ tpl $value $ | quote }}\n")
+	builder.WriteString("            {{- end }}\n")
+	builder.WriteString("            {{- range $key, $secret := " + values + ".secretEnv }}\n")
+	builder.WriteString("            - name: {{ $key }}\n")
+	builder.WriteString("              valueFrom:\n")
+	builder.WriteString("                secretKeyRef:\n")
+	builder.WriteString("                  name: {{ $secret }}\n")
+	builder.WriteString("                  key: value\n")
+	builder.WriteString("            {{- end }}\n")
+	builder.WriteString("          {{- end }}\n")
+	builder.WriteString("{{- end }}\n")
+
+	return builder.String()
+}
+
+// generateService generates a values-driven Service template for a service
+func (g *Generator) generateService(name, key string) string {
+	var builder strings.Builder
+	values := ".Values.services." + key
+
+	builder.WriteString("{{- if " + values + ".enabled }}\n")
+	builder.WriteString("apiVersion: v1\n")
+	builder.WriteString("kind: Service\n")
+	builder.WriteString("metadata:\n")
+	builder.WriteString("  name: {{ .Release.Name }}-" + name + "\n")
+	builder.WriteString("  labels:\n")
+	builder.WriteString("    app.kubernetes.io/name: " + name + "\n")
+	builder.WriteString("    {{- include \"chart.labels\" . | nindent 4 }}\n")
+	builder.WriteString("spec:\n")
+	builder.WriteString("  type: ClusterIP\n")
+	builder.WriteString("  selector:\n")
+	builder.WriteString("    app.kubernetes.io/name: " + name + "\n")
+	builder.WriteString("    app.kubernetes.io/instance: {{ .Release.Name }}\n")
+	builder.WriteString("  ports:\n")
+	builder.WriteString("    {{- range " + values + ".ports }}\n")
+	builder.WriteString("    - name: {{ .name }}\n")
+	builder.WriteString("      port: {{ .servicePort }}\n")
+	builder.WriteString("      targetPort: {{ .containerPort }}\n")
+	builder.WriteString("      protocol: {{ .protocol }}\n")
+	builder.WriteString("    {{- end }}\n")
+	builder.WriteString("{{- end }}\n")
+
+	return builder.String()
+}
+
+// checkChart verifies that every .Values path referenced by a template
+// exists in values.yaml, so a chart is never written half-wired
+func (g *Generator) checkChart(files []types.GeneratedFile, valuesContent string) error {
+	var values map[string]interface{}
+	if err := yaml.Unmarshal([]byte(valuesContent), &values); err != nil {
+		return fmt.Errorf("generated values.yaml is not valid YAML: %w", err)
+	}
+
+	var errors types.ValidationErrors
+	for _, file := range files {
+		if !strings.Contains(file.Path, "/templates/") {
+			continue
+		}
+
+		seen := make(map[string]bool)
+		for _, match := range valuesReference.FindAllStringSubmatch(file.Content, -1) {
+			reference := match[1]
+			if seen[reference] {
+				continue
+			}
+			seen[reference] = true
+
+			if !hasValuesPath(values, strings.Split(strings.TrimPrefix(reference, "."), ".")) {
+				errors.Add(file.Path, fmt.Sprintf("template references .Values%s which is not defined in values.yaml", reference), reference)
+			}
+		}
+	}
+
+	if errors.HasErrors() {
+		return fmt.Errorf("chart structure check failed: %w", errors)
+	}
+
+	return nil
+}
+
+func (g *Generator) file(chartName, name, content string) types.GeneratedFile {
+	return types.GeneratedFile{
+		Path:     path.Join("helm", chartName, name),
+		Content:  content,
+		Type:     types.TargetHelm,
+		Encoding: "utf-8",
+	}
+}
+
+// Helper functions
+func hasValuesPath(values map[string]interface{}, keys []string) bool {
+	var current interface{} = values
+	for _, key := range keys {
+		node, ok := current.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		current, ok = node[key]
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// valuesKey converts a service name into a key usable in template dot paths,
+// e.g. "api-gateway" becomes "apiGateway"
+func valuesKey(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	})
+
+	var builder strings.Builder
+	for i, part := range parts {
+		part = strings.ToLower(part)
+		if i > 0 && len(part) > 0 {
+			part = strings.ToUpper(part[:1]) + part[1:]
+		}
+		builder.WriteString(part)
+	}
+
+	key := builder.String()
+	if key == "" || (key[0] >= '0' && key[0] <= '9') {
+		key = "svc" + key
+	}
+	return key
+}
+
+// resourceQuantities returns the cpu and memory of a size as Kubernetes
+// quantities
+func resourceQuantities(size sizing.Size) map[string]string {
+	quantities := make(map[string]string)
+	if size.CPU > 0 {
+		quantities["cpu"] = sizing.KubernetesCPU(size.CPU)
+	}
+	if size.Memory > 0 {
+		quantities["memory"] = sizing.KubernetesMemory(size.Memory)
+	}
+	return quantities
+}
+
+// exposedPorts returns a service's ports other than those with exposure
+// none, with ranges expanded as Kubernetes has no port ranges
+func exposedPorts(service types.ServiceConfig) []types.PortConfig {
+	var exposed []types.PortConfig
+	for _, port := range service.Ports {
+		if ports.Exposure(port) != ports.None {
+			exposed = append(exposed, ports.Expand(port)...)
+		}
+	}
+	return exposed
+}
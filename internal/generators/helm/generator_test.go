@@ -0,0 +1,193 @@
+package helm
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/interpolation"
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+func chartProject() *types.ProjectConfig {
+	return &types.ProjectConfig{
+		Name:      "Demo_Shop",
+		Type:      "web-app",
+		Variables: map[string]string{"LOG_LEVEL": "info"},
+		Services: []types.ServiceConfig{
+			{
+				Name:        "api-gateway",
+				Type:        "api",
+				Image:       "gateway:1",
+				Ports:       []types.PortConfig{{Host: 80, Container: 8080}},
+				Environment: map[string]string{"LOG_LEVEL": "${LOG_LEVEL}"},
+				Enabled:     true,
+			},
+			{
+				Name:    "worker",
+				Type:    "worker",
+				Image:   "worker:1",
+				Enabled: true,
+			},
+		},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	files, err := NewGenerator().Generate(chartProject())
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	contents := make(map[string]string)
+	for _, file := range files {
+		contents[file.Path] = file.Content
+	}
+	for _, path := range []string{
+		"helm/demo-shop/Chart.yaml",
+		"helm/demo-shop/values.yaml",
+		"helm/demo-shop/templates/_helpers.tpl",
+		"helm/demo-shop/templates/configmap.yaml",
+		"helm/demo-shop/templates/api-gateway-deployment.yaml",
+		"helm/demo-shop/templates/api-gateway-service.yaml",
+		"helm/demo-shop/templates/worker-deployment.yaml",
+	} {
+		if _, exists := contents[path]; !exists {
+			t.Errorf("%s not generated", path)
+		}
+	}
+	if _, exists := contents["helm/demo-shop/templates/worker-service.yaml"]; exists {
+		t.Errorf("Service generated for worker without ports")
+	}
+
+	var values ChartValues
+	if err := yaml.Unmarshal([]byte(contents["helm/demo-shop/values.yaml"]), &values); err != nil {
+		t.Fatalf("values.yaml does not parse: %v", err)
+	}
+	if values.Variables["LOG_LEVEL"] != "info" {
+		t.Errorf("variables = %v, want LOG_LEVEL: info", values.Variables)
+	}
+	gateway, exists := values.Services["apiGateway"]
+	if !exists {
+		t.Fatalf("services = %v, want key apiGateway", values.Services)
+	}
+	if gateway.Image != "gateway:1" || gateway.Env["LOG_LEVEL"] != `{{ (index .Values.variables "LOG_LEVEL") }}` {
+		t.Errorf("apiGateway values = %+v, want image gateway:1 and LOG_LEVEL from .Values.variables", gateway)
+	}
+	if len(gateway.Ports) != 1 || gateway.Ports[0].ContainerPort != 8080 || gateway.Ports[0].Protocol != "TCP" {
+		t.Errorf("apiGateway ports = %+v, want container port 8080/TCP", gateway.Ports)
+	}
+	if !strings.Contains(contents["helm/demo-shop/templates/api-gateway-deployment.yaml"], ".Values.services.apiGateway.image") {
+		t.Errorf("deployment does not take its image from values:\n%s", contents["helm/demo-shop/templates/api-gateway-deployment.yaml"])
+	}
+}
+
+func TestTemplateEnv(t *testing.T) {
+	config := chartProject()
+	config.Services[0].Environment = map[string]string{
+		"LOG_LEVEL": "${LOG_LEVEL}",
+		"URL":       "http://${API_HOST:-localhost}:${API_PORT:-80}/${LOG_LEVEL:-debug}",
+		"DATA_DIR":  "${DATA_DIR:-/srv}",
+		"LITERAL":   "{{ not a template }} $${LOG_LEVEL}",
+	}
+	config.Services[1].Environment = map[string]string{"UNSET": "${UNSET}"}
+	config.Services[1].Enabled = false
+
+	env, err := templateEnv(config, interpolation.NewResolver(config.Variables, nil))
+	if err != nil {
+		t.Fatalf("templateEnv() error = %v", err)
+	}
+
+	want := map[string]string{
+		"LOG_LEVEL": `{{ (index .Values.variables "LOG_LEVEL") }}`,
+		"URL":       `http://localhost:80/{{ (index .Values.variables "LOG_LEVEL") | default "debug" }}`,
+		"DATA_DIR":  "/srv",
+		"LITERAL":   `{{ "{{" }} not a template }} ${LOG_LEVEL}`,
+	}
+	for key, value := range want {
+		if got := env["api-gateway"][key]; got != value {
+			t.Errorf("%s = %s, want %s", key, got, value)
+		}
+	}
+	if got := env["worker"]["UNSET"]; got != "${UNSET}" {
+		t.Errorf("disabled service UNSET = %s, want it unresolved", got)
+	}
+}
+
+func TestTemplateEnvOverride(t *testing.T) {
+	config := chartProject()
+	resolver := interpolation.NewResolver(config.Variables, map[string]string{"LOG_LEVEL": "warn"})
+
+	files, err := NewGenerator().WithResolver(resolver).Generate(config)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	for _, file := range files {
+		switch path.Base(file.Path) {
+		case "values.yaml":
+			if !strings.Contains(file.Content, "LOG_LEVEL: warn\n") || strings.Contains(file.Content, "LOG_LEVEL: info") {
+				t.Errorf("values.yaml does not set the --set value once, in variables:\n%s", file.Content)
+			}
+		case "api-gateway-deployment.yaml":
+			if !strings.Contains(file.Content, "value: {{ tpl $value $ | quote }}") {
+				t.Errorf("deployment does not render env values with tpl:\n%s", file.Content)
+			}
+		}
+	}
+}
+
+func TestCheckChart(t *testing.T) {
+	values := "services:\n  api:\n    image: app:1\n"
+	tests := []struct {
+		name     string
+		template string
+		wantErr  string
+	}{
+		{name: "defined key", template: "image: {{ .Values.services.api.image }}"},
+		{name: "undefined key", template: "image: {{ .Values.services.api.tag }}", wantErr: ".Values.services.api.tag which is not defined"},
+		{name: "path through a scalar", template: "{{ .Values.services.api.image.tag }}", wantErr: ".Values.services.api.image.tag"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files := []types.GeneratedFile{{Path: "helm/x/templates/t.yaml", Content: test.template}}
+			err := NewGenerator().checkChart(files, values)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkChart() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("checkChart() error = %v, want it to contain %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValuesKey(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: "api", want: "api"},
+		{in: "api-gateway", want: "apiGateway"},
+		{in: "Auth_Service.v2", want: "authServiceV2"},
+		{in: "2fa", want: "svc2fa"},
+		{in: "--", want: "svc"},
+	}
+	for _, tt := range tests {
+		if got := valuesKey(tt.in); got != tt.want {
+			t.Errorf("valuesKey(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidateKeyCollision(t *testing.T) {
+	config := chartProject()
+	config.Services[1].Name = "api_gateway"
+
+	err := NewGenerator().Validate(config)
+	if err == nil || !strings.Contains(err.Error(), "values key 'apiGateway' collides with service 'api-gateway'") {
+		t.Errorf("Validate() error = %v, want values key collision", err)
+	}
+}
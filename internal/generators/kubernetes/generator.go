@@ -0,0 +1,602 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/graph"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/interpolation"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/ports"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/scaling"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/sizing"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/validation"
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// dns1123Label matches names Kubernetes accepts for Services and most objects
+var dns1123Label = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// DefaultVolumeSize is the storage requested by claims of named volumes that
+// set no size
+const DefaultVolumeSize = "1Gi"
+
+// Generator implements Kubernetes manifest generation
+type Generator struct {
+	resolver *interpolation.Resolver
+}
+
+// NewGenerator creates a new Kubernetes generator
+func NewGenerator() *Generator {
+	return &Generator{}
+}
+
+// GetTarget returns the target type
+func (g *Generator) GetTarget() types.Target {
+	return types.TargetKubernetes
+}
+
+// WithResolver sets the resolver used for ${VAR} references
+func (g *Generator) WithResolver(resolver *interpolation.Resolver) *Generator {
+	g.resolver = resolver
+	return g
+}
+
+// Generate generates Kubernetes manifests from project config
+func (g *Generator) Generate(config *types.ProjectConfig) ([]types.GeneratedFile, error) {
+	if err := g.Validate(config); err != nil {
+		return nil, err
+	}
+
+	// Resolve every ${VAR} reference to its value
+	config, err := interpolation.ResolverFor(g.resolver, config).Apply(config, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate services in dependency order
+	if config.Services, err = graph.New(config).Order(); err != nil {
+		return nil, err
+	}
+
+	files := []types.GeneratedFile{}
+	claimed := make(map[string]bool)
+
+	for _, service := range config.Services {
+		if !service.Enabled {
+			continue
+		}
+
+		name := ResourceName(service.Name)
+		configData, secretData := g.splitEnvironment(service.Environment)
+
+		if len(configData) > 0 {
+			files = append(files, g.file(name+"-configmap.yaml", g.generateConfigMap(config, service, configData)))
+		}
+
+		if len(secretData) > 0 {
+			files = append(files, g.file(name+"-secret.yaml", g.generateSecret(config, service, secretData)))
+		}
+
+		// Services sharing a named volume share one claim
+		for _, volume := range service.Volumes {
+			if volume.Type == "volume" && !claimed[volume.Source] {
+				claimed[volume.Source] = true
+				files = append(files, g.file(ResourceName(volume.Source)+"-pvc.yaml", g.generatePVC(config, service, volume.Source)))
+			}
+		}
+
+		deployment, err := g.generateDeployment(config, service, len(configData) > 0, len(secretData) > 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, g.file(name+"-deployment.yaml", deployment))
+
+		if len(servicePorts(service)) > 0 {
+			serviceManifest, err := g.generateService(config, service)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, g.file(name+"-service.yaml", serviceManifest))
+		}
+
+		if service.Type == "frontend" && hasPublicPort(service) {
+			files = append(files, g.file(name+"-ingress.yaml", g.generateIngress(config, service)))
+		}
+
+		if service.Autoscaling != nil {
+			files = append(files, g.file(name+"-hpa.yaml", g.generateHPA(config, service)))
+		}
+	}
+
+	return files, nil
+}
+
+// Validate validates the project config for Kubernetes generation
+func (g *Generator) Validate(config *types.ProjectConfig) error {
+	errors := validation.Project(config, interpolation.ResolverFor(g.resolver, config))
+
+	// Check names, images and volumes
+	for i, service := range config.Services {
+		if service.Name != "" && !dns1123Label.MatchString(ResourceName(service.Name)) {
+			errors.Add(fmt.Sprintf("services[%d].name", i), "service name must be a valid DNS-1123 label for Kubernetes", service.Name)
+		}
+		if service.Enabled && service.Image == "" {
+			errors.Add(fmt.Sprintf("services[%d].image", i), "image is required for Kubernetes deployments", service.Image)
+		}
+		for j, volume := range service.Volumes {
+			if volume.Type != "volume" && !filepath.IsAbs(volume.Source) {
+				errors.Add(fmt.Sprintf("services[%d].volumes[%d].source", i, j), "bind mount source must be an absolute path for Kubernetes hostPath volumes", volume.Source)
+			}
+		}
+	}
+
+	if errors.HasErrors() {
+		return errors
+	}
+
+	return nil
+}
+
+// generateDeployment generates a Deployment manifest for a service
+func (g *Generator) generateDeployment(config *types.ProjectConfig, service types.ServiceConfig, hasConfigMap, hasSecret bool) (string, error) {
+	var builder strings.Builder
+	name := ResourceName(service.Name)
+
+	builder.WriteString("apiVersion: apps/v1\n")
+	builder.WriteString("kind: Deployment\n")
+	g.writeMetadata(&builder, config, service, name)
+	builder.WriteString("spec:\n")
+	builder.WriteString(fmt.Sprintf("  replicas: %d\n", scaling.Replicas(service)))
+	builder.WriteString("  selector:\n")
+	builder.WriteString("    matchLabels:\n")
+	builder.WriteString("      app.kubernetes.io/name: ")
+	builder.WriteString(name)
+	builder.WriteString("\n")
+	builder.WriteString("  template:\n")
+	builder.WriteString("    metadata:\n")
+	builder.WriteString("      labels:\n")
+	builder.WriteString("        app.kubernetes.io/name: ")
+	builder.WriteString(name)
+	builder.WriteString("\n")
+	builder.WriteString("        app.kubernetes.io/part-of: ")
+	builder.WriteString(ResourceName(config.Name))
+	builder.WriteString("\n")
+	builder.WriteString("    spec:\n")
+	builder.WriteString("      containers:\n")
+	builder.WriteString("        - name: ")
+	builder.WriteString(name)
+	builder.WriteString("\n")
+	builder.WriteString("          image: ")
+	builder.WriteString(service.Image)
+	builder.WriteString("\n")
+
+	if len(service.Ports) > 0 {
+		builder.WriteString("          ports:\n")
+		for _, port := range expandPorts(service.Ports) {
+			protocol, err := Protocol(port.Protocol)
+			if err != nil {
+				return "", err
+			}
+			builder.WriteString(fmt.Sprintf("            - containerPort: %d\n", port.Container))
+			builder.WriteString("              protocol: ")
+			builder.WriteString(protocol)
+			builder.WriteString("\n")
+		}
+	}
+
+	if size, ok := sizing.Resolve(service.Resources); ok && (size.CPU > 0 || size.Memory > 0) {
+		builder.WriteString("          resources:\n")
+		builder.WriteString("            limits:\n")
+		writeResources(&builder, size)
+		builder.WriteString("            requests:\n")
+		writeResources(&builder, sizing.Reservation(size))
+	}
+
+	// Secrets are Kubernetes Secrets created outside of the manifests, named
+	// after the project secret with the value under the key "value"
+	var secretEnv []types.SecretRef
+	for _, ref := range service.Secrets {
+		if _, exists := config.Secrets[ref.Name]; exists && ref.Env != "" {
+			secretEnv = append(secretEnv, ref)
+		}
+	}
+	if len(secretEnv) > 0 {
+		builder.WriteString("          env:\n")
+		for _, ref := range secretEnv {
+			builder.WriteString("            - name: ")
+			builder.WriteString(ref.Env)
+			builder.WriteString("\n")
+			builder.WriteString("              valueFrom:\n")
+			builder.WriteString("                secretKeyRef:\n")
+			builder.WriteString("                  name: ")
+			builder.WriteString(ResourceName(ref.Name))
+			builder.WriteString("\n")
+			builder.WriteString("                  key: value\n")
+		}
+	}
+
+	if hasConfigMap || hasSecret {
+		builder.WriteString("          envFrom:\n")
+		if hasConfigMap {
+			builder.WriteString("            - configMapRef:\n")
+			builder.WriteString("                name: ")
+			builder.WriteString(name)
+			builder.WriteString("-config\n")
+		}
+		if hasSecret {
+			builder.WriteString("            - secretRef:\n")
+			builder.WriteString("                name: ")
+			builder.WriteString(name)
+			builder.WriteString("-secret\n")
+		}
+	}
+
+	if len(service.Volumes) > 0 {
+		builder.WriteString("          volumeMounts:\n")
+		for i, volume := range service.Volumes {
+			builder.WriteString("            - name: ")
+			builder.WriteString(volumeName(volume, i))
+			builder.WriteString("\n")
+			builder.WriteString("              mountPath: ")
+			builder.WriteString(volume.Target)
+			builder.WriteString("\n")
+			if volume.ReadOnly {
+				builder.WriteString("              readOnly: true\n")
+			}
+		}
+
+		builder.WriteString("      volumes:\n")
+		for i, volume := range service.Volumes {
+			builder.WriteString("        - name: ")
+			builder.WriteString(volumeName(volume, i))
+			builder.WriteString("\n")
+			if volume.Type == "volume" {
+				builder.WriteString("          persistentVolumeClaim:\n")
+				builder.WriteString("            claimName: ")
+				builder.WriteString(ResourceName(volume.Source))
+				builder.WriteString("\n")
+			} else {
+				builder.WriteString("          hostPath:\n")
+				builder.WriteString("            path: ")
+				builder.WriteString(volume.Source)
+				builder.WriteString("\n")
+			}
+		}
+	}
+
+	return builder.String(), nil
+}
+
+// generateService generates a ClusterIP Service exposing the container ports
+func (g *Generator) generateService(config *types.ProjectConfig, service types.ServiceConfig) (string, error) {
+	var builder strings.Builder
+	name := ResourceName(service.Name)
+
+	builder.WriteString("apiVersion: v1\n")
+	builder.WriteString("kind: Service\n")
+	g.writeMetadata(&builder, config, service, name)
+	builder.WriteString("spec:\n")
+	builder.WriteString("  type: ClusterIP\n")
+	builder.WriteString("  selector:\n")
+	builder.WriteString("    app.kubernetes.io/name: ")
+	builder.WriteString(name)
+	builder.WriteString("\n")
+	builder.WriteString("  ports:\n")
+	for _, port := range servicePorts(service) {
+		servicePort := port.Container
+		if port.Host > 0 {
+			servicePort = port.Host
+		}
+		protocol, err := Protocol(port.Protocol)
+		if err != nil {
+			return "", err
+		}
+		builder.WriteString(fmt.Sprintf("    - name: %s-%d\n", strings.ToLower(protocol), port.Container))
+		builder.WriteString(fmt.Sprintf("      port: %d\n", servicePort))
+		builder.WriteString(fmt.Sprintf("      targetPort: %d\n", port.Container))
+		builder.WriteString("      protocol: ")
+		builder.WriteString(protocol)
+		builder.WriteString("\n")
+	}
+
+	return builder.String(), nil
+}
+
+// generatePVC generates the PersistentVolumeClaim of a named volume, labelled
+// with the first service that mounts it
+func (g *Generator) generatePVC(config *types.ProjectConfig, service types.ServiceConfig, source string) string {
+	var builder strings.Builder
+
+	builder.WriteString("apiVersion: v1\n")
+	builder.WriteString("kind: PersistentVolumeClaim\n")
+	g.writeMetadata(&builder, config, service, ResourceName(source))
+	builder.WriteString("spec:\n")
+	builder.WriteString("  accessModes:\n")
+	builder.WriteString("    - ReadWriteOnce\n")
+	builder.WriteString("  resources:\n")
+	builder.WriteString("    requests:\n")
+	builder.WriteString("      storage: ")
+	builder.WriteString(volumeSize(config, source))
+	builder.WriteString("\n")
+
+	return builder.String()
+}
+
+// generateConfigMap generates a ConfigMap with non-sensitive environment variables
+func (g *Generator) generateConfigMap(config *types.ProjectConfig, service types.ServiceConfig, data map[string]string) string {
+	var builder strings.Builder
+
+	builder.WriteString("apiVersion: v1\n")
+	builder.WriteString("kind: ConfigMap\n")
+	g.writeMetadata(&builder, config, service, ResourceName(service.Name)+"-config")
+	builder.WriteString("data:\n")
+	for _, key := range sortedKeys(data) {
+		builder.WriteString(fmt.Sprintf("  %s: %q\n", key, data[key]))
+	}
+
+	return builder.String()
+}
+
+// generateSecret generates an Opaque Secret with sensitive environment variables
+func (g *Generator) generateSecret(config *types.ProjectConfig, service types.ServiceConfig, data map[string]string) string {
+	var builder strings.Builder
+
+	builder.WriteString("apiVersion: v1\n")
+	builder.WriteString("kind: Secret\n")
+	g.writeMetadata(&builder, config, service, ResourceName(service.Name)+"-secret")
+	builder.WriteString("type: Opaque\n")
+	builder.WriteString("data:\n")
+	for _, key := range sortedKeys(data) {
+		builder.WriteString("  ")
+		builder.WriteString(key)
+		builder.WriteString(": ")
+		builder.WriteString(base64.StdEncoding.EncodeToString([]byte(data[key])))
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
+
+// generateIngress generates an Ingress routing to a frontend service.
+// The host rule is taken from the INGRESS_HOST project variable when set.
+func (g *Generator) generateIngress(config *types.ProjectConfig, service types.ServiceConfig) string {
+	var builder strings.Builder
+	name := ResourceName(service.Name)
+
+	var servicePort int
+	for _, port := range service.Ports {
+		if ports.Exposure(port) == ports.Public {
+			servicePort = port.Container
+			if port.Host > 0 {
+				servicePort = port.Host
+			}
+			break
+		}
+	}
+
+	builder.WriteString("apiVersion: networking.k8s.io/v1\n")
+	builder.WriteString("kind: Ingress\n")
+	g.writeMetadata(&builder, config, service, name)
+	builder.WriteString("spec:\n")
+	builder.WriteString("  rules:\n")
+	if host := config.Variables["INGRESS_HOST"]; host != "" {
+		builder.WriteString("    - host: ")
+		builder.WriteString(host)
+		builder.WriteString("\n")
+		builder.WriteString("      http:\n")
+	} else {
+		builder.WriteString("    - http:\n")
+	}
+	builder.WriteString("        paths:\n")
+	builder.WriteString("          - path: /\n")
+	builder.WriteString("            pathType: Prefix\n")
+	builder.WriteString("            backend:\n")
+	builder.WriteString("              service:\n")
+	builder.WriteString("                name: ")
+	builder.WriteString(name)
+	builder.WriteString("\n")
+	builder.WriteString("                port:\n")
+	builder.WriteString(fmt.Sprintf("                  number: %d\n", servicePort))
+
+	return builder.String()
+}
+
+// generateHPA generates a HorizontalPodAutoscaler that scales a Deployment
+// on its autoscaling targets
+func (g *Generator) generateHPA(config *types.ProjectConfig, service types.ServiceConfig) string {
+	var builder strings.Builder
+	name := ResourceName(service.Name)
+	autoscaling := service.Autoscaling
+
+	builder.WriteString("apiVersion: autoscaling/v2\n")
+	builder.WriteString("kind: HorizontalPodAutoscaler\n")
+	g.writeMetadata(&builder, config, service, name)
+	builder.WriteString("spec:\n")
+	builder.WriteString("  scaleTargetRef:\n")
+	builder.WriteString("    apiVersion: apps/v1\n")
+	builder.WriteString("    kind: Deployment\n")
+	builder.WriteString("    name: ")
+	builder.WriteString(name)
+	builder.WriteString("\n")
+	builder.WriteString(fmt.Sprintf("  minReplicas: %d\n", autoscaling.Min))
+	builder.WriteString(fmt.Sprintf("  maxReplicas: %d\n", autoscaling.Max))
+	builder.WriteString("  metrics:\n")
+	for _, target := range []struct {
+		resource string
+		value    int
+	}{
+		{"cpu", autoscaling.TargetCPU},
+		{"memory", autoscaling.TargetMemory},
+	} {
+		if target.value == 0 {
+			continue
+		}
+		builder.WriteString("    - type: Resource\n")
+		builder.WriteString("      resource:\n")
+		builder.WriteString("        name: ")
+		builder.WriteString(target.resource)
+		builder.WriteString("\n")
+		builder.WriteString("        target:\n")
+		builder.WriteString("          type: Utilization\n")
+		builder.WriteString(fmt.Sprintf("          averageUtilization: %d\n", target.value))
+	}
+
+	return builder.String()
+}
+
+// writeMetadata writes the common metadata block shared by all manifests
+func (g *Generator) writeMetadata(builder *strings.Builder, config *types.ProjectConfig, service types.ServiceConfig, name string) {
+	builder.WriteString("metadata:\n")
+	builder.WriteString("  name: ")
+	builder.WriteString(name)
+	builder.WriteString("\n")
+	builder.WriteString("  labels:\n")
+	builder.WriteString("    app.kubernetes.io/name: ")
+	builder.WriteString(ResourceName(service.Name))
+	builder.WriteString("\n")
+	builder.WriteString("    app.kubernetes.io/part-of: ")
+	builder.WriteString(ResourceName(config.Name))
+	builder.WriteString("\n")
+	builder.WriteString("    app.kubernetes.io/managed-by: infra-gen\n")
+	if config.Environment != "" {
+		builder.WriteString("    environment: ")
+		builder.WriteString(config.Environment)
+		builder.WriteString("\n")
+	}
+}
+
+// volumeSize returns the storage a named volume requests: the largest size
+// any service gives it, or DefaultVolumeSize
+func volumeSize(config *types.ProjectConfig, source string) string {
+	size := 0
+	for _, service := range config.Services {
+		for _, volume := range service.Volumes {
+			if volume.Source != source || volume.Size == "" {
+				continue
+			}
+			if gib, err := sizing.ParseSize(volume.Size, "G"); err == nil {
+				size = max(size, gib)
+			}
+		}
+	}
+	if size == 0 {
+		return DefaultVolumeSize
+	}
+	return fmt.Sprintf("%dGi", size)
+}
+
+// splitEnvironment separates sensitive environment variables from plain ones
+func (g *Generator) splitEnvironment(environment map[string]string) (map[string]string, map[string]string) {
+	configData := make(map[string]string)
+	secretData := make(map[string]string)
+
+	for key, value := range environment {
+		upper := strings.ToUpper(key)
+		if strings.Contains(upper, "PASSWORD") ||
+			strings.Contains(upper, "SECRET") ||
+			strings.Contains(upper, "KEY") ||
+			strings.Contains(upper, "TOKEN") {
+			secretData[key] = value
+		} else {
+			configData[key] = value
+		}
+	}
+
+	return configData, secretData
+}
+
+func (g *Generator) file(name, content string) types.GeneratedFile {
+	return types.GeneratedFile{
+		Path:     filepath.Join("kubernetes", name),
+		Content:  content,
+		Type:     types.TargetKubernetes,
+		Encoding: "utf-8",
+	}
+}
+
+// ResourceName converts a name into a Kubernetes object name: lower case,
+// with underscores replaced by dashes
+func ResourceName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+}
+
+func volumeName(volume types.VolumeConfig, index int) string {
+	if volume.Type == "volume" {
+		return ResourceName(volume.Source)
+	}
+	return fmt.Sprintf("host-%d", index)
+}
+
+// writeResources writes the cpu and memory of a container's resource limits
+// or requests
+func writeResources(builder *strings.Builder, size sizing.Size) {
+	if size.CPU > 0 {
+		builder.WriteString("              cpu: \"")
+		builder.WriteString(sizing.KubernetesCPU(size.CPU))
+		builder.WriteString("\"\n")
+	}
+	if size.Memory > 0 {
+		builder.WriteString("              memory: ")
+		builder.WriteString(sizing.KubernetesMemory(size.Memory))
+		builder.WriteString("\n")
+	}
+}
+
+// expandPorts returns the ports with ranges expanded, as Kubernetes has no
+// port ranges
+func expandPorts(servicePorts []types.PortConfig) []types.PortConfig {
+	var expanded []types.PortConfig
+	for _, port := range servicePorts {
+		expanded = append(expanded, ports.Expand(port)...)
+	}
+	return expanded
+}
+
+// servicePorts returns the expanded ports a Service exposes, leaving out
+// ports with exposure none
+func servicePorts(service types.ServiceConfig) []types.PortConfig {
+	var exposed []types.PortConfig
+	for _, port := range expandPorts(service.Ports) {
+		if ports.Exposure(port) != ports.None {
+			exposed = append(exposed, port)
+		}
+	}
+	return exposed
+}
+
+// hasPublicPort reports whether a service has a public port to route
+// ingress traffic to
+func hasPublicPort(service types.ServiceConfig) bool {
+	for _, port := range service.Ports {
+		if ports.Exposure(port) == ports.Public {
+			return true
+		}
+	}
+	return false
+}
+
+// protocols maps the port protocols Kubernetes supports to its spelling
+var protocols = map[string]string{"": "TCP", "tcp": "TCP", "udp": "UDP", "sctp": "SCTP"}
+
+// Protocol returns a port protocol as Kubernetes spells it, defaulting to
+// TCP. Project validation refuses other protocols before generation.
+func Protocol(p string) (string, error) {
+	protocol, exists := protocols[p]
+	if !exists {
+		return "", fmt.Errorf("unsupported protocol '%s' for Kubernetes (use tcp, udp or sctp)", p)
+	}
+	return protocol, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,287 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+func volumeProject(volumes ...[]types.VolumeConfig) *types.ProjectConfig {
+	config := &types.ProjectConfig{Name: "demo", Type: "web-app"}
+	for i, serviceVolumes := range volumes {
+		config.Services = append(config.Services, types.ServiceConfig{
+			Name:    string(rune('a' + i)),
+			Type:    "api",
+			Image:   "app:1",
+			Volumes: serviceVolumes,
+			Enabled: true,
+		})
+	}
+	return config
+}
+
+func TestGenerate(t *testing.T) {
+	config := &types.ProjectConfig{
+		Name:      "demo",
+		Type:      "web-app",
+		Variables: map[string]string{"INGRESS_HOST": "shop.example.com"},
+		Services: []types.ServiceConfig{
+			{
+				Name:        "frontend",
+				Type:        "frontend",
+				Image:       "nginx:alpine",
+				Ports:       []types.PortConfig{{Host: 80, Container: 80}},
+				Environment: map[string]string{"API_URL": "http://api:8080"},
+				Enabled:     true,
+			},
+			{
+				Name:        "api",
+				Type:        "api",
+				Image:       "app:1",
+				Ports:       []types.PortConfig{{Host: 8080, Container: 8080}},
+				Environment: map[string]string{"LOG_LEVEL": "info", "DB_PASSWORD": "hunter22"},
+				Enabled:     true,
+			},
+			{
+				Name:    "worker",
+				Type:    "worker",
+				Image:   "worker:1",
+				Enabled: false,
+			},
+		},
+	}
+
+	files, err := NewGenerator().Generate(config)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	contents := make(map[string]string)
+	var paths []string
+	for _, file := range files {
+		contents[file.Path] = file.Content
+		paths = append(paths, file.Path)
+	}
+
+	want := []string{
+		"kubernetes/frontend-configmap.yaml",
+		"kubernetes/frontend-deployment.yaml",
+		"kubernetes/frontend-service.yaml",
+		"kubernetes/frontend-ingress.yaml",
+		"kubernetes/api-configmap.yaml",
+		"kubernetes/api-secret.yaml",
+		"kubernetes/api-deployment.yaml",
+		"kubernetes/api-service.yaml",
+	}
+	if got := strings.Join(paths, " "); got != strings.Join(want, " ") {
+		t.Errorf("generated %s\nwant %s", got, strings.Join(want, " "))
+	}
+
+	if !strings.Contains(contents["kubernetes/api-secret.yaml"], "DB_PASSWORD: "+base64.StdEncoding.EncodeToString([]byte("hunter22"))) {
+		t.Errorf("secret does not hold DB_PASSWORD base64 encoded:\n%s", contents["kubernetes/api-secret.yaml"])
+	}
+	if configMap := contents["kubernetes/api-configmap.yaml"]; strings.Contains(configMap, "DB_PASSWORD") || !strings.Contains(configMap, "LOG_LEVEL") {
+		t.Errorf("config map should hold LOG_LEVEL only:\n%s", configMap)
+	}
+	if deployment := contents["kubernetes/api-deployment.yaml"]; !strings.Contains(deployment, "name: api-config\n") || !strings.Contains(deployment, "name: api-secret\n") {
+		t.Errorf("deployment does not load its config map and secret:\n%s", deployment)
+	}
+
+	manifests := []struct {
+		path string
+		want []string
+	}{
+		{path: "kubernetes/api-deployment.yaml", want: []string{"kind: Deployment\n", "image: app:1\n", "containerPort: 8080\n", "protocol: TCP\n"}},
+		{path: "kubernetes/api-service.yaml", want: []string{"kind: Service\n", "type: ClusterIP\n", "name: tcp-8080\n", "port: 8080\n", "targetPort: 8080\n"}},
+		{path: "kubernetes/frontend-configmap.yaml", want: []string{"kind: ConfigMap\n", `API_URL: "http://api:8080"`}},
+		{path: "kubernetes/api-secret.yaml", want: []string{"kind: Secret\n", "type: Opaque\n"}},
+		{path: "kubernetes/frontend-ingress.yaml", want: []string{"kind: Ingress\n", "host: shop.example.com\n", "name: frontend\n", "number: 80\n"}},
+	}
+	for _, manifest := range manifests {
+		for _, want := range manifest.want {
+			if !strings.Contains(contents[manifest.path], want) {
+				t.Errorf("%s does not contain %q:\n%s", manifest.path, want, contents[manifest.path])
+			}
+		}
+	}
+}
+
+func TestGeneratePVC(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *types.ProjectConfig
+		claims  int
+		storage string
+	}{
+		{
+			name:    "default size",
+			config:  volumeProject([]types.VolumeConfig{{Source: "data", Target: "/d", Type: "volume"}}),
+			claims:  1,
+			storage: DefaultVolumeSize,
+		},
+		{
+			name: "shared volume gets one claim of the largest size",
+			config: volumeProject(
+				[]types.VolumeConfig{{Source: "data", Target: "/d", Type: "volume", Size: "5G"}},
+				[]types.VolumeConfig{{Source: "data", Target: "/d", Type: "volume", Size: "10G"}},
+			),
+			claims:  1,
+			storage: "10Gi",
+		},
+		{
+			name:   "bind mounts get no claim",
+			config: volumeProject([]types.VolumeConfig{{Source: "/srv/src", Target: "/src", Type: "bind"}}),
+			claims: 0,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files, err := NewGenerator().Generate(test.config)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+
+			seen := make(map[string]bool)
+			var claims []types.GeneratedFile
+			for _, file := range files {
+				if seen[file.Path] {
+					t.Errorf("%s generated twice", file.Path)
+				}
+				seen[file.Path] = true
+				if strings.HasSuffix(file.Path, "-pvc.yaml") {
+					claims = append(claims, file)
+				}
+			}
+
+			if len(claims) != test.claims {
+				t.Fatalf("generated %d claims, want %d", len(claims), test.claims)
+			}
+			if test.claims > 0 && !strings.Contains(claims[0].Content, "storage: "+test.storage+"\n") {
+				t.Errorf("claim does not request %s:\n%s", test.storage, claims[0].Content)
+			}
+		})
+	}
+}
+
+func TestVolumeSizeValidation(t *testing.T) {
+	config := volumeProject([]types.VolumeConfig{{Source: "/srv/src", Target: "/src", Type: "bind", Size: "5G"}})
+	err := NewGenerator().Validate(config)
+	if err == nil || !strings.Contains(err.Error(), "size only applies to volumes of type volume") {
+		t.Errorf("Validate() error = %v, want size rejected on bind mount", err)
+	}
+}
+
+func TestResourceName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: "", want: ""},
+		{in: "api", want: "api"},
+		{in: "My_Service", want: "my-service"},
+	}
+
+	for _, tt := range tests {
+		if got := ResourceName(tt.in); got != tt.want {
+			t.Errorf("ResourceName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestProtocol(t *testing.T) {
+	tests := []struct {
+		in, want, wantErr string
+	}{
+		{in: "", want: "TCP"},
+		{in: "tcp", want: "TCP"},
+		{in: "udp", want: "UDP"},
+		{in: "sctp", want: "SCTP"},
+		{in: "icmp", wantErr: "unsupported protocol 'icmp'"},
+		{in: "TCP", wantErr: "unsupported protocol 'TCP'"},
+	}
+
+	for _, tt := range tests {
+		got, err := Protocol(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Protocol(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Protocol(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestValidateProtocol(t *testing.T) {
+	config := volumeProject(nil)
+	config.Services[0].Ports = []types.PortConfig{{Container: 53, Protocol: "icmp"}}
+
+	_, err := NewGenerator().Generate(config)
+	if err == nil || !strings.Contains(err.Error(), "services[0].ports[0].protocol") {
+		t.Errorf("Generate() error = %v, want the protocol refused by validation", err)
+	}
+}
+
+func TestGenerateHPA(t *testing.T) {
+	tests := []struct {
+		name      string
+		service   types.ServiceConfig
+		replicas  string
+		hpa       []string
+		wantNoHPA bool
+	}{
+		{
+			name:      "fixed replicas",
+			service:   types.ServiceConfig{Replicas: 3},
+			replicas:  "replicas: 3\n",
+			wantNoHPA: true,
+		},
+		{
+			name:     "autoscaling range",
+			service:  types.ServiceConfig{Autoscaling: &types.AutoscalingConfig{Min: 2, Max: 8, TargetCPU: 70}},
+			replicas: "replicas: 2\n",
+			hpa:      []string{"minReplicas: 2\n", "maxReplicas: 8\n", "name: cpu\n", "averageUtilization: 70\n"},
+		},
+		{
+			name:     "memory target",
+			service:  types.ServiceConfig{Replicas: 4, Autoscaling: &types.AutoscalingConfig{Min: 1, Max: 6, TargetMemory: 80}},
+			replicas: "replicas: 4\n",
+			hpa:      []string{"minReplicas: 1\n", "maxReplicas: 6\n", "name: memory\n", "averageUtilization: 80\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := volumeProject(nil)
+			config.Services[0].Replicas = tt.service.Replicas
+			config.Services[0].Autoscaling = tt.service.Autoscaling
+
+			files, err := NewGenerator().Generate(config)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			contents := make(map[string]string)
+			for _, file := range files {
+				contents[file.Path] = file.Content
+			}
+
+			if deployment := contents["kubernetes/a-deployment.yaml"]; !strings.Contains(deployment, tt.replicas) {
+				t.Errorf("deployment does not contain %q:\n%s", tt.replicas, deployment)
+			}
+			hpa, exists := contents["kubernetes/a-hpa.yaml"]
+			if exists == tt.wantNoHPA {
+				t.Fatalf("HPA generated = %v, want %v", exists, !tt.wantNoHPA)
+			}
+			for _, want := range tt.hpa {
+				if !strings.Contains(hpa, want) {
+					t.Errorf("HPA does not contain %q:\n%s", want, hpa)
+				}
+			}
+			if strings.Contains(hpa, "name: cpu\n") && tt.service.Autoscaling.TargetCPU == 0 {
+				t.Errorf("HPA has a cpu metric without target_cpu:\n%s", hpa)
+			}
+		})
+	}
+}
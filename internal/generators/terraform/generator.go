@@ -2,13 +2,29 @@ package terraform
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/graph"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/health"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/interpolation"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/ports"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/scaling"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/secrets"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/sizing"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/validation"
 	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
 )
 
+// varReference matches a value that is exactly one Terraform variable reference
+var varReference = regexp.MustCompile(`^\$\{var\.([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
 // Generator implements Terraform HCL generation using only standard library
-type Generator struct{}
+type Generator struct {
+	resolver *interpolation.Resolver
+}
 
 // NewGenerator creates a new Terraform generator
 func NewGenerator() *Generator {
@@ -20,16 +36,42 @@ func (g *Generator) GetTarget() types.Target {
 	return types.TargetTerraform
 }
 
+// WithResolver sets the resolver used for ${VAR} references
+func (g *Generator) WithResolver(resolver *interpolation.Resolver) *Generator {
+	g.resolver = resolver
+	return g
+}
+
 // Generate generates Terraform files from project config
 func (g *Generator) Generate(config *types.ProjectConfig) ([]types.GeneratedFile, error) {
 	if err := g.Validate(config); err != nil {
 		return nil, err
 	}
 
+	// Resolve every ${VAR} reference to its value, but keep project variables
+	// as var.NAME references for database credentials
+	resolver := interpolation.ResolverFor(g.resolver, config)
+	native, err := resolver.Apply(config, interpolation.TerraformFormat)
+	if err != nil {
+		return nil, err
+	}
+	config, err = resolver.Apply(config, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate services in dependency order
+	if config.Services, err = graph.New(config).Order(); err != nil {
+		return nil, err
+	}
+	if native.Services, err = graph.New(native).Order(); err != nil {
+		return nil, err
+	}
+
 	files := []types.GeneratedFile{}
 
 	// Generate main.tf
-	mainContent := g.generateMainTF(config)
+	mainContent := g.generateMainTF(config, native)
 	files = append(files, types.GeneratedFile{
 		Path:     "main.tf",
 		Content:  mainContent,
@@ -38,7 +80,7 @@ func (g *Generator) Generate(config *types.ProjectConfig) ([]types.GeneratedFile
 	})
 
 	// Generate variables.tf
-	varsContent := g.generateVariablesTF(config)
+	varsContent := g.generateVariablesTF(config, native)
 	files = append(files, types.GeneratedFile{
 		Path:     "variables.tf",
 		Content:  varsContent,
@@ -71,25 +113,7 @@ func (g *Generator) Generate(config *types.ProjectConfig) ([]types.GeneratedFile
 
 // Validate validates the project config for Terraform generation
 func (g *Generator) Validate(config *types.ProjectConfig) error {
-	var errors types.ValidationErrors
-
-	if config.Name == "" {
-		errors.Add("name", "project name is required", config.Name)
-	}
-
-	if len(config.Services) == 0 {
-		errors.Add("services", "at least one service is required", len(config.Services))
-	}
-
-	// Validate each service
-	for i, service := range config.Services {
-		if service.Name == "" {
-			errors.Add(fmt.Sprintf("services[%d].name", i), "service name is required", service.Name)
-		}
-		if service.Type == "" {
-			errors.Add(fmt.Sprintf("services[%d].type", i), "service type is required", service.Type)
-		}
-	}
+	errors := validation.Project(config, interpolation.ResolverFor(g.resolver, config))
 
 	if errors.HasErrors() {
 		return errors
@@ -99,15 +123,29 @@ func (g *Generator) Validate(config *types.ProjectConfig) error {
 }
 
 // generateMainTF generates the main Terraform configuration
-func (g *Generator) generateMainTF(config *types.ProjectConfig) string {
+func (g *Generator) generateMainTF(config, native *types.ProjectConfig) string {
 	var builder strings.Builder
 
 	builder.WriteString("# Terraform configuration for ")
 	builder.WriteString(config.Name)
 	builder.WriteString("\n# Generated by infra-gen\n\n")
 
+	// Read external secrets from AWS Secrets Manager
+	for _, name := range secrets.Used(config) {
+		secret := config.Secrets[name]
+		if secrets.Source(secret) != secrets.External {
+			continue
+		}
+		builder.WriteString("data \"aws_secretsmanager_secret_version\" \"")
+		builder.WriteString(name)
+		builder.WriteString("\" {\n")
+		builder.WriteString("  secret_id = ")
+		builder.WriteString(hclString(secret.External))
+		builder.WriteString("\n}\n\n")
+	}
+
 	// Generate resources for each service
-	for _, service := range config.Services {
+	for i, service := range config.Services {
 		if !service.Enabled {
 			continue
 		}
@@ -116,39 +154,71 @@ func (g *Generator) generateMainTF(config *types.ProjectConfig) string {
 		case "web", "frontend", "nginx":
 			g.generateWebServer(&builder, service, config)
 		case "database", "postgres", "mysql":
-			g.generateDatabase(&builder, service, config)
+			g.generateDatabase(&builder, native.Services[i], config)
 		case "api", "backend":
 			g.generateAPIServer(&builder, service, config)
 		default:
 			g.generateGenericService(&builder, service, config)
 		}
+
+		if hasTargetGroup(service) {
+			g.generateTargetGroup(&builder, service)
+		}
 	}
 
 	return builder.String()
 }
 
 // generateVariablesTF generates Terraform variables
-func (g *Generator) generateVariablesTF(config *types.ProjectConfig) string {
+func (g *Generator) generateVariablesTF(config, native *types.ProjectConfig) string {
 	var builder strings.Builder
 
 	builder.WriteString("# Input variables\n")
 	builder.WriteString("variable \"project_name\" {\n")
 	builder.WriteString("  description = \"Name of the project\"\n")
 	builder.WriteString("  type        = string\n")
-	builder.WriteString("  default     = \"")
-	builder.WriteString(config.Name)
-	builder.WriteString("\"\n}\n\n")
+	builder.WriteString("  default     = ")
+	builder.WriteString(hclString(config.Name))
+	builder.WriteString("\n}\n\n")
 
 	builder.WriteString("variable \"environment\" {\n")
 	builder.WriteString("  description = \"Environment (dev, staging, prod)\"\n")
 	builder.WriteString("  type        = string\n")
-	builder.WriteString("  default     = \"")
-	if config.Environment != "" {
-		builder.WriteString(config.Environment)
-	} else {
-		builder.WriteString("dev")
+	environment := config.Environment
+	if environment == "" {
+		environment = "dev"
+	}
+	builder.WriteString("  default     = ")
+	builder.WriteString(hclString(environment))
+	builder.WriteString("\n}\n\n")
+
+	for _, service := range config.Services {
+		if service.Enabled && hasTargetGroup(service) {
+			builder.WriteString("variable \"vpc_id\" {\n")
+			builder.WriteString("  description = \"VPC of the load balancer target groups\"\n")
+			builder.WriteString("  type        = string\n")
+			builder.WriteString("}\n\n")
+			break
+		}
+	}
+
+	for _, service := range config.Services {
+		if service.Enabled && hasAutoScalingGroup(service) {
+			builder.WriteString("variable \"subnet_ids\" {\n")
+			builder.WriteString("  description = \"Subnets the Auto Scaling groups launch instances in\"\n")
+			builder.WriteString("  type        = list(string)\n")
+			builder.WriteString("}\n\n")
+			break
+		}
+	}
+
+	if hasInternalPorts(config) {
+		builder.WriteString("variable \"internal_cidr_blocks\" {\n")
+		builder.WriteString("  description = \"Networks allowed to reach internal ports\"\n")
+		builder.WriteString("  type        = list(string)\n")
+		builder.WriteString("  default     = [\"10.0.0.0/8\"]\n")
+		builder.WriteString("}\n\n")
 	}
-	builder.WriteString("\"\n}\n\n")
 
 	// Add service-specific variables
 	for _, service := range config.Services {
@@ -165,25 +235,71 @@ func (g *Generator) generateVariablesTF(config *types.ProjectConfig) string {
 		builder.WriteString("\"\n")
 		builder.WriteString("  type        = string\n")
 		if service.Image != "" {
-			builder.WriteString("  default     = \"")
-			builder.WriteString(service.Image)
-			builder.WriteString("\"\n")
+			builder.WriteString("  default     = ")
+			builder.WriteString(hclString(service.Image))
+			builder.WriteString("\n")
 		}
 		builder.WriteString("}\n\n")
 	}
 
-	// Add project variables
-	for key, value := range config.Variables {
+	// Add secrets, which are set outside of the configuration, for example
+	// with TF_VAR_<name>
+	for _, name := range secrets.Used(config) {
+		secret := config.Secrets[name]
+		if secrets.Source(secret) == secrets.External {
+			continue
+		}
+		description := secret.Description
+		if description == "" {
+			description = "Secret " + name
+		}
 		builder.WriteString("variable \"")
-		builder.WriteString(key)
+		builder.WriteString(name)
 		builder.WriteString("\" {\n")
-		builder.WriteString("  description = \"")
-		builder.WriteString(key)
-		builder.WriteString("\"\n")
+		builder.WriteString("  description = ")
+		builder.WriteString(hclString(description))
+		builder.WriteString("\n")
 		builder.WriteString("  type        = string\n")
-		builder.WriteString("  default     = \"")
-		builder.WriteString(value)
+		builder.WriteString("  sensitive   = true\n")
+		builder.WriteString("}\n\n")
+	}
+
+	// Add database passwords that are neither project variables nor secrets.
+	// They get no default, so the password never lands in this file.
+	for _, service := range native.Services {
+		if !service.Enabled || !isDatabase(service.Type) {
+			continue
+		}
+		password, exists := databaseCredential(service, "PASSWORD", native)
+		if exists && !strings.HasPrefix(password, "\"") {
+			continue
+		}
+
+		varName := strings.ReplaceAll(service.Name, "-", "_")
+		builder.WriteString("variable \"")
+		builder.WriteString(varName)
+		builder.WriteString("_password\" {\n")
+		builder.WriteString("  description = \"Master password for ")
+		builder.WriteString(service.Name)
 		builder.WriteString("\"\n")
+		builder.WriteString("  type        = string\n")
+		builder.WriteString("  sensitive   = true\n")
+		builder.WriteString("}\n\n")
+	}
+
+	// Add project variables, which hold no secrets: validation refuses
+	// secret-like names and names taken by secrets
+	for _, key := range sortedKeys(config.Variables) {
+		builder.WriteString("variable \"")
+		builder.WriteString(key)
+		builder.WriteString("\" {\n")
+		builder.WriteString("  description = ")
+		builder.WriteString(hclString(key))
+		builder.WriteString("\n")
+		builder.WriteString("  type        = string\n")
+		builder.WriteString("  default     = ")
+		builder.WriteString(hclString(config.Variables[key]))
+		builder.WriteString("\n")
 		builder.WriteString("}\n\n")
 	}
 
@@ -201,7 +317,20 @@ func (g *Generator) generateOutputsTF(config *types.ProjectConfig) string {
 			continue
 		}
 
-		if len(service.Ports) > 0 {
+		if hasAutoScalingGroup(service) {
+			// Replicas have no single address; report the group instead
+			varName := strings.ReplaceAll(service.Name, "-", "_")
+			builder.WriteString("output \"")
+			builder.WriteString(varName)
+			builder.WriteString("_autoscaling_group\" {\n")
+			builder.WriteString("  description = \"Auto Scaling group for ")
+			builder.WriteString(service.Name)
+			builder.WriteString("\"\n")
+			builder.WriteString("  value = aws_autoscaling_group.")
+			builder.WriteString(varName)
+			builder.WriteString(".name\n")
+			builder.WriteString("}\n\n")
+		} else if len(service.Ports) > 0 {
 			varName := strings.ReplaceAll(service.Name, "-", "_")
 			builder.WriteString("output \"")
 			builder.WriteString(varName)
@@ -256,24 +385,29 @@ func (g *Generator) generateWebServer(builder *strings.Builder, service types.Se
 	builder.WriteString("# Web Server: ")
 	builder.WriteString(service.Name)
 	builder.WriteString("\n")
-	builder.WriteString("resource \"aws_instance\" \"")
-	builder.WriteString(varName)
-	builder.WriteString("\" {\n")
-	builder.WriteString("  ami           = \"ami-0c55b159cbfafe1f0\" # Amazon Linux 2\n")
-	builder.WriteString("  instance_type = \"t3.micro\"\n")
-	builder.WriteString("  tags = {\n")
-	builder.WriteString("    Name        = \"")
-	builder.WriteString(service.Name)
-	builder.WriteString("\"\n")
-	builder.WriteString("    Project     = var.project_name\n")
-	builder.WriteString("    Environment = var.environment\n")
-	builder.WriteString("  }\n\n")
+	if hasAutoScalingGroup(service) {
+		g.generateAutoScalingGroup(builder, service, config, true)
+	} else {
+		builder.WriteString("resource \"aws_instance\" \"")
+		builder.WriteString(varName)
+		builder.WriteString("\" {\n")
+		builder.WriteString("  ami           = \"ami-0c55b159cbfafe1f0\" # Amazon Linux 2\n")
+		writeInstanceSize(builder, service)
+		builder.WriteString("  tags = {\n")
+		builder.WriteString("    Name        = \"")
+		builder.WriteString(service.Name)
+		builder.WriteString("\"\n")
+		builder.WriteString("    Project     = var.project_name\n")
+		builder.WriteString("    Environment = var.environment\n")
+		builder.WriteString("  }\n\n")
 
-	// Add security group for web server
-	builder.WriteString("  vpc_security_group_ids = [aws_security_group.")
-	builder.WriteString(varName)
-	builder.WriteString(".id]\n")
-	builder.WriteString("}\n\n")
+		// Add security group for web server
+		builder.WriteString("  vpc_security_group_ids = [aws_security_group.")
+		builder.WriteString(varName)
+		builder.WriteString(".id]\n")
+		writeDependsOn(builder, service, config)
+		builder.WriteString("}\n\n")
+	}
 
 	builder.WriteString("resource \"aws_security_group\" \"")
 	builder.WriteString(varName)
@@ -286,18 +420,7 @@ func (g *Generator) generateWebServer(builder *strings.Builder, service types.Se
 	builder.WriteString("\"\n\n")
 
 	// Add port rules
-	for _, port := range service.Ports {
-		builder.WriteString("  ingress {\n")
-		builder.WriteString("    from_port   = ")
-		builder.WriteString(fmt.Sprintf("%d", port.Container))
-		builder.WriteString("\n")
-		builder.WriteString("    to_port     = ")
-		builder.WriteString(fmt.Sprintf("%d", port.Container))
-		builder.WriteString("\n")
-		builder.WriteString("    protocol    = \"tcp\"\n")
-		builder.WriteString("    cidr_blocks = [\"0.0.0.0/0\"]\n")
-		builder.WriteString("  }\n\n")
-	}
+	writeIngress(builder, service)
 
 	builder.WriteString("  egress {\n")
 	builder.WriteString("    from_port   = 0\n")
@@ -318,6 +441,15 @@ func (g *Generator) generateWebServer(builder *strings.Builder, service types.Se
 func (g *Generator) generateDatabase(builder *strings.Builder, service types.ServiceConfig, config *types.ProjectConfig) {
 	varName := strings.ReplaceAll(service.Name, "-", "_")
 
+	username, exists := databaseCredential(service, "USER", config)
+	if !exists {
+		username = "\"admin\""
+	}
+	password, exists := databaseCredential(service, "PASSWORD", config)
+	if !exists || strings.HasPrefix(password, "\"") {
+		password = "var." + varName + "_password"
+	}
+
 	builder.WriteString("# Database: ")
 	builder.WriteString(service.Name)
 	builder.WriteString("\n")
@@ -328,13 +460,21 @@ func (g *Generator) generateDatabase(builder *strings.Builder, service types.Ser
 	builder.WriteString(service.Name)
 	builder.WriteString("\"\n")
 	builder.WriteString("  engine     = \"postgres\"\n")
-	builder.WriteString("  instance_class = \"db.t3.micro\"\n")
-	builder.WriteString("  allocated_storage = 20\n")
+	instanceClass, storage := "db.t3.micro", 20
+	if size, ok := sizing.Resolve(service.Resources); ok {
+		instanceClass, storage = sizing.DBInstanceClass(size), max(storage, size.Disk)
+	}
+	builder.WriteString("  instance_class = \"")
+	builder.WriteString(instanceClass)
+	builder.WriteString("\"\n")
+	builder.WriteString(fmt.Sprintf("  allocated_storage = %d\n", storage))
 	builder.WriteString("  engine_version = \"15.4\"\n")
-	builder.WriteString("  username   = \"admin\"\n")
-	builder.WriteString("  password   = var.")
-	builder.WriteString(varName)
-	builder.WriteString("_password\n")
+	builder.WriteString("  username   = ")
+	builder.WriteString(username)
+	builder.WriteString("\n")
+	builder.WriteString("  password   = ")
+	builder.WriteString(password)
+	builder.WriteString("\n")
 	builder.WriteString("  db_name  = \"")
 	builder.WriteString(service.Name)
 	builder.WriteString("\"\n")
@@ -346,6 +486,7 @@ func (g *Generator) generateDatabase(builder *strings.Builder, service types.Ser
 	builder.WriteString("    Project     = var.project_name\n")
 	builder.WriteString("    Environment = var.environment\n")
 	builder.WriteString("  }\n")
+	writeDependsOn(builder, service, config)
 	builder.WriteString("}\n\n")
 }
 
@@ -355,23 +496,28 @@ func (g *Generator) generateAPIServer(builder *strings.Builder, service types.Se
 	builder.WriteString("# API Server: ")
 	builder.WriteString(service.Name)
 	builder.WriteString("\n")
-	builder.WriteString("resource \"aws_instance\" \"")
-	builder.WriteString(varName)
-	builder.WriteString("\" {\n")
-	builder.WriteString("  ami           = \"ami-0c55b159cbfafe1f0\"\n")
-	builder.WriteString("  instance_type = \"t3.micro\"\n")
-	builder.WriteString("  tags = {\n")
-	builder.WriteString("    Name        = \"")
-	builder.WriteString(service.Name)
-	builder.WriteString("\"\n")
-	builder.WriteString("    Project     = var.project_name\n")
-	builder.WriteString("    Environment = var.environment\n")
-	builder.WriteString("  }\n\n")
+	if hasAutoScalingGroup(service) {
+		g.generateAutoScalingGroup(builder, service, config, true)
+	} else {
+		builder.WriteString("resource \"aws_instance\" \"")
+		builder.WriteString(varName)
+		builder.WriteString("\" {\n")
+		builder.WriteString("  ami           = \"ami-0c55b159cbfafe1f0\"\n")
+		writeInstanceSize(builder, service)
+		builder.WriteString("  tags = {\n")
+		builder.WriteString("    Name        = \"")
+		builder.WriteString(service.Name)
+		builder.WriteString("\"\n")
+		builder.WriteString("    Project     = var.project_name\n")
+		builder.WriteString("    Environment = var.environment\n")
+		builder.WriteString("  }\n\n")
 
-	builder.WriteString("  vpc_security_group_ids = [aws_security_group.")
-	builder.WriteString(varName)
-	builder.WriteString(".id]\n")
-	builder.WriteString("}\n\n")
+		builder.WriteString("  vpc_security_group_ids = [aws_security_group.")
+		builder.WriteString(varName)
+		builder.WriteString(".id]\n")
+		writeDependsOn(builder, service, config)
+		builder.WriteString("}\n\n")
+	}
 
 	builder.WriteString("resource \"aws_security_group\" \"")
 	builder.WriteString(varName)
@@ -384,18 +530,7 @@ func (g *Generator) generateAPIServer(builder *strings.Builder, service types.Se
 	builder.WriteString("\"\n\n")
 
 	// Add port rules
-	for _, port := range service.Ports {
-		builder.WriteString("  ingress {\n")
-		builder.WriteString("    from_port   = ")
-		builder.WriteString(fmt.Sprintf("%d", port.Container))
-		builder.WriteString("\n")
-		builder.WriteString("    to_port     = ")
-		builder.WriteString(fmt.Sprintf("%d", port.Container))
-		builder.WriteString("\n")
-		builder.WriteString("    protocol    = \"tcp\"\n")
-		builder.WriteString("    cidr_blocks = [\"0.0.0.0/0\"]\n")
-		builder.WriteString("  }\n\n")
-	}
+	writeIngress(builder, service)
 
 	builder.WriteString("  egress {\n")
 	builder.WriteString("    from_port   = 0\n")
@@ -419,17 +554,391 @@ func (g *Generator) generateGenericService(builder *strings.Builder, service typ
 	builder.WriteString("# Generic Service: ")
 	builder.WriteString(service.Name)
 	builder.WriteString("\n")
-	builder.WriteString("resource \"aws_instance\" \"")
+	if hasAutoScalingGroup(service) {
+		g.generateAutoScalingGroup(builder, service, config, false)
+	} else {
+		builder.WriteString("resource \"aws_instance\" \"")
+		builder.WriteString(varName)
+		builder.WriteString("\" {\n")
+		builder.WriteString("  ami           = \"ami-0c55b159cbfafe1f0\"\n")
+		writeInstanceSize(builder, service)
+		builder.WriteString("  tags = {\n")
+		builder.WriteString("    Name        = \"")
+		builder.WriteString(service.Name)
+		builder.WriteString("\"\n")
+		builder.WriteString("    Project     = var.project_name\n")
+		builder.WriteString("    Environment = var.environment\n")
+		builder.WriteString("  }\n")
+		writeDependsOn(builder, service, config)
+		builder.WriteString("}\n\n")
+	}
+}
+
+// generateAutoScalingGroup generates a launch template and an Auto Scaling
+// group running a service's replicas, with target tracking policies for its
+// autoscaling targets. The memory target relies on the CloudWatch agent
+// publishing mem_used_percent.
+func (g *Generator) generateAutoScalingGroup(builder *strings.Builder, service types.ServiceConfig, config *types.ProjectConfig, securityGroup bool) {
+	varName := strings.ReplaceAll(service.Name, "-", "_")
+	instanceType, size := instanceSize(service)
+
+	builder.WriteString("resource \"aws_launch_template\" \"")
+	builder.WriteString(varName)
+	builder.WriteString("\" {\n")
+	builder.WriteString("  name_prefix   = \"")
+	builder.WriteString(service.Name)
+	builder.WriteString("-\"\n")
+	builder.WriteString("  image_id      = \"ami-0c55b159cbfafe1f0\"\n")
+	builder.WriteString("  instance_type = \"")
+	builder.WriteString(instanceType)
+	builder.WriteString("\"\n")
+	if securityGroup {
+		builder.WriteString("  vpc_security_group_ids = [aws_security_group.")
+		builder.WriteString(varName)
+		builder.WriteString(".id]\n")
+	}
+	builder.WriteString("\n")
+	if size.Disk > 0 {
+		builder.WriteString("  block_device_mappings {\n")
+		builder.WriteString("    device_name = \"/dev/xvda\"\n")
+		builder.WriteString("    ebs {\n")
+		builder.WriteString(fmt.Sprintf("      volume_size = %d\n", size.Disk))
+		builder.WriteString("    }\n")
+		builder.WriteString("  }\n\n")
+	}
+	builder.WriteString("  tag_specifications {\n")
+	builder.WriteString("    resource_type = \"instance\"\n")
+	builder.WriteString("    tags = {\n")
+	builder.WriteString("      Name        = \"")
+	builder.WriteString(service.Name)
+	builder.WriteString("\"\n")
+	builder.WriteString("      Project     = var.project_name\n")
+	builder.WriteString("      Environment = var.environment\n")
+	builder.WriteString("    }\n")
+	builder.WriteString("  }\n")
+	builder.WriteString("}\n\n")
+
+	minSize, maxSize := scaling.Replicas(service), scaling.MaxReplicas(service)
+	if service.Autoscaling != nil {
+		minSize = service.Autoscaling.Min
+	}
+
+	builder.WriteString("resource \"aws_autoscaling_group\" \"")
+	builder.WriteString(varName)
+	builder.WriteString("\" {\n")
+	builder.WriteString("  name                = \"")
+	builder.WriteString(service.Name)
+	builder.WriteString("\"\n")
+	builder.WriteString(fmt.Sprintf("  min_size            = %d\n", minSize))
+	builder.WriteString(fmt.Sprintf("  max_size            = %d\n", maxSize))
+	builder.WriteString(fmt.Sprintf("  desired_capacity    = %d\n", scaling.Replicas(service)))
+	builder.WriteString("  vpc_zone_identifier = var.subnet_ids\n")
+	if hasTargetGroup(service) {
+		builder.WriteString("  target_group_arns   = [aws_lb_target_group.")
+		builder.WriteString(varName)
+		builder.WriteString(".arn]\n")
+		builder.WriteString("  health_check_type   = \"ELB\"\n")
+	}
+	builder.WriteString("\n")
+	builder.WriteString("  launch_template {\n")
+	builder.WriteString("    id      = aws_launch_template.")
+	builder.WriteString(varName)
+	builder.WriteString(".id\n")
+	builder.WriteString("    version = \"$Latest\"\n")
+	builder.WriteString("  }\n\n")
+	builder.WriteString("  tag {\n")
+	builder.WriteString("    key                 = \"Project\"\n")
+	builder.WriteString("    value               = var.project_name\n")
+	builder.WriteString("    propagate_at_launch = false\n")
+	builder.WriteString("  }\n")
+	writeDependsOn(builder, service, config)
+	builder.WriteString("}\n\n")
+
+	autoscaling := service.Autoscaling
+	if autoscaling == nil {
+		return
+	}
+	if autoscaling.TargetCPU > 0 {
+		writeScalingPolicy(builder, service, "cpu", autoscaling.TargetCPU,
+			"    predefined_metric_specification {\n"+
+				"      predefined_metric_type = \"ASGAverageCPUUtilization\"\n"+
+				"    }\n")
+	}
+	if autoscaling.TargetMemory > 0 {
+		writeScalingPolicy(builder, service, "memory", autoscaling.TargetMemory,
+			"    customized_metric_specification {\n"+
+				"      metric_name = \"mem_used_percent\"\n"+
+				"      namespace   = \"CWAgent\"\n"+
+				"      statistic   = \"Average\"\n\n"+
+				"      metric_dimension {\n"+
+				"        name  = \"AutoScalingGroupName\"\n"+
+				"        value = aws_autoscaling_group."+varName+".name\n"+
+				"      }\n"+
+				"    }\n")
+	}
+}
+
+// writeScalingPolicy writes a target tracking policy that keeps a metric of
+// a service's Auto Scaling group at target percent
+func writeScalingPolicy(builder *strings.Builder, service types.ServiceConfig, metric string, target int, specification string) {
+	varName := strings.ReplaceAll(service.Name, "-", "_")
+
+	builder.WriteString("resource \"aws_autoscaling_policy\" \"")
+	builder.WriteString(varName)
+	builder.WriteString("_")
+	builder.WriteString(metric)
+	builder.WriteString("\" {\n")
+	builder.WriteString("  name                   = \"")
+	builder.WriteString(service.Name)
+	builder.WriteString("-")
+	builder.WriteString(metric)
+	builder.WriteString("\"\n")
+	builder.WriteString("  autoscaling_group_name = aws_autoscaling_group.")
+	builder.WriteString(varName)
+	builder.WriteString(".name\n")
+	builder.WriteString("  policy_type            = \"TargetTrackingScaling\"\n\n")
+	builder.WriteString("  target_tracking_configuration {\n")
+	builder.WriteString(specification)
+	builder.WriteString(fmt.Sprintf("    target_value = %d\n", target))
+	builder.WriteString("  }\n")
+	builder.WriteString("}\n\n")
+}
+
+// generateTargetGroup generates a load balancer target group whose health
+// check follows the service's HTTP healthcheck, and registers the instance
+// with it; Auto Scaling groups register their own instances. Attach the
+// target group to a listener of your load balancer.
+func (g *Generator) generateTargetGroup(builder *strings.Builder, service types.ServiceConfig) {
+	varName := strings.ReplaceAll(service.Name, "-", "_")
+	check := service.Healthcheck
+
+	// Keep the settings within what target groups accept
+	interval := min(max(health.Seconds(health.Interval(check)), 5), 300)
+	timeout := min(max(health.Seconds(health.Timeout(check)), 2), 120, interval-1)
+	retries := min(max(health.Retries(check), 2), 10)
+
+	builder.WriteString("# Target group: ")
+	builder.WriteString(service.Name)
+	builder.WriteString("\n")
+	builder.WriteString("resource \"aws_lb_target_group\" \"")
 	builder.WriteString(varName)
 	builder.WriteString("\" {\n")
-	builder.WriteString("  ami           = \"ami-0c55b159cbfafe1f0\"\n")
-	builder.WriteString("  instance_type = \"t3.micro\"\n")
+	builder.WriteString("  name     = \"")
+	builder.WriteString(service.Name)
+	builder.WriteString("-tg\"\n")
+	builder.WriteString(fmt.Sprintf("  port     = %d\n", check.HTTP.Port))
+	builder.WriteString("  protocol = \"HTTP\"\n")
+	builder.WriteString("  vpc_id   = var.vpc_id\n\n")
+	builder.WriteString("  health_check {\n")
+	builder.WriteString("    path                = \"")
+	builder.WriteString(health.Path(check.HTTP))
+	builder.WriteString("\"\n")
+	builder.WriteString(fmt.Sprintf("    port                = \"%d\"\n", check.HTTP.Port))
+	builder.WriteString("    protocol            = \"HTTP\"\n")
+	builder.WriteString("    matcher             = \"200-399\"\n")
+	builder.WriteString(fmt.Sprintf("    interval            = %d\n", interval))
+	builder.WriteString(fmt.Sprintf("    timeout             = %d\n", timeout))
+	builder.WriteString("    healthy_threshold   = 2\n")
+	builder.WriteString(fmt.Sprintf("    unhealthy_threshold = %d\n", retries))
+	builder.WriteString("  }\n\n")
 	builder.WriteString("  tags = {\n")
 	builder.WriteString("    Name        = \"")
 	builder.WriteString(service.Name)
-	builder.WriteString("\"\n")
+	builder.WriteString("-tg\"\n")
 	builder.WriteString("    Project     = var.project_name\n")
-	builder.WriteString("    Environment = var.environment\n")
 	builder.WriteString("  }\n")
 	builder.WriteString("}\n\n")
+
+	if hasAutoScalingGroup(service) {
+		return
+	}
+	builder.WriteString("resource \"aws_lb_target_group_attachment\" \"")
+	builder.WriteString(varName)
+	builder.WriteString("\" {\n")
+	builder.WriteString("  target_group_arn = aws_lb_target_group.")
+	builder.WriteString(varName)
+	builder.WriteString(".arn\n")
+	builder.WriteString("  target_id        = aws_instance.")
+	builder.WriteString(varName)
+	builder.WriteString(".id\n")
+	builder.WriteString(fmt.Sprintf("  port             = %d\n", check.HTTP.Port))
+	builder.WriteString("}\n\n")
+}
+
+// hasTargetGroup reports whether a service is an instance with an HTTP
+// healthcheck, which load balancer target groups can probe
+func hasTargetGroup(service types.ServiceConfig) bool {
+	return !isDatabase(service.Type) && service.Healthcheck != nil && service.Healthcheck.HTTP != nil
+}
+
+// hasAutoScalingGroup reports whether a service runs its replicas in an Auto
+// Scaling group instead of a single instance
+func hasAutoScalingGroup(service types.ServiceConfig) bool {
+	return !isDatabase(service.Type) && scaling.Scaled(service)
+}
+
+// instanceSize returns the instance type that fits a service's resources,
+// t3.micro when it sets none, and its resolved size
+func instanceSize(service types.ServiceConfig) (string, sizing.Size) {
+	size, sized := sizing.Resolve(service.Resources)
+	if !sized {
+		return "t3.micro", size
+	}
+	return sizing.InstanceType(size), size
+}
+
+// writeInstanceSize writes the instance type that fits a service's resources
+// and the root volume size when it sets a disk
+func writeInstanceSize(builder *strings.Builder, service types.ServiceConfig) {
+	instanceType, size := instanceSize(service)
+	builder.WriteString("  instance_type = \"")
+	builder.WriteString(instanceType)
+	builder.WriteString("\"\n")
+
+	if size.Disk > 0 {
+		builder.WriteString("  root_block_device {\n")
+		builder.WriteString(fmt.Sprintf("    volume_size = %d\n", size.Disk))
+		builder.WriteString("  }\n")
+	}
+}
+
+// writeIngress writes the security group rules for a service's ports. Public
+// ports are open to everyone and internal ones to var.internal_cidr_blocks;
+// ports bound to a loopback address or not exposed get no rule.
+func writeIngress(builder *strings.Builder, service types.ServiceConfig) {
+	for _, port := range service.Ports {
+		var cidrBlocks string
+		switch ports.Exposure(port) {
+		case ports.Public:
+			if ports.Loopback(port) {
+				continue
+			}
+			cidrBlocks = "[\"0.0.0.0/0\"]"
+		case ports.Internal:
+			cidrBlocks = "var.internal_cidr_blocks"
+		default:
+			continue
+		}
+
+		builder.WriteString("  ingress {\n")
+		builder.WriteString("    from_port   = ")
+		builder.WriteString(fmt.Sprintf("%d", port.Container))
+		builder.WriteString("\n")
+		builder.WriteString("    to_port     = ")
+		builder.WriteString(fmt.Sprintf("%d", ports.ContainerEnd(port)))
+		builder.WriteString("\n")
+		builder.WriteString("    protocol    = \"")
+		builder.WriteString(ports.Protocol(port))
+		builder.WriteString("\"\n")
+		builder.WriteString("    cidr_blocks = ")
+		builder.WriteString(cidrBlocks)
+		builder.WriteString("\n")
+		builder.WriteString("  }\n\n")
+	}
+}
+
+// hasInternalPorts reports whether an enabled service has an internal port
+func hasInternalPorts(config *types.ProjectConfig) bool {
+	for _, service := range config.Services {
+		if !service.Enabled {
+			continue
+		}
+		for _, port := range service.Ports {
+			if ports.Exposure(port) == ports.Internal {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeDependsOn writes the depends_on argument of a service's resource,
+// pointing at the resources of the services it depends on
+func writeDependsOn(builder *strings.Builder, service types.ServiceConfig, config *types.ProjectConfig) {
+	if len(service.DependsOn) == 0 {
+		return
+	}
+
+	var resources []string
+	for _, dep := range service.DependsOn {
+		for _, other := range config.Services {
+			if other.Name != dep {
+				continue
+			}
+			resource := "aws_instance."
+			if isDatabase(other.Type) {
+				resource = "aws_db_instance."
+			} else if hasAutoScalingGroup(other) {
+				resource = "aws_autoscaling_group."
+			}
+			resources = append(resources, resource+strings.ReplaceAll(dep, "-", "_"))
+			break
+		}
+	}
+	if len(resources) == 0 {
+		return
+	}
+
+	builder.WriteString("\n  depends_on = [")
+	builder.WriteString(strings.Join(resources, ", "))
+	builder.WriteString("]\n")
+}
+
+// isDatabase reports whether a service type is generated as an RDS instance
+func isDatabase(serviceType string) bool {
+	switch serviceType {
+	case "database", "postgres", "mysql":
+		return true
+	}
+	return false
+}
+
+// databaseCredential returns the HCL expression for the first secret or
+// environment variable whose name contains suffix, e.g. POSTGRES_PASSWORD.
+// Secrets and values that are a single project variable reference become bare
+// references; other values are quoted literals.
+func databaseCredential(service types.ServiceConfig, suffix string, config *types.ProjectConfig) (string, bool) {
+	for _, ref := range service.Secrets {
+		if secret, exists := config.Secrets[ref.Name]; exists && strings.Contains(strings.ToUpper(ref.Env), suffix) {
+			return secretReference(ref.Name, secret), true
+		}
+	}
+
+	for _, key := range sortedKeys(service.Environment) {
+		if !strings.Contains(strings.ToUpper(key), suffix) {
+			continue
+		}
+		value := service.Environment[key]
+		if match := varReference.FindStringSubmatch(value); match != nil {
+			return "var." + match[1], true
+		}
+		return hclString(value), true
+	}
+
+	return "", false
+}
+
+// secretReference returns the HCL expression for the value of a secret
+func secretReference(name string, secret types.SecretConfig) string {
+	if secrets.Source(secret) == secrets.External {
+		return "data.aws_secretsmanager_secret_version." + name + ".secret_string"
+	}
+	return "var." + name
+}
+
+// hclString returns s as a quoted HCL string, with ${ and %{ escaped so
+// Terraform takes the value literally instead of as a template
+func hclString(s string) string {
+	quoted := strconv.Quote(s)
+	quoted = strings.ReplaceAll(quoted, "${", "$${")
+	return strings.ReplaceAll(quoted, "%{", "%%{")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
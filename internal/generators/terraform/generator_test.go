@@ -0,0 +1,69 @@
+package terraform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+func TestGenerateVariables(t *testing.T) {
+	config := &types.ProjectConfig{
+		Name: "demo",
+		Type: "web-app",
+		Variables: map[string]string{
+			"ZONE":     "eu-1",
+			"GREETING": `say "hi"`,
+			"TEMPLATE": "$${HOME} and %{ if x }",
+		},
+		Services: []types.ServiceConfig{
+			{Name: "api", Type: "api", Image: "app:1", Enabled: true},
+		},
+	}
+
+	files, err := NewGenerator().Generate(config)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	var variables string
+	for _, file := range files {
+		if file.Path == "variables.tf" {
+			variables = file.Content
+		}
+	}
+
+	for _, want := range []string{
+		`default     = "say \"hi\""`,
+		`default     = "$${HOME} and %%{ if x }"`,
+	} {
+		if !strings.Contains(variables, want) {
+			t.Errorf("variables.tf does not contain %s:\n%s", want, variables)
+		}
+	}
+	greeting := strings.Index(variables, `variable "GREETING"`)
+	template := strings.Index(variables, `variable "TEMPLATE"`)
+	zone := strings.Index(variables, `variable "ZONE"`)
+	if greeting < 0 || !(greeting < template && template < zone) {
+		t.Errorf("project variables not declared once each in sorted order:\n%s", variables)
+	}
+	if strings.Count(variables, `variable "ZONE"`) != 1 {
+		t.Errorf("ZONE declared more than once:\n%s", variables)
+	}
+}
+
+func TestHCLString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: "plain", want: `"plain"`},
+		{in: `a "quoted" \ value`, want: `"a \"quoted\" \\ value"`},
+		{in: "${var.x}", want: `"$${var.x}"`},
+		{in: "%{ for x in y }", want: `"%%{ for x in y }"`},
+		{in: "line\nbreak", want: `"line\nbreak"`},
+	}
+	for _, tt := range tests {
+		if got := hclString(tt.in); got != tt.want {
+			t.Errorf("hclString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
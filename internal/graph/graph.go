@@ -0,0 +1,148 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// Graph is the dependency graph of the services of a project, built from
+// their depends_on lists
+type Graph struct {
+	services []types.ServiceConfig
+	index    map[string]int
+}
+
+// New builds the dependency graph of a project
+func New(config *types.ProjectConfig) *Graph {
+	g := &Graph{
+		services: config.Services,
+		index:    make(map[string]int),
+	}
+	for i, service := range config.Services {
+		if _, exists := g.index[service.Name]; !exists {
+			g.index[service.Name] = i
+		}
+	}
+	return g
+}
+
+// Validate reports dependencies of enabled services on unknown or disabled
+// services, services that depend on themselves, and dependency cycles with
+// the path of each cycle
+func (g *Graph) Validate() types.ValidationErrors {
+	var errors types.ValidationErrors
+
+	for i, service := range g.services {
+		if !service.Enabled {
+			continue
+		}
+		for j, dep := range service.DependsOn {
+			field := fmt.Sprintf("services[%d].depends_on[%d]", i, j)
+			target, exists := g.index[dep]
+			switch {
+			case !exists:
+				errors.Add(field, fmt.Sprintf("service '%s' depends on unknown service '%s'", service.Name, dep), dep)
+			case dep == service.Name:
+				errors.Add(field, fmt.Sprintf("service '%s' depends on itself", service.Name), dep)
+			case !g.services[target].Enabled:
+				errors.Add(field, fmt.Sprintf("service '%s' depends on disabled service '%s'", service.Name, dep), dep)
+			}
+		}
+	}
+
+	for _, cycle := range g.cycles() {
+		errors.Add(fmt.Sprintf("services[%d].depends_on", g.index[cycle[0]]), fmt.Sprintf("dependency cycle: %s", strings.Join(cycle, " -> ")), cycle)
+	}
+
+	return errors
+}
+
+// Order returns the services so that every enabled service comes after the
+// services it depends on. Services without an ordering constraint, including
+// disabled ones, keep their order from the config. A cycle is returned as an
+// error.
+func (g *Graph) Order() ([]types.ServiceConfig, error) {
+	if cycles := g.cycles(); len(cycles) > 0 {
+		return nil, fmt.Errorf("dependency cycle: %s", strings.Join(cycles[0], " -> "))
+	}
+
+	var ordered []types.ServiceConfig
+	placed := make(map[string]bool)
+
+	var place func(i int)
+	place = func(i int) {
+		service := g.services[i]
+		if placed[service.Name] {
+			return
+		}
+		placed[service.Name] = true
+		if service.Enabled {
+			for _, dep := range service.DependsOn {
+				if target, exists := g.index[dep]; exists && g.services[target].Enabled {
+					place(target)
+				}
+			}
+		}
+		ordered = append(ordered, service)
+	}
+
+	for i := range g.services {
+		place(i)
+	}
+
+	return ordered, nil
+}
+
+// cycles returns each dependency cycle among enabled services once, as the
+// path of service names that leads back to its first service
+func (g *Graph) cycles() [][]string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+
+	state := make(map[string]int)
+	var stack []string
+	var cycles [][]string
+
+	var visit func(i int)
+	visit = func(i int) {
+		name := g.services[i].Name
+		state[name] = visiting
+		stack = append(stack, name)
+
+		for _, dep := range g.services[i].DependsOn {
+			target, exists := g.index[dep]
+			if !exists || dep == name || !g.services[target].Enabled {
+				continue
+			}
+			switch state[dep] {
+			case unvisited:
+				visit(target)
+			case visiting:
+				// The cycle is the part of the stack from dep onwards
+				for k := len(stack) - 1; k >= 0; k-- {
+					if stack[k] == dep {
+						cycle := append(append([]string{}, stack[k:]...), dep)
+						cycles = append(cycles, cycle)
+						break
+					}
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[name] = done
+	}
+
+	for i, service := range g.services {
+		if service.Enabled && state[service.Name] == unvisited {
+			visit(i)
+		}
+	}
+
+	return cycles
+}
@@ -0,0 +1,150 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// project builds a project from "name:dep,dep" specs; a leading "-" marks a
+// disabled service
+func project(specs ...string) *types.ProjectConfig {
+	config := &types.ProjectConfig{Name: "demo"}
+	for _, spec := range specs {
+		name, deps, _ := strings.Cut(spec, ":")
+		service := types.ServiceConfig{Name: strings.TrimPrefix(name, "-"), Enabled: !strings.HasPrefix(name, "-")}
+		if deps != "" {
+			service.DependsOn = strings.Split(deps, ",")
+		}
+		config.Services = append(config.Services, service)
+	}
+	return config
+}
+
+func names(services []types.ServiceConfig) string {
+	var names []string
+	for _, service := range services {
+		names = append(names, service.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []string
+		want  []string
+	}{
+		{
+			name:  "acyclic",
+			specs: []string{"web:api", "api:db,cache", "db", "cache"},
+		},
+		{
+			name:  "unknown dependency",
+			specs: []string{"api:queue"},
+			want:  []string{"services[0].depends_on[0]': service 'api' depends on unknown service 'queue'"},
+		},
+		{
+			name:  "self dependency",
+			specs: []string{"api:api"},
+			want:  []string{"services[0].depends_on[0]': service 'api' depends on itself"},
+		},
+		{
+			name:  "disabled dependency",
+			specs: []string{"api:db", "-db"},
+			want:  []string{"services[0].depends_on[0]': service 'api' depends on disabled service 'db'"},
+		},
+		{
+			name:  "disabled service may depend on anything",
+			specs: []string{"-api:queue"},
+		},
+		{
+			name:  "two-service cycle",
+			specs: []string{"api:db", "db:api"},
+			want:  []string{"services[0].depends_on': dependency cycle: api -> db -> api"},
+		},
+		{
+			name:  "cycle path starts at the service it returns to",
+			specs: []string{"web:api", "api:worker", "worker:cache", "cache:api"},
+			want:  []string{"services[1].depends_on': dependency cycle: api -> worker -> cache -> api"},
+		},
+		{
+			name:  "each cycle reported once",
+			specs: []string{"a:b", "b:a", "c:d", "d:c"},
+			want:  []string{"dependency cycle: a -> b -> a", "dependency cycle: c -> d -> c"},
+		},
+		{
+			name:  "cycle through a disabled service is broken",
+			specs: []string{"api:db", "-db:api"},
+			want:  []string{"depends on disabled service 'db'"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errors := New(project(tt.specs...)).Validate()
+			if len(errors) != len(tt.want) {
+				t.Errorf("Validate() reported %d errors, want %d: %v", len(errors), len(tt.want), errors)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(errors.Error(), want) {
+					t.Errorf("Validate() = %v, want %q", errors, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []string
+		want  string
+		err   string
+	}{
+		{
+			name:  "dependencies first",
+			specs: []string{"web:api", "api:db,cache", "db", "cache"},
+			want:  "db cache api web",
+		},
+		{
+			name:  "unconstrained services keep their order",
+			specs: []string{"b", "a", "c"},
+			want:  "b a c",
+		},
+		{
+			name:  "disabled services keep their place",
+			specs: []string{"-admin:api", "api:db", "db"},
+			want:  "admin db api",
+		},
+		{
+			name:  "unknown dependencies ignored",
+			specs: []string{"api:queue", "db"},
+			want:  "api db",
+		},
+		{
+			name:  "cycle",
+			specs: []string{"web:api", "api:db", "db:web"},
+			err:   "dependency cycle: web -> api -> db -> web",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ordered, err := New(project(tt.specs...)).Order()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Order() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Order() error = %v", err)
+			}
+			if got := names(ordered); got != tt.want {
+				t.Errorf("Order() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
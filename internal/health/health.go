@@ -0,0 +1,143 @@
+package health
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// Defaults for settings a healthcheck leaves out, matching Docker's own
+const (
+	DefaultInterval    = 30 * time.Second
+	DefaultTimeout     = 30 * time.Second
+	DefaultRetries     = 3
+	DefaultStartPeriod = 0
+)
+
+// Interval returns the time between two checks
+func Interval(check *types.HealthcheckConfig) time.Duration {
+	return duration(check.Interval, DefaultInterval)
+}
+
+// Timeout returns how long a single check may take
+func Timeout(check *types.HealthcheckConfig) time.Duration {
+	return duration(check.Timeout, DefaultTimeout)
+}
+
+// StartPeriod returns the grace period before failed checks count
+func StartPeriod(check *types.HealthcheckConfig) time.Duration {
+	return duration(check.StartPeriod, DefaultStartPeriod)
+}
+
+// Retries returns the number of consecutive failures that make a service
+// unhealthy
+func Retries(check *types.HealthcheckConfig) int {
+	if check.Retries > 0 {
+		return check.Retries
+	}
+	return DefaultRetries
+}
+
+// Deadline returns how long a service may take to become healthy
+func Deadline(check *types.HealthcheckConfig) time.Duration {
+	return StartPeriod(check) + time.Duration(Retries(check))*(Interval(check)+Timeout(check))
+}
+
+// Path returns the path of an HTTP probe, defaulting to /
+func Path(probe *types.HTTPProbe) string {
+	if probe.Path == "" {
+		return "/"
+	}
+	return probe.Path
+}
+
+// Port returns the container port an HTTP or TCP probe checks, or 0 for a
+// command
+func Port(check *types.HealthcheckConfig) int {
+	switch {
+	case check.HTTP != nil:
+		return check.HTTP.Port
+	case check.TCP != nil:
+		return check.TCP.Port
+	}
+	return 0
+}
+
+// ShellCommand returns a shell command that runs the check inside the
+// container and exits non-zero when it fails
+func ShellCommand(check *types.HealthcheckConfig) string {
+	switch {
+	case check.HTTP != nil:
+		return fmt.Sprintf("wget -q -O /dev/null http://localhost:%d%s || exit 1", check.HTTP.Port, Path(check.HTTP))
+	case check.TCP != nil:
+		return fmt.Sprintf("nc -z localhost %d || exit 1", check.TCP.Port)
+	}
+	return check.Command
+}
+
+// Seconds formats a duration as whole seconds, rounding up
+func Seconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// Validate reports healthchecks that do not set exactly one of command, http
+// and tcp, invalid durations and HTTP paths that do not start with /
+func Validate(config *types.ProjectConfig) types.ValidationErrors {
+	var errors types.ValidationErrors
+
+	for i, service := range config.Services {
+		check := service.Healthcheck
+		if check == nil {
+			continue
+		}
+		field := fmt.Sprintf("services[%d].healthcheck", i)
+
+		var probes []string
+		if check.Command != "" {
+			probes = append(probes, "command")
+		}
+		if check.HTTP != nil {
+			probes = append(probes, "http")
+		}
+		if check.TCP != nil {
+			probes = append(probes, "tcp")
+		}
+		switch len(probes) {
+		case 0:
+			errors.Add(field, "healthcheck needs one of command, http or tcp", nil)
+		case 1:
+		default:
+			errors.Add(field, fmt.Sprintf("healthcheck sets %s; use only one", strings.Join(probes, " and ")), probes)
+		}
+
+		if check.HTTP != nil && check.HTTP.Path != "" && !strings.HasPrefix(check.HTTP.Path, "/") {
+			errors.Add(field+".http.path", "path must start with /", check.HTTP.Path)
+		}
+
+		for _, setting := range []struct{ name, value string }{
+			{"interval", check.Interval},
+			{"timeout", check.Timeout},
+			{"start_period", check.StartPeriod},
+		} {
+			if setting.value == "" {
+				continue
+			}
+			d, err := time.ParseDuration(setting.value)
+			if err != nil || d < 0 || (d == 0 && setting.name != "start_period") {
+				errors.Add(field+"."+setting.name, fmt.Sprintf("'%s' is not a duration such as 10s or 1m30s", setting.value), setting.value)
+			}
+		}
+	}
+
+	return errors
+}
+
+// duration parses a validated duration, falling back to a default
+func duration(value string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(value); err == nil && value != "" {
+		return d
+	}
+	return fallback
+}
@@ -0,0 +1,137 @@
+package health
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+func TestShellCommand(t *testing.T) {
+	tests := []struct {
+		name  string
+		check types.HealthcheckConfig
+		want  string
+		port  int
+	}{
+		{
+			name:  "command",
+			check: types.HealthcheckConfig{Command: "pg_isready -U $POSTGRES_USER"},
+			want:  "pg_isready -U $POSTGRES_USER",
+		},
+		{
+			name:  "http with default path",
+			check: types.HealthcheckConfig{HTTP: &types.HTTPProbe{Port: 8080}},
+			want:  "wget -q -O /dev/null http://localhost:8080/ || exit 1",
+			port:  8080,
+		},
+		{
+			name:  "http path",
+			check: types.HealthcheckConfig{HTTP: &types.HTTPProbe{Port: 80, Path: "/healthz"}},
+			want:  "wget -q -O /dev/null http://localhost:80/healthz || exit 1",
+			port:  80,
+		},
+		{
+			name:  "tcp",
+			check: types.HealthcheckConfig{TCP: &types.TCPProbe{Port: 6379}},
+			want:  "nc -z localhost 6379 || exit 1",
+			port:  6379,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ShellCommand(&tt.check); got != tt.want {
+				t.Errorf("ShellCommand() = %q, want %q", got, tt.want)
+			}
+			if got := Port(&tt.check); got != tt.port {
+				t.Errorf("Port() = %d, want %d", got, tt.port)
+			}
+		})
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	tests := []struct {
+		name     string
+		check    types.HealthcheckConfig
+		deadline time.Duration
+	}{
+		{name: "defaults", check: types.HealthcheckConfig{Command: "true"}, deadline: 3 * time.Minute},
+		{
+			name:     "set",
+			check:    types.HealthcheckConfig{Command: "true", Interval: "10s", Timeout: "5s", Retries: 5, StartPeriod: "1m"},
+			deadline: time.Minute + 5*15*time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Deadline(&tt.check); got != tt.deadline {
+				t.Errorf("Deadline() = %v, want %v", got, tt.deadline)
+			}
+		})
+	}
+}
+
+func TestSeconds(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want int
+	}{
+		{in: 0, want: 0},
+		{in: 1500 * time.Millisecond, want: 2},
+		{in: 30 * time.Second, want: 30},
+	}
+	for _, tt := range tests {
+		if got := Seconds(tt.in); got != tt.want {
+			t.Errorf("Seconds(%v) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		check types.HealthcheckConfig
+		want  []string
+	}{
+		{name: "command", check: types.HealthcheckConfig{Command: "true", Interval: "10s", StartPeriod: "0s"}},
+		{name: "no probe", check: types.HealthcheckConfig{Interval: "10s"}, want: []string{"healthcheck needs one of command, http or tcp"}},
+		{
+			name:  "two probes",
+			check: types.HealthcheckConfig{Command: "true", TCP: &types.TCPProbe{Port: 80}},
+			want:  []string{"healthcheck sets command and tcp; use only one"},
+		},
+		{
+			name:  "relative path",
+			check: types.HealthcheckConfig{HTTP: &types.HTTPProbe{Port: 80, Path: "healthz"}},
+			want:  []string{"healthcheck.http.path': path must start with /"},
+		},
+		{
+			name:  "bad durations",
+			check: types.HealthcheckConfig{Command: "true", Interval: "10", Timeout: "0s", StartPeriod: "-1s"},
+			want: []string{
+				"healthcheck.interval': '10' is not a duration",
+				"healthcheck.timeout': '0s' is not a duration",
+				"healthcheck.start_period': '-1s' is not a duration",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &types.ProjectConfig{Services: []types.ServiceConfig{{Name: "api", Healthcheck: &tt.check}}}
+			errors := Validate(config)
+			if len(errors) != len(tt.want) {
+				t.Errorf("Validate() reported %d errors, want %d: %v", len(errors), len(tt.want), errors)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(errors.Error(), want) {
+					t.Errorf("Validate() = %v, want %q", errors, want)
+				}
+			}
+		})
+	}
+}
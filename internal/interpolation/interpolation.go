@@ -0,0 +1,349 @@
+package interpolation
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// reference matches $${...} escapes and ${NAME}, ${NAME:-default}, ${NAME:?error}
+var reference = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?:(:-|:\?)([^}]*))?\}`)
+
+// maxDepth bounds nested expansion of variables that reference other variables
+const maxDepth = 10
+
+// Reference is a single ${...} occurrence in a string
+type Reference struct {
+	Raw      string
+	Name     string
+	Default  string
+	Required bool
+	Message  string
+	hasValue bool
+}
+
+// HasDefault reports whether the reference carries a ${NAME:-default} fallback
+func (r Reference) HasDefault() bool {
+	return r.hasValue && !r.Required
+}
+
+// Format renders a reference as a native reference of a target
+type Format func(ref Reference) string
+
+// ComposeFormat keeps references as Docker Compose ${} interpolation
+func ComposeFormat(ref Reference) string {
+	return ref.Raw
+}
+
+// TerraformFormat renders references as Terraform string interpolation of var.NAME
+func TerraformFormat(ref Reference) string {
+	return "${var." + ref.Name + "}"
+}
+
+// AnsibleFormat renders references as Jinja2 expressions
+func AnsibleFormat(ref Reference) string {
+	if ref.HasDefault() {
+		return fmt.Sprintf("{{ %s | default('%s') }}", ref.Name, strings.ReplaceAll(ref.Default, "'", "\\'"))
+	}
+	return "{{ " + ref.Name + " }}"
+}
+
+// HelmFormat renders references as Helm template lookups of
+// .Values.variables, for chart values that templates render with tpl
+func HelmFormat(ref Reference) string {
+	lookup := fmt.Sprintf("(index .Values.variables %q)", ref.Name)
+	switch {
+	case ref.HasDefault():
+		return fmt.Sprintf("{{ %s | default %q }}", lookup, ref.Default)
+	case ref.Required:
+		return fmt.Sprintf("{{ required %q %s }}", requiredMessage(ref), lookup)
+	}
+	return "{{ " + lookup + " }}"
+}
+
+// Parse returns every reference in s, in order
+func Parse(s string) []Reference {
+	var refs []Reference
+	for _, match := range reference.FindAllStringSubmatch(s, -1) {
+		if match[0] == "$${" {
+			continue
+		}
+		refs = append(refs, newReference(match))
+	}
+	return refs
+}
+
+func newReference(match []string) Reference {
+	ref := Reference{Raw: match[0], Name: match[1]}
+	switch match[2] {
+	case ":-":
+		ref.Default = match[3]
+		ref.hasValue = true
+	case ":?":
+		ref.Required = true
+		ref.Message = match[3]
+		ref.hasValue = true
+	}
+	return ref
+}
+
+// Resolver resolves references against --set overrides, the process
+// environment and project variables, in that order of precedence
+type Resolver struct {
+	variables map[string]string
+	overrides map[string]string
+	lookupEnv func(string) (string, bool)
+}
+
+// NewResolver creates a resolver for the given project variables and overrides
+func NewResolver(variables, overrides map[string]string) *Resolver {
+	return &Resolver{
+		variables: variables,
+		overrides: overrides,
+		lookupEnv: os.LookupEnv,
+	}
+}
+
+// ResolverFor returns the resolver a generator was given, or, when it was
+// given none, one that resolves references against the project's variables
+// and the process environment
+func ResolverFor(resolver *Resolver, config *types.ProjectConfig) *Resolver {
+	if resolver != nil {
+		return resolver
+	}
+	return NewResolver(config.Variables, nil)
+}
+
+// IsVariable reports whether a name is a project variable or an override,
+// i.e. something a generator declares natively
+func (r *Resolver) IsVariable(name string) bool {
+	if _, exists := r.overrides[name]; exists {
+		return true
+	}
+	_, exists := r.variables[name]
+	return exists
+}
+
+// Lookup returns the fully expanded value of a name
+func (r *Resolver) Lookup(name string) (string, bool, error) {
+	return r.lookup(name, nil)
+}
+
+func (r *Resolver) lookup(name string, stack []string) (string, bool, error) {
+	for _, seen := range stack {
+		if seen == name {
+			return "", false, fmt.Errorf("variable reference cycle: %s -> %s", strings.Join(stack, " -> "), name)
+		}
+	}
+	if len(stack) >= maxDepth {
+		return "", false, fmt.Errorf("variable references nested deeper than %d levels", maxDepth)
+	}
+
+	value, found := r.overrides[name]
+	if !found {
+		value, found = r.lookupEnv(name)
+	}
+	if !found {
+		value, found = r.variables[name]
+	}
+	if !found {
+		return "", false, nil
+	}
+
+	expanded, err := r.expand(value, nil, append(stack, name))
+	return expanded, true, err
+}
+
+// Resolve replaces every reference in s with its value
+func (r *Resolver) Resolve(s string) (string, error) {
+	return r.expand(s, nil, nil)
+}
+
+// Passthrough renders references to project variables with the native
+// format of a target and resolves all other references
+func (r *Resolver) Passthrough(s string, format Format) (string, error) {
+	return r.expand(s, format, nil)
+}
+
+func (r *Resolver) expand(s string, format Format, stack []string) (string, error) {
+	var firstErr error
+
+	expanded := reference.ReplaceAllStringFunc(s, func(raw string) string {
+		if raw == "$${" {
+			if format != nil {
+				return raw
+			}
+			return "${"
+		}
+
+		ref := newReference(reference.FindStringSubmatch(raw))
+		if format != nil && r.IsVariable(ref.Name) {
+			return format(ref)
+		}
+
+		value, found, err := r.lookup(ref.Name, stack)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return raw
+		}
+		if found && (value != "" || !ref.hasValue) {
+			return value
+		}
+
+		switch {
+		case ref.HasDefault():
+			return ref.Default
+		case ref.Required:
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %s", ref.Name, requiredMessage(ref))
+			}
+		default:
+			if firstErr == nil {
+				firstErr = fmt.Errorf("unresolved variable reference %s", ref.Raw)
+			}
+		}
+		return raw
+	})
+
+	return expanded, firstErr
+}
+
+// Validate reports every reference in the project config that cannot be resolved
+func (r *Resolver) Validate(config *types.ProjectConfig) types.ValidationErrors {
+	var errors types.ValidationErrors
+
+	check := func(field, value string) {
+		if _, err := r.Resolve(value); err != nil {
+			errors.Add(field, err.Error(), value)
+		}
+	}
+
+	for _, key := range sortedKeys(config.Variables) {
+		check("variables."+key, config.Variables[key])
+	}
+
+	for i, service := range config.Services {
+		if !service.Enabled {
+			continue
+		}
+
+		prefix := fmt.Sprintf("services[%d]", i)
+		check(prefix+".image", service.Image)
+		for _, key := range sortedKeys(service.Environment) {
+			check(prefix+".environment."+key, service.Environment[key])
+		}
+		for j, volume := range service.Volumes {
+			check(fmt.Sprintf("%s.volumes[%d].source", prefix, j), volume.Source)
+			check(fmt.Sprintf("%s.volumes[%d].target", prefix, j), volume.Target)
+		}
+	}
+
+	return errors
+}
+
+// Variables returns project variables and overrides with their values resolved
+func (r *Resolver) Variables() (map[string]string, error) {
+	resolved := make(map[string]string)
+
+	names := make(map[string]bool)
+	for name := range r.variables {
+		names[name] = true
+	}
+	for name := range r.overrides {
+		names[name] = true
+	}
+
+	for name := range names {
+		value, _, err := r.lookup(name, nil)
+		if err != nil {
+			return nil, err
+		}
+		resolved[name] = value
+	}
+
+	return resolved, nil
+}
+
+// Apply returns a copy of the project config with references in images,
+// environment values and volumes of enabled services expanded. A nil format
+// resolves every reference; otherwise project variables are kept as native
+// references. Project variables in the copy are always resolved.
+func (r *Resolver) Apply(config *types.ProjectConfig, format Format) (*types.ProjectConfig, error) {
+	if errors := r.Validate(config); errors.HasErrors() {
+		return nil, errors
+	}
+
+	expand := func(s string) (string, error) {
+		if format == nil {
+			return r.Resolve(s)
+		}
+		return r.Passthrough(s, format)
+	}
+
+	result := *config
+
+	variables, err := r.Variables()
+	if err != nil {
+		return nil, err
+	}
+	result.Variables = variables
+
+	result.Services = make([]types.ServiceConfig, len(config.Services))
+	for i, service := range config.Services {
+		copied := service
+		if !service.Enabled {
+			result.Services[i] = copied
+			continue
+		}
+
+		if copied.Image, err = expand(service.Image); err != nil {
+			return nil, fmt.Errorf("service %s: %w", service.Name, err)
+		}
+
+		if service.Environment != nil {
+			copied.Environment = make(map[string]string)
+			for key, value := range service.Environment {
+				if copied.Environment[key], err = expand(value); err != nil {
+					return nil, fmt.Errorf("service %s: %w", service.Name, err)
+				}
+			}
+		}
+
+		copied.Volumes = make([]types.VolumeConfig, len(service.Volumes))
+		for j, volume := range service.Volumes {
+			copied.Volumes[j] = volume
+			if copied.Volumes[j].Source, err = expand(volume.Source); err != nil {
+				return nil, fmt.Errorf("service %s: %w", service.Name, err)
+			}
+			if copied.Volumes[j].Target, err = expand(volume.Target); err != nil {
+				return nil, fmt.Errorf("service %s: %w", service.Name, err)
+			}
+		}
+
+		result.Services[i] = copied
+	}
+
+	return &result, nil
+}
+
+func requiredMessage(ref Reference) string {
+	if ref.Message != "" {
+		return ref.Message
+	}
+	return "required variable is not set"
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
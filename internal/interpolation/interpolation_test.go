@@ -0,0 +1,104 @@
+package interpolation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// newTestResolver creates a resolver that sees env instead of the process
+// environment
+func newTestResolver(variables, overrides, env map[string]string) *Resolver {
+	resolver := NewResolver(variables, overrides)
+	resolver.lookupEnv = func(name string) (string, bool) {
+		value, found := env[name]
+		return value, found
+	}
+	return resolver
+}
+
+func TestResolve(t *testing.T) {
+	variables := map[string]string{
+		"HOST":  "db",
+		"URL":   "postgres://${HOST}:${PORT:-5432}",
+		"EMPTY": "",
+		"LOOP":  "${LOOP}",
+	}
+	overrides := map[string]string{"TAG": "2.0"}
+	env := map[string]string{"TAG": "1.0", "HOST": "env-db"}
+
+	tests := []struct {
+		input string
+		want  string
+		err   string
+	}{
+		{input: "plain", want: "plain"},
+		{input: "app:${TAG}", want: "app:2.0"},
+		{input: "${HOST}", want: "env-db"},
+		{input: "${URL}", want: "postgres://env-db:5432"},
+		{input: "${EMPTY:-fallback}", want: "fallback"},
+		{input: "${EMPTY}", want: ""},
+		{input: "$${HOST}", want: "${HOST}"},
+		{input: "${MISSING}", err: "unresolved variable reference ${MISSING}"},
+		{input: "${MISSING:?set it}", err: "MISSING: set it"},
+		{input: "${LOOP}", err: "variable reference cycle: LOOP -> LOOP"},
+	}
+
+	resolver := newTestResolver(variables, overrides, env)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := resolver.Resolve(tt.input)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Resolve(%q) error = %v, want %q", tt.input, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q) error = %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPassthrough(t *testing.T) {
+	resolver := newTestResolver(map[string]string{"TAG": "1.0"}, nil, map[string]string{"HOME": "/root"})
+
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{ComposeFormat, "app:${TAG:-latest} /root"},
+		{TerraformFormat, "app:${var.TAG} /root"},
+		{AnsibleFormat, "app:{{ TAG | default('latest') }} /root"},
+		{HelmFormat, `app:{{ (index .Values.variables "TAG") | default "latest" }} /root`},
+	}
+
+	for _, tt := range tests {
+		got, err := resolver.Passthrough("app:${TAG:-latest} ${HOME:-/tmp}", tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Passthrough() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestResolverFor(t *testing.T) {
+	config := &types.ProjectConfig{Variables: map[string]string{"TAG": "1.0"}}
+
+	given := NewResolver(nil, map[string]string{"TAG": "2.0"})
+	if got := ResolverFor(given, config); got != given {
+		t.Errorf("ResolverFor() did not return the given resolver")
+	}
+
+	value, found, err := ResolverFor(nil, config).Lookup("TAG")
+	if err != nil || !found || value != "1.0" {
+		t.Errorf("Lookup(TAG) = %q, %v, %v, want project variable 1.0", value, found, err)
+	}
+}
@@ -0,0 +1,175 @@
+package migrations
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the newest infra-gen.yml schema_version this build understands
+const CurrentVersion = 2
+
+// LegacyVersion is assumed for configs that have no schema_version
+const LegacyVersion = 1
+
+// Migration upgrades a project document from one schema version to the next
+type Migration struct {
+	From        int
+	Description string
+	Apply       func(document *yaml.Node) error
+}
+
+// registry holds every migration, ordered by the version it upgrades from
+var registry = []Migration{
+	{
+		From:        1,
+		Description: "services without 'enabled' are enabled; state enabled: true explicitly",
+		Apply:       enableImplicitServices,
+	},
+}
+
+// Registry returns the registered migrations in order
+func Registry() []Migration {
+	return append([]Migration{}, registry...)
+}
+
+// TooNewError reports a config written for a newer infra-gen
+type TooNewError struct {
+	File    string
+	Version int
+}
+
+func (e *TooNewError) Error() string {
+	return fmt.Sprintf("%s uses schema_version %d, but this infra-gen only understands up to %d; upgrade infra-gen to a release that supports schema_version %d", e.File, e.Version, CurrentVersion, e.Version)
+}
+
+// Version returns the schema_version of a project document, or LegacyVersion
+// when it has none
+func Version(document *yaml.Node) (int, error) {
+	value := mappingValue(document, "schema_version")
+	if value == nil {
+		return LegacyVersion, nil
+	}
+
+	version, err := strconv.Atoi(value.Value)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("line %d: schema_version must be a positive integer, got %q", value.Line, value.Value)
+	}
+	return version, nil
+}
+
+// Upgrade migrates a project document in place to CurrentVersion and returns
+// the migrations it applied. Documents newer than CurrentVersion are refused
+// with a *TooNewError.
+func Upgrade(document *yaml.Node, file string) ([]Migration, error) {
+	version, err := Version(document)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if version > CurrentVersion {
+		return nil, &TooNewError{File: file, Version: version}
+	}
+
+	var applied []Migration
+	for _, migration := range registry {
+		if migration.From < version {
+			continue
+		}
+		if err := migration.Apply(document); err != nil {
+			return nil, fmt.Errorf("%s: migrating from schema_version %d: %w", file, migration.From, err)
+		}
+		applied = append(applied, migration)
+		version = migration.From + 1
+	}
+
+	if len(applied) > 0 {
+		setVersion(document, version)
+	}
+
+	return applied, nil
+}
+
+// enableImplicitServices adds enabled: true to top-level services that do
+// not state enabled, which keeps their meaning under the loader's default
+func enableImplicitServices(document *yaml.Node) error {
+	services := mappingValue(document, "services")
+	if services == nil {
+		return nil
+	}
+	if services.Kind != yaml.SequenceNode {
+		return fmt.Errorf("line %d: services must be a list", services.Line)
+	}
+
+	for _, service := range services.Content {
+		if service.Kind == yaml.MappingNode && mappingValue(service, "enabled") == nil {
+			service.Content = append(service.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "enabled"},
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"},
+			)
+		}
+	}
+
+	return nil
+}
+
+// setVersion sets schema_version, adding it as the first key when missing
+func setVersion(document *yaml.Node, version int) {
+	if value := mappingValue(document, "schema_version"); value != nil {
+		value.Value = strconv.Itoa(version)
+		value.Tag = "!!int"
+		return
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "schema_version"}
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(version)}
+
+	// Keep a leading file comment above the new first key
+	if len(document.Content) > 0 {
+		key.HeadComment = document.Content[0].HeadComment
+		document.Content[0].HeadComment = ""
+	}
+
+	document.Content = append([]*yaml.Node{key, value}, document.Content...)
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// DetectIndent returns the indentation width used by a YAML file, or 2
+func DetectIndent(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || len(trimmed) == len(line) {
+			continue
+		}
+		return len(line) - len(trimmed)
+	}
+	return 2
+}
+
+// Encode renders a YAML document with the given indentation, keeping comments
+func Encode(root *yaml.Node, indent int) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(indent)
+	if err := encoder.Encode(root); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,119 @@
+package migrations
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func parse(t *testing.T, text string) (*yaml.Node, *yaml.Node) {
+	t.Helper()
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &root); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return &root, root.Content[0]
+}
+
+func TestVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    int
+		wantErr bool
+	}{
+		{"missing", "name: x\n", LegacyVersion, false},
+		{"explicit", "schema_version: 2\n", 2, false},
+		{"zero", "schema_version: 0\n", 0, true},
+		{"not a number", "schema_version: two\n", 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, document := parse(t, test.text)
+			got, err := Version(document)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Version() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("Version() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		want        string
+		wantApplied int
+	}{
+		{
+			name:        "unversioned services stay enabled",
+			text:        "name: x\nservices:\n  - name: api\n    type: api\n",
+			want:        "schema_version: 2\nname: x\nservices:\n  - name: api\n    type: api\n    enabled: true\n",
+			wantApplied: 1,
+		},
+		{
+			name:        "explicit enabled is kept",
+			text:        "name: x\nservices:\n  - name: api\n    enabled: false\n",
+			want:        "schema_version: 2\nname: x\nservices:\n  - name: api\n    enabled: false\n",
+			wantApplied: 1,
+		},
+		{
+			name: "current version is untouched",
+			text: "schema_version: 2\nname: x\nservices:\n  - name: api\n",
+			want: "schema_version: 2\nname: x\nservices:\n  - name: api\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, document := parse(t, test.text)
+			applied, err := Upgrade(document, "infra-gen.yml")
+			if err != nil {
+				t.Fatalf("Upgrade() error = %v", err)
+			}
+			if len(applied) != test.wantApplied {
+				t.Errorf("Upgrade() applied %d migrations, want %d", len(applied), test.wantApplied)
+			}
+			got, err := Encode(root, 2)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if string(got) != test.want {
+				t.Errorf("Upgrade() =\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUpgradeTooNew(t *testing.T) {
+	_, document := parse(t, "schema_version: 99\n")
+	_, err := Upgrade(document, "infra-gen.yml")
+
+	var tooNew *TooNewError
+	if !errors.As(err, &tooNew) || tooNew.Version != 99 {
+		t.Fatalf("Upgrade() error = %v, want *TooNewError for version 99", err)
+	}
+	if !strings.Contains(err.Error(), "upgrade infra-gen") {
+		t.Errorf("error %q does not ask to upgrade infra-gen", err)
+	}
+}
+
+func TestDetectIndent(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"a:\n  b: 1\n", 2},
+		{"# comment\na:\n    b: 1\n", 4},
+		{"a: 1\n", 2},
+	}
+	for _, test := range tests {
+		if got := DetectIndent([]byte(test.text)); got != test.want {
+			t.Errorf("DetectIndent(%q) = %d, want %d", test.text, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package ports
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// DefaultProtocol is used for ports that do not state a protocol
+const DefaultProtocol = "tcp"
+
+// Exposure levels of a port
+const (
+	// Public ports are published on the host and reachable from anywhere
+	Public = "public"
+	// Internal ports are reachable from the project's own network only
+	Internal = "internal"
+	// None keeps a port private to its container
+	None = "none"
+)
+
+// protocols are the supported port protocols
+var protocols = []string{"tcp", "udp"}
+
+// exposures are the supported exposure levels
+var exposures = []string{Public, Internal, None}
+
+// Protocol returns the protocol of a port, defaulting to tcp
+func Protocol(port types.PortConfig) string {
+	if port.Protocol == "" {
+		return DefaultProtocol
+	}
+	return port.Protocol
+}
+
+// Exposure returns the exposure level of a port, defaulting to public
+func Exposure(port types.PortConfig) string {
+	if port.Exposure == "" {
+		return Public
+	}
+	return port.Exposure
+}
+
+// ContainerEnd returns the last container port of a port or range
+func ContainerEnd(port types.PortConfig) int {
+	if port.ContainerEnd > 0 {
+		return port.ContainerEnd
+	}
+	return port.Container
+}
+
+// HostEnd returns the last host port of a port or range, or 0 when no host
+// port is set. A host range without an end has the size of the container range.
+func HostEnd(port types.PortConfig) int {
+	if port.Host == 0 {
+		return 0
+	}
+	if port.HostEnd > 0 {
+		return port.HostEnd
+	}
+	return port.Host + ContainerEnd(port) - port.Container
+}
+
+// Loopback reports whether a port is only bound to a loopback address, so it
+// cannot be reached from other machines
+func Loopback(port types.PortConfig) bool {
+	ip := net.ParseIP(port.HostIP)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Mapping returns a port in the short [host_ip:][host:]container[/protocol]
+// syntax used by Docker Compose ports. The protocol suffix is left out for tcp.
+func Mapping(port types.PortConfig) string {
+	mapping := Span(port.Container, ContainerEnd(port))
+	if port.Host > 0 {
+		mapping = Span(port.Host, HostEnd(port)) + ":" + mapping
+	}
+	if port.HostIP != "" {
+		hostIP := port.HostIP
+		if strings.Contains(hostIP, ":") {
+			hostIP = "[" + hostIP + "]"
+		}
+		if port.Host == 0 {
+			// An address without a host port needs an empty host port
+			hostIP += ":"
+		}
+		mapping = hostIP + ":" + mapping
+	}
+	return mapping + protocolSuffix(port)
+}
+
+// Expose returns the container port or range in the container[/protocol]
+// syntax used by Docker Compose expose
+func Expose(port types.PortConfig) string {
+	return Span(port.Container, ContainerEnd(port)) + protocolSuffix(port)
+}
+
+// Span formats a port range as from-to, or a single port as from
+func Span(from, to int) string {
+	if to > from {
+		return fmt.Sprintf("%d-%d", from, to)
+	}
+	return fmt.Sprintf("%d", from)
+}
+
+// Expand returns one port per container port of a range, for targets that
+// have no port ranges
+func Expand(port types.PortConfig) []types.PortConfig {
+	var expanded []types.PortConfig
+	for offset := 0; port.Container+offset <= ContainerEnd(port); offset++ {
+		single := port
+		single.Container = port.Container + offset
+		single.ContainerEnd = 0
+		if port.Host > 0 {
+			single.Host = port.Host + offset
+		}
+		single.HostEnd = 0
+		expanded = append(expanded, single)
+	}
+	return expanded
+}
+
+func protocolSuffix(port types.PortConfig) string {
+	if protocol := Protocol(port); protocol != DefaultProtocol {
+		return "/" + protocol
+	}
+	return ""
+}
+
+// Validate reports port numbers outside 1-65535 and unsupported protocols and
+// exposure levels. Bindings and Conflicts check how ports are published.
+func Validate(config *types.ProjectConfig) types.ValidationErrors {
+	var errors types.ValidationErrors
+
+	for i, service := range config.Services {
+		for j, port := range service.Ports {
+			field := fmt.Sprintf("services[%d].ports[%d]", i, j)
+			checkNumber(&errors, field+".container", port.Container)
+			checkNumber(&errors, field+".container_end", port.ContainerEnd)
+			checkNumber(&errors, field+".host", port.Host)
+			checkNumber(&errors, field+".host_end", port.HostEnd)
+			if !oneOf(port.Protocol, protocols) {
+				errors.Add(field+".protocol", fmt.Sprintf("unsupported protocol '%s' (use %s)", port.Protocol, strings.Join(protocols, " or ")), port.Protocol)
+			}
+			if !oneOf(port.Exposure, exposures) {
+				errors.Add(field+".exposure", fmt.Sprintf("unsupported exposure '%s' (use %s)", port.Exposure, strings.Join(exposures, ", ")), port.Exposure)
+			}
+		}
+	}
+
+	return errors
+}
+
+// Bindings reports ranges that end before they start, host ranges whose size
+// differs from the container range, invalid host IPs and host bindings of
+// ports that are not public
+func Bindings(config *types.ProjectConfig) types.ValidationErrors {
+	var errors types.ValidationErrors
+
+	for i, service := range config.Services {
+		for j, port := range service.Ports {
+			field := fmt.Sprintf("services[%d].ports[%d]", i, j)
+
+			if port.ContainerEnd > 0 && port.ContainerEnd < port.Container {
+				errors.Add(field+".container_end", fmt.Sprintf("range end %d is before its start %d", port.ContainerEnd, port.Container), port.ContainerEnd)
+			}
+			switch {
+			case port.HostEnd > 0 && port.Host == 0:
+				errors.Add(field+".host_end", "host_end requires host", port.HostEnd)
+			case port.HostEnd > 0 && port.HostEnd < port.Host:
+				errors.Add(field+".host_end", fmt.Sprintf("range end %d is before its start %d", port.HostEnd, port.Host), port.HostEnd)
+			case port.HostEnd > 0 && port.HostEnd-port.Host != ContainerEnd(port)-port.Container:
+				errors.Add(field+".host_end", fmt.Sprintf("host range %s must have as many ports as container range %s", Span(port.Host, port.HostEnd), Span(port.Container, ContainerEnd(port))), port.HostEnd)
+			}
+
+			if port.HostIP != "" && net.ParseIP(port.HostIP) == nil {
+				errors.Add(field+".host_ip", fmt.Sprintf("'%s' is not an IP address", port.HostIP), port.HostIP)
+			}
+
+			if exposure := Exposure(port); exposure != Public {
+				if port.Host > 0 {
+					errors.Add(field+".host", fmt.Sprintf("only public ports are published on a host port, this one is %s", exposure), port.Host)
+				}
+				if port.HostIP != "" {
+					errors.Add(field+".host_ip", fmt.Sprintf("only public ports are bound to a host IP, this one is %s", exposure), port.HostIP)
+				}
+			}
+		}
+	}
+
+	return errors
+}
+
+// binding is a host port range published by a service
+type binding struct {
+	service  string
+	ip       string
+	protocol string
+	from, to int
+}
+
+// overlaps reports whether two bindings claim a common host port. A binding
+// without a specific address claims the port on every address.
+func (b binding) overlaps(other binding) bool {
+	if b.protocol != other.protocol || b.to < other.from || other.to < b.from {
+		return false
+	}
+	return b.ip == other.ip || unspecified(b.ip) || unspecified(other.ip)
+}
+
+// Conflicts reports host ports that more than one enabled public port
+// publishes with the same protocol and an overlapping address
+func Conflicts(config *types.ProjectConfig) types.ValidationErrors {
+	var errors types.ValidationErrors
+
+	var published []binding
+	for i, service := range config.Services {
+		if !service.Enabled {
+			continue
+		}
+		for j, port := range service.Ports {
+			if port.Host < 1 || HostEnd(port) > 65535 || Exposure(port) != Public || !oneOf(port.Protocol, protocols) {
+				continue
+			}
+
+			current := binding{service.Name, port.HostIP, Protocol(port), port.Host, HostEnd(port)}
+			for _, other := range published {
+				if current.overlaps(other) {
+					errors.Add(fmt.Sprintf("services[%d].ports[%d].host", i, j), fmt.Sprintf("host port %s/%s is already published by service '%s'", Span(current.from, current.to), current.protocol, other.service), port.Host)
+					break
+				}
+			}
+			published = append(published, current)
+		}
+	}
+
+	return errors
+}
+
+func checkNumber(errors *types.ValidationErrors, field string, number int) {
+	if number < 0 || number > 65535 || (number == 0 && strings.HasSuffix(field, ".container")) {
+		errors.Add(field, "must be between 1 and 65535", number)
+	}
+}
+
+func unspecified(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return ip == "" || (parsed != nil && parsed.IsUnspecified())
+}
+
+// oneOf reports whether value is empty, meaning the default, or in allowed
+func oneOf(value string, allowed []string) bool {
+	if value == "" {
+		return true
+	}
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+package ports
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// project builds a project with one enabled service per port list
+func project(servicePorts ...[]types.PortConfig) *types.ProjectConfig {
+	config := &types.ProjectConfig{Name: "demo"}
+	for i, ports := range servicePorts {
+		config.Services = append(config.Services, types.ServiceConfig{
+			Name:    string(rune('a' + i)),
+			Ports:   ports,
+			Enabled: true,
+		})
+	}
+	return config
+}
+
+// checkErrors reports a mismatch between errors and the wanted substrings
+func checkErrors(t *testing.T, function string, errors types.ValidationErrors, want []string) {
+	t.Helper()
+	if len(errors) != len(want) {
+		t.Errorf("%s() reported %d errors, want %d: %v", function, len(errors), len(want), errors)
+	}
+	for _, w := range want {
+		if !strings.Contains(errors.Error(), w) {
+			t.Errorf("%s() = %v, want %q", function, errors, w)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		port types.PortConfig
+		want []string
+	}{
+		{name: "container only", port: types.PortConfig{Container: 80}},
+		{name: "published udp", port: types.PortConfig{Host: 53, Container: 53, Protocol: "udp"}},
+		{name: "missing container port", port: types.PortConfig{Host: 80}, want: []string{"ports[0].container': must be between 1 and 65535"}},
+		{name: "host port too large", port: types.PortConfig{Host: 70000, Container: 80}, want: []string{"ports[0].host': must be between 1 and 65535"}},
+		{name: "negative container port", port: types.PortConfig{Container: -1}, want: []string{"ports[0].container'"}},
+		{name: "unsupported protocol", port: types.PortConfig{Container: 80, Protocol: "sctp"}, want: []string{"unsupported protocol 'sctp' (use tcp or udp)"}},
+		{name: "protocols are lower case", port: types.PortConfig{Container: 80, Protocol: "TCP"}, want: []string{"unsupported protocol 'TCP'"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErrors(t, "Validate", Validate(project([]types.PortConfig{tt.port})), tt.want)
+		})
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		services [][]types.PortConfig
+		want     []string
+	}{
+		{
+			name:     "different host ports",
+			services: [][]types.PortConfig{{{Host: 80, Container: 80}}, {{Host: 8080, Container: 80}}},
+		},
+		{
+			name:     "same host port",
+			services: [][]types.PortConfig{{{Host: 80, Container: 80}}, {{Host: 80, Container: 8080}}},
+			want:     []string{"services[1].ports[0].host': host port 80/tcp is already published by service 'a'"},
+		},
+		{
+			name:     "same port within one service",
+			services: [][]types.PortConfig{{{Host: 80, Container: 80}, {Host: 80, Container: 81}}},
+			want:     []string{"services[0].ports[1].host': host port 80/tcp is already published by service 'a'"},
+		},
+		{
+			name:     "different protocols",
+			services: [][]types.PortConfig{{{Host: 53, Container: 53}}, {{Host: 53, Container: 53, Protocol: "udp"}}},
+		},
+		{
+			name:     "container ports only",
+			services: [][]types.PortConfig{{{Container: 80}}, {{Container: 80}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErrors(t, "Conflicts", Conflicts(project(tt.services...)), tt.want)
+		})
+	}
+
+	config := project([]types.PortConfig{{Host: 80, Container: 80}}, []types.PortConfig{{Host: 80, Container: 80}})
+	config.Services[0].Enabled = false
+	if errors := Conflicts(config); errors.HasErrors() {
+		t.Errorf("Conflicts() = %v, want disabled services ignored", errors)
+	}
+}
+
+func TestMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		port    types.PortConfig
+		mapping string
+		expose  string
+	}{
+		{name: "container only", port: types.PortConfig{Container: 80}, mapping: "80", expose: "80"},
+		{name: "published", port: types.PortConfig{Host: 8080, Container: 80}, mapping: "8080:80", expose: "80"},
+		{name: "udp", port: types.PortConfig{Host: 53, Container: 53, Protocol: "udp"}, mapping: "53:53/udp", expose: "53/udp"},
+		{name: "range", port: types.PortConfig{Host: 9000, Container: 7000, ContainerEnd: 7002}, mapping: "9000-9002:7000-7002", expose: "7000-7002"},
+		{name: "loopback", port: types.PortConfig{HostIP: "127.0.0.1", Host: 5432, Container: 5432}, mapping: "127.0.0.1:5432:5432", expose: "5432"},
+		{name: "address without host port", port: types.PortConfig{HostIP: "10.0.0.1", Container: 80}, mapping: "10.0.0.1::80", expose: "80"},
+		{name: "ipv6", port: types.PortConfig{HostIP: "::1", Host: 80, Container: 80}, mapping: "[::1]:80:80", expose: "80"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Mapping(tt.port); got != tt.mapping {
+				t.Errorf("Mapping() = %s, want %s", got, tt.mapping)
+			}
+			if got := Expose(tt.port); got != tt.expose {
+				t.Errorf("Expose() = %s, want %s", got, tt.expose)
+			}
+		})
+	}
+}
+
+func TestExposure(t *testing.T) {
+	tests := []struct {
+		port     types.PortConfig
+		exposure string
+		loopback bool
+	}{
+		{port: types.PortConfig{Container: 80}, exposure: Public},
+		{port: types.PortConfig{Container: 80, Exposure: Internal}, exposure: Internal},
+		{port: types.PortConfig{Container: 80, Exposure: None}, exposure: None},
+		{port: types.PortConfig{Container: 80, HostIP: "127.0.0.1"}, exposure: Public, loopback: true},
+		{port: types.PortConfig{Container: 80, HostIP: "::1"}, exposure: Public, loopback: true},
+		{port: types.PortConfig{Container: 80, HostIP: "0.0.0.0"}, exposure: Public},
+	}
+
+	for _, tt := range tests {
+		if got := Exposure(tt.port); got != tt.exposure {
+			t.Errorf("Exposure(%+v) = %s, want %s", tt.port, got, tt.exposure)
+		}
+		if got := Loopback(tt.port); got != tt.loopback {
+			t.Errorf("Loopback(%+v) = %v, want %v", tt.port, got, tt.loopback)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	expanded := Expand(types.PortConfig{Host: 9000, Container: 7000, ContainerEnd: 7002, Protocol: "udp"})
+	if len(expanded) != 3 {
+		t.Fatalf("Expand() = %+v, want 3 ports", expanded)
+	}
+	for i, port := range expanded {
+		if port.Container != 7000+i || port.Host != 9000+i || port.ContainerEnd != 0 || port.HostEnd != 0 || port.Protocol != "udp" {
+			t.Errorf("Expand()[%d] = %+v, want %d:%d/udp", i, port, 9000+i, 7000+i)
+		}
+	}
+
+	if expanded := Expand(types.PortConfig{Container: 80}); len(expanded) != 1 || expanded[0].Host != 0 {
+		t.Errorf("Expand() = %+v, want the single port unpublished", expanded)
+	}
+}
+
+func TestBindings(t *testing.T) {
+	tests := []struct {
+		name string
+		port types.PortConfig
+		want []string
+	}{
+		{name: "range", port: types.PortConfig{Host: 9000, HostEnd: 9002, Container: 7000, ContainerEnd: 7002}},
+		{name: "host range from container range", port: types.PortConfig{Host: 9000, Container: 7000, ContainerEnd: 7002}},
+		{name: "internal port", port: types.PortConfig{Container: 80, Exposure: Internal}},
+		{name: "reversed range", port: types.PortConfig{Container: 7002, ContainerEnd: 7000}, want: []string{"container_end': range end 7000 is before its start 7002"}},
+		{name: "host end without host", port: types.PortConfig{Container: 80, HostEnd: 81}, want: []string{"host_end requires host"}},
+		{name: "range sizes differ", port: types.PortConfig{Host: 9000, HostEnd: 9001, Container: 7000, ContainerEnd: 7002}, want: []string{"host range 9000-9001 must have as many ports as container range 7000-7002"}},
+		{name: "invalid host IP", port: types.PortConfig{Host: 80, Container: 80, HostIP: "localhost"}, want: []string{"'localhost' is not an IP address"}},
+		{
+			name: "published port that is not public",
+			port: types.PortConfig{Host: 80, Container: 80, HostIP: "127.0.0.1", Exposure: Internal},
+			want: []string{"only public ports are published on a host port, this one is internal", "only public ports are bound to a host IP"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErrors(t, "Bindings", Bindings(project([]types.PortConfig{tt.port})), tt.want)
+		})
+	}
+}
+
+func TestConflictsRangesAndAddresses(t *testing.T) {
+	tests := []struct {
+		name     string
+		services [][]types.PortConfig
+		want     []string
+	}{
+		{
+			name:     "overlapping ranges",
+			services: [][]types.PortConfig{{{Host: 9000, Container: 9000, ContainerEnd: 9005}}, {{Host: 9005, Container: 80}}},
+			want:     []string{"host port 9005/tcp is already published by service 'a'"},
+		},
+		{
+			name:     "adjacent ranges",
+			services: [][]types.PortConfig{{{Host: 9000, Container: 9000, ContainerEnd: 9005}}, {{Host: 9006, Container: 80}}},
+		},
+		{
+			name:     "different addresses",
+			services: [][]types.PortConfig{{{HostIP: "127.0.0.1", Host: 80, Container: 80}}, {{HostIP: "10.0.0.1", Host: 80, Container: 80}}},
+		},
+		{
+			name:     "any address overlaps a specific one",
+			services: [][]types.PortConfig{{{HostIP: "127.0.0.1", Host: 80, Container: 80}}, {{HostIP: "0.0.0.0", Host: 80, Container: 80}}},
+			want:     []string{"host port 80/tcp is already published by service 'a'"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErrors(t, "Conflicts", Conflicts(project(tt.services...)), tt.want)
+		})
+	}
+}
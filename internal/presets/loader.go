@@ -0,0 +1,246 @@
+package presets
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/migrations"
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// yamlErrorLine matches the "line N: message" entries of yaml.v3 errors
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// parseProject parses a project file into its document node. With upgrade,
+// documents of older schema versions are migrated in place; migrated nodes
+// keep their original positions.
+func parseProject(data []byte, file string, upgrade bool) (*yaml.Node, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, yamlErrors(err, file)
+	}
+
+	document := &root
+	if document.Kind == yaml.DocumentNode && len(document.Content) > 0 {
+		document = document.Content[0]
+	}
+
+	if upgrade && document.Kind == yaml.MappingNode {
+		if _, err := migrations.Upgrade(document, file); err != nil {
+			return nil, err
+		}
+	}
+
+	return document, nil
+}
+
+// decodeProject strictly decodes a project document. Unknown fields and type
+// mismatches are reported together as ValidationErrors positioned in file.
+// Services that do not state enabled default to enabled.
+func decodeProject(document *yaml.Node, file string) (*types.ProjectConfig, error) {
+	sources := types.SourceMap{}
+	var errors types.ValidationErrors
+	checkFields(document, reflect.TypeOf(types.ProjectConfig{}), "", file, sources, &errors)
+
+	var config types.ProjectConfig
+	if document.Kind != 0 {
+		if err := document.Decode(&config); err != nil {
+			errors = append(errors, yamlErrors(err, file)...)
+		}
+	}
+
+	if errors.HasErrors() {
+		return nil, errors
+	}
+
+	applyDefaults(document, &config)
+	config.Sources = sources
+
+	return &config, nil
+}
+
+// checkFields walks node against the YAML shape of t, recording the position
+// of every field in sources and reporting keys that t does not define
+func checkFields(node *yaml.Node, t reflect.Type, path, file string, sources types.SourceMap, errors *types.ValidationErrors) {
+	sources[path] = types.Position{File: file, Line: node.Line, Column: node.Column}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode || t == reflect.TypeOf(time.Time{}) {
+			return
+		}
+
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			childPath := joinPath(path, key.Value)
+
+			fieldType, known := fields[key.Value]
+			if !known {
+				*errors = append(*errors, types.ValidationError{
+					Field:    childPath,
+					Message:  unknownFieldMessage(key.Value, fields),
+					Value:    key.Value,
+					Position: types.Position{File: file, Line: key.Line, Column: key.Column},
+				})
+				continue
+			}
+
+			// Environment overlays are partial project configs
+			if path == "" && key.Value == "environments" && value.Kind == yaml.MappingNode {
+				sources[childPath] = types.Position{File: file, Line: value.Line, Column: value.Column}
+				for j := 0; j+1 < len(value.Content); j += 2 {
+					checkFields(value.Content[j+1], t, joinPath(childPath, value.Content[j].Value), file, sources, errors)
+				}
+				continue
+			}
+
+			checkFields(value, fieldType, childPath, file, sources, errors)
+		}
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			checkFields(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value), file, sources, errors)
+		}
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			checkFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), file, sources, errors)
+		}
+	}
+}
+
+// yamlFields returns the YAML keys of a struct with their types
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// unknownFieldMessage reports an unknown key, suggesting the closest known one
+func unknownFieldMessage(key string, fields map[string]reflect.Type) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	best, bestDistance := "", 3
+	for _, name := range names {
+		if distance := editDistance(key, name); distance < bestDistance {
+			best, bestDistance = name, distance
+		}
+	}
+
+	if best != "" {
+		return fmt.Sprintf("unknown field '%s' (did you mean '%s'?)", key, best)
+	}
+	return fmt.Sprintf("unknown field '%s'", key)
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+
+	return previous[len(b)]
+}
+
+// applyDefaults sets defaults for fields the file leaves out
+func applyDefaults(document *yaml.Node, config *types.ProjectConfig) {
+	services := mappingValue(document, "services")
+	if services == nil || services.Kind != yaml.SequenceNode {
+		return
+	}
+
+	for i, service := range services.Content {
+		if i < len(config.Services) && mappingValue(service, "enabled") == nil {
+			config.Services[i].Enabled = true
+		}
+	}
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// yamlErrors converts yaml.v3 errors into positioned validation errors
+func yamlErrors(err error, file string) types.ValidationErrors {
+	var messages []string
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+
+	var errors types.ValidationErrors
+	for _, message := range messages {
+		position := types.Position{File: file}
+		if match := yamlErrorLine.FindStringSubmatch(message); match != nil {
+			position.Line, _ = strconv.Atoi(match[1])
+			message = match[2]
+		}
+		errors = append(errors, types.ValidationError{
+			Message:  strings.TrimPrefix(message, "yaml: "),
+			Position: position,
+		})
+	}
+	return errors
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
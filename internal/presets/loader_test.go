@@ -0,0 +1,90 @@
+package presets
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/generators/docker"
+)
+
+func writeProject(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "infra-gen.yml")
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadProjectUnversionedServiceIsGenerated(t *testing.T) {
+	path := writeProject(t, "name: x\ntype: web-app\nservices:\n  - name: api\n    type: api\n    image: node\n")
+
+	config, err := NewManager().LoadProject(path)
+	if err != nil {
+		t.Fatalf("LoadProject() error = %v", err)
+	}
+	if !config.Services[0].Enabled {
+		t.Fatalf("service without enabled was loaded disabled")
+	}
+
+	files, err := docker.NewGenerator().Generate(config)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	var compose string
+	for _, file := range files {
+		if file.Path == "docker-compose.yml" {
+			compose = file.Content
+		}
+	}
+	if !strings.Contains(compose, "  api:\n    image: node") {
+		t.Errorf("docker-compose.yml does not contain service api:\n%s", compose)
+	}
+}
+
+func TestLoadProjectStrict(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "misspelled field",
+			text: "schema_version: 2\nname: x\nservices:\n  - name: api\n    type: api\n    depend_on: [db]\n",
+			want: "infra-gen.yml:6:5: validation error on field 'services[0].depend_on': unknown field 'depend_on' (did you mean 'depends_on'?)",
+		},
+		{
+			name: "type mismatch",
+			text: "schema_version: 2\nname: x\nservices:\n  - name: api\n    type: api\n    ports: 8080\n",
+			want: "infra-gen.yml:6",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewManager().LoadProject(writeProject(t, test.text))
+			if err == nil {
+				t.Fatal("LoadProject() succeeded, want an error")
+			}
+			if !strings.Contains(err.Error(), test.want) {
+				t.Errorf("LoadProject() error = %v, want it to contain %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadProjectKeepsExplicitDisable(t *testing.T) {
+	path := writeProject(t, "name: x\nservices:\n  - name: api\n    type: api\n    enabled: false\n")
+
+	config, err := NewManager().LoadProject(path)
+	if err != nil {
+		t.Fatalf("LoadProject() error = %v", err)
+	}
+	if config.Services[0].Enabled {
+		t.Errorf("enabled: false was loaded as enabled")
+	}
+	if config.SchemaVersion != 2 {
+		t.Errorf("SchemaVersion = %d, want 2", config.SchemaVersion)
+	}
+}
@@ -3,9 +3,15 @@ package presets
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
 	"time"
 
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/interpolation"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/migrations"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/schema"
 	"github.com/kishininfosec/infra-gen/infra-gen/internal/templates"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/validation"
 	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
 	"gopkg.in/yaml.v3"
 )
@@ -22,33 +28,59 @@ func NewManager() *Manager {
 	}
 }
 
-// CreateProjectFromPreset creates a project configuration from a preset
-func (m *Manager) CreateProjectFromPreset(presetID string, projectName string, environment string) (*types.ProjectConfig, error) {
-	preset, err := m.templateManager.GetPreset(presetID)
+// ProjectOptions holds the choices made when creating a project from a preset
+type ProjectOptions struct {
+	Name        string
+	Environment string
+	Params      map[string]string
+	Mixins      []string
+
+	// Variables overrides or adds project variables after the preset is applied
+	Variables map[string]string
+
+	// Include lists optional services to add; Exclude lists services to leave out
+	Include []string
+	Exclude []string
+
+	// SelectOptional, when set and Include is empty, is asked which optional
+	// services to add
+	SelectOptional func(optional []types.PresetService) ([]string, error)
+}
+
+// CreateProjectFromPreset creates a project configuration from a preset,
+// rendering its parameters, applying mixins and keeping only the selected
+// services. Optional services that are not selected are left out entirely.
+func (m *Manager) CreateProjectFromPreset(presetID string, opts ProjectOptions) (*types.ProjectConfig, error) {
+	preset, err := m.templateManager.Compose(presetID, opts.Mixins, opts.Params)
 	if err != nil {
 		return nil, err
 	}
 
-	config := &types.ProjectConfig{
-		Name:        projectName,
-		Type:        m.getProjectTypeFromPreset(presetID),
-		Description: preset.Description,
-		Environment: environment,
-		Version:     "1.0.0",
-		Services:    make([]types.ServiceConfig, 0),
-		Variables:   make(map[string]string),
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}
-
-	// Get preset with service data
-	presetWithServices, err := m.loadPresetTemplateData(presetID)
+	services, err := m.selectServices(preset.Services, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load preset template data: %w", err)
+		return nil, err
+	}
+
+	projectType := preset.Type
+	if projectType == "" {
+		projectType = types.ProjectTypeWebApp
+	}
+
+	config := &types.ProjectConfig{
+		SchemaVersion: migrations.CurrentVersion,
+		Name:          opts.Name,
+		Type:          projectType,
+		Description:   preset.Description,
+		Environment:   opts.Environment,
+		Version:       "1.0.0",
+		Services:      make([]types.ServiceConfig, 0),
+		Variables:     make(map[string]string),
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
 	}
 
 	// Convert preset services to project services
-	for _, presetService := range presetWithServices.Services {
+	for _, presetService := range services {
 		service := types.ServiceConfig{
 			Name:        presetService.Name,
 			Type:        presetService.Type,
@@ -56,8 +88,12 @@ func (m *Manager) CreateProjectFromPreset(presetID string, projectName string, e
 			Ports:       presetService.Ports,
 			Volumes:     presetService.Volumes,
 			Environment: make(map[string]string),
-			DependsOn:   []string{},
-			Enabled:     !presetService.Optional,
+			DependsOn:   append([]string{}, presetService.DependsOn...),
+			Healthcheck: presetService.Healthcheck,
+			Resources:   presetService.Resources,
+			Replicas:    presetService.Replicas,
+			Autoscaling: presetService.Autoscaling,
+			Enabled:     true,
 		}
 
 		// Copy environment variables
@@ -68,19 +104,216 @@ func (m *Manager) CreateProjectFromPreset(presetID string, projectName string, e
 		config.Services = append(config.Services, service)
 	}
 
-	// Copy preset variables
-	for key, value := range preset.Variables {
+	// Copy preset variables, leaving out those only dropped services use
+	for key, value := range selectVariables(preset, services) {
+		config.Variables[key] = value
+	}
+	for key, value := range opts.Variables {
 		config.Variables[key] = value
 	}
 
 	return config, nil
 }
 
+// selectServices applies --include/--exclude style selection to preset services.
+// Required services are kept unless excluded; optional services are kept only
+// when included. Unknown names and dependencies on dropped services are errors.
+func (m *Manager) selectServices(services []types.PresetService, opts ProjectOptions) ([]types.PresetService, error) {
+	var errors types.ValidationErrors
+
+	known := make(map[string]types.PresetService)
+	var optional []types.PresetService
+	for _, service := range services {
+		known[service.Name] = service
+		if service.Optional {
+			optional = append(optional, service)
+		}
+	}
+
+	include := opts.Include
+	if len(include) == 0 && opts.SelectOptional != nil && len(optional) > 0 {
+		selected, err := opts.SelectOptional(optional)
+		if err != nil {
+			return nil, err
+		}
+		include = selected
+	}
+
+	included := make(map[string]bool)
+	for _, name := range include {
+		if _, exists := known[name]; !exists {
+			errors.Add("include", fmt.Sprintf("unknown service: %s", name), name)
+		}
+		included[name] = true
+	}
+
+	excluded := make(map[string]bool)
+	for _, name := range opts.Exclude {
+		if _, exists := known[name]; !exists {
+			errors.Add("exclude", fmt.Sprintf("unknown service: %s", name), name)
+		}
+		if included[name] {
+			errors.Add("exclude", fmt.Sprintf("service '%s' is both included and excluded", name), name)
+		}
+		excluded[name] = true
+	}
+
+	var selected []types.PresetService
+	kept := make(map[string]bool)
+	for _, service := range services {
+		if excluded[service.Name] || (service.Optional && !included[service.Name]) {
+			continue
+		}
+		selected = append(selected, service)
+		kept[service.Name] = true
+	}
+
+	for _, service := range selected {
+		for _, dep := range service.DependsOn {
+			if _, exists := known[dep]; exists && !kept[dep] {
+				errors.Add("services", fmt.Sprintf("service '%s' depends on '%s', which is not selected", service.Name, dep), dep)
+			}
+		}
+	}
+
+	if len(selected) == 0 && !errors.HasErrors() {
+		errors.Add("services", "at least one service must be selected", len(selected))
+	}
+
+	if errors.HasErrors() {
+		return nil, errors
+	}
+
+	return selected, nil
+}
+
+// selectVariables returns the preset variables that the selected services
+// reference, directly or through other variables, and those that no service
+// of the preset references at all
+func selectVariables(preset *types.Preset, selected []types.PresetService) map[string]string {
+	reach := func(names []string) map[string]bool {
+		reached := make(map[string]bool)
+		for len(names) > 0 {
+			name := names[len(names)-1]
+			names = names[:len(names)-1]
+			value, exists := preset.Variables[name]
+			if !exists || reached[name] {
+				continue
+			}
+			reached[name] = true
+			for _, ref := range interpolation.Parse(value) {
+				names = append(names, ref.Name)
+			}
+		}
+		return reached
+	}
+
+	var all, roots []string
+	for _, service := range preset.Services {
+		all = append(all, serviceReferences(service)...)
+	}
+	for _, service := range selected {
+		roots = append(roots, serviceReferences(service)...)
+	}
+	referenced := reach(all)
+	for name := range preset.Variables {
+		if !referenced[name] {
+			roots = append(roots, name)
+		}
+	}
+
+	variables := make(map[string]string)
+	for name := range reach(roots) {
+		variables[name] = preset.Variables[name]
+	}
+	return variables
+}
+
+// serviceReferences returns the names of the variables a preset service
+// references in its image, volumes, environment and healthcheck
+func serviceReferences(service types.PresetService) []string {
+	values := []string{service.Image}
+	for _, volume := range service.Volumes {
+		values = append(values, volume.Source, volume.Target)
+	}
+	for _, value := range service.Environment {
+		values = append(values, value)
+	}
+	if service.Healthcheck != nil {
+		values = append(values, service.Healthcheck.Command)
+	}
+
+	var names []string
+	for _, value := range values {
+		for _, ref := range interpolation.Parse(value) {
+			names = append(names, ref.Name)
+		}
+	}
+	return names
+}
+
+// PresetPathEnv is the environment variable holding a list of custom preset directories
+const PresetPathEnv = "INFRA_GEN_PRESET_PATH"
+
+// presetSearchPath returns the custom preset locations from highest to lowest
+// precedence: explicit preset directories, then INFRA_GEN_PRESET_PATH entries
+// in order, then $XDG_CONFIG_HOME/infra-gen/presets.
+func presetSearchPath(presetDirs []string) []string {
+	var paths []string
+
+	paths = append(paths, presetDirs...)
+
+	for _, dir := range filepath.SplitList(os.Getenv(PresetPathEnv)) {
+		if dir != "" {
+			paths = append(paths, dir)
+		}
+	}
+
+	if configDir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(configDir, "infra-gen", "presets"))
+	}
+
+	return paths
+}
+
+// LoadCustomPresets discovers custom presets from the explicit preset
+// directories, INFRA_GEN_PRESET_PATH and the user config directory. A preset
+// found in a higher precedence location shadows embedded presets and presets
+// of the same ID in lower precedence locations. Explicit directories must
+// exist; the others are skipped when missing.
+func (m *Manager) LoadCustomPresets(presetDirs []string) error {
+	paths := presetSearchPath(presetDirs)
+
+	// Load lowest precedence first so later loads shadow earlier ones
+	var existing []string
+	for i := len(paths) - 1; i >= 0; i-- {
+		if _, err := os.Stat(paths[i]); os.IsNotExist(err) && i >= len(presetDirs) {
+			continue
+		}
+		existing = append(existing, paths[i])
+	}
+	if len(existing) == 0 {
+		return nil
+	}
+
+	return m.templateManager.LoadCustomTemplate(existing...)
+}
+
+// Warnings returns warnings raised while loading presets, such as shadowed presets
+func (m *Manager) Warnings() []string {
+	return m.templateManager.Warnings()
+}
+
 // ListPresets returns all available presets
 func (m *Manager) ListPresets() []types.Preset {
 	return m.templateManager.ListPresets()
 }
 
+// ListMixins returns all available add-on mixins
+func (m *Manager) ListMixins() []types.Preset {
+	return m.templateManager.ListMixins()
+}
+
 // ListPresetsByCategory returns presets filtered by category
 func (m *Manager) ListPresetsByCategory(category string) []types.Preset {
 	return m.templateManager.ListPresetsByCategory(category)
@@ -91,41 +324,36 @@ func (m *Manager) GetPreset(id string) (*types.Preset, error) {
 	return m.templateManager.GetPreset(id)
 }
 
-// ValidateProject validates a project configuration
-func (m *Manager) ValidateProject(config *types.ProjectConfig) error {
-	var errors types.ValidationErrors
+// projectSchema is the schema printed by infra-gen schema
+var projectSchema = schema.Project()
 
-	if config.Name == "" {
-		errors.Add("name", "project name is required", config.Name)
+// ValidateProject validates a project configuration against the project JSON
+// Schema, then checks rules the schema cannot express
+func (m *Manager) ValidateProject(config *types.ProjectConfig) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal project config: %w", err)
 	}
 
-	if config.Type == "" {
-		errors.Add("type", "project type is required", config.Type)
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("failed to unmarshal project config: %w", err)
 	}
 
-	if len(config.Services) == 0 {
-		errors.Add("services", "at least one service is required", len(config.Services))
-	}
-
-	// Validate each service
-	for i, service := range config.Services {
-		if service.Name == "" {
-			errors.Add(fmt.Sprintf("services[%d].name", i), "service name is required", service.Name)
-		}
-		if service.Type == "" {
-			errors.Add(fmt.Sprintf("services[%d].type", i), "service type is required", service.Type)
-		}
-	}
+	errors := projectSchema.Validate(document)
 
 	// Check for duplicate service names
 	serviceNames := make(map[string]bool)
-	for _, service := range config.Services {
+	for i, service := range config.Services {
 		if serviceNames[service.Name] {
-			errors.Add("services", fmt.Sprintf("duplicate service name: %s", service.Name), service.Name)
+			errors.Add(fmt.Sprintf("services[%d].name", i), fmt.Sprintf("duplicate service name: %s", service.Name), service.Name)
 		}
 		serviceNames[service.Name] = true
 	}
 
+	// Check what the schema cannot express
+	errors = append(errors, validation.Rules(config)...)
+
 	if errors.HasErrors() {
 		return errors
 	}
@@ -133,145 +361,91 @@ func (m *Manager) ValidateProject(config *types.ProjectConfig) error {
 	return nil
 }
 
-// SaveProject saves a project configuration to a file
+// SaveProject saves a project configuration to a file. An existing project
+// file is edited in place through its YAML node tree, so comments, key order
+// and anchors survive and only changed values are rewritten. created_at is
+// kept, updated_at only moves when something else changed, and a file
+// without changes is left byte-for-byte untouched. A file that is not valid
+// YAML is an error rather than replaced.
 func (m *Manager) SaveProject(config *types.ProjectConfig, filePath string) error {
-	yamlData, err := yaml.Marshal(config)
+	var desired yaml.Node
+	if err := desired.Encode(config); err != nil {
+		return fmt.Errorf("failed to marshal project config: %w", err)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read project file: %w", err)
+	}
+
+	// A file that does not parse is the user's to fix, not to overwrite
+	var root yaml.Node
+	if err == nil {
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", filePath, err)
+		}
+		if len(root.Content) > 0 && root.Content[0].Kind != yaml.MappingNode {
+			return fmt.Errorf("failed to parse %s: not a mapping of project settings", filePath)
+		}
+	}
+	if len(root.Content) == 0 {
+		// No existing project to preserve
+		yamlData, err := yaml.Marshal(config)
+		if err != nil {
+			return fmt.Errorf("failed to marshal project config: %w", err)
+		}
+		return os.WriteFile(filePath, yamlData, 0644)
+	}
+	document := root.Content[0]
+
+	// Timestamps alone are not a change: compare against the file's values,
+	// keeping its created_at
+	var newUpdatedAt *yaml.Node
+	if value := mappingValue(&desired, "updated_at"); value != nil {
+		snapshot := *value
+		newUpdatedAt = &snapshot
+	}
+	for _, key := range []string{"created_at", "updated_at"} {
+		if current := mappingValue(document, key); current != nil {
+			if value := mappingValue(&desired, key); value != nil {
+				*value = *current
+			}
+		}
+	}
+
+	if !updateNode(document, &desired, reflect.TypeOf(config)) {
+		return nil
+	}
+	if current := mappingValue(document, "updated_at"); current != nil && newUpdatedAt != nil {
+		updateNode(current, newUpdatedAt, nil)
+	}
+
+	yamlData, err := migrations.Encode(&root, migrations.DetectIndent(data))
 	if err != nil {
 		return fmt.Errorf("failed to marshal project config: %w", err)
 	}
 
-	return os.WriteFile(filePath, yamlData, 0644)
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(filePath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	return os.WriteFile(filePath, yamlData, mode)
 }
 
-// LoadProject loads a project configuration from a file
+// LoadProject loads a project configuration from a file. Configs of older
+// schema versions are migrated in memory and configs newer than this build
+// are refused. Unknown fields are rejected and every error carries its line
+// and column in the file.
 func (m *Manager) LoadProject(filePath string) (*types.ProjectConfig, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read project file: %w", err)
 	}
 
-	var config types.ProjectConfig
-	err = yaml.Unmarshal(data, &config)
+	document, err := parseProject(data, filePath, true)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal project config: %w", err)
-	}
-
-	return &config, nil
-}
-
-// loadPresetTemplateData loads the detailed template data for a preset
-func (m *Manager) loadPresetTemplateData(presetID string) (*types.Preset, error) {
-	// For now, return basic preset data based on ID
-	switch presetID {
-	case "web-app":
-		return &types.Preset{
-			Services: []types.PresetService{
-				{
-					Name:        "frontend",
-					Type:        "frontend",
-					Description: "Frontend web application",
-					Image:       "nginx:alpine",
-					Ports:       []types.PortConfig{{Container: 80, Protocol: "tcp"}},
-					Environment: map[string]string{"REACT_APP_API_URL": "http://api:8080"},
-					Optional:    false,
-				},
-				{
-					Name:        "api",
-					Type:        "api",
-					Description: "Backend API server",
-					Image:       "node:18-alpine",
-					Ports:       []types.PortConfig{{Container: 8080, Protocol: "tcp"}},
-					Environment: map[string]string{"NODE_ENV": "development", "DB_HOST": "database"},
-					Optional:    false,
-				},
-				{
-					Name:        "database",
-					Type:        "database",
-					Description: "PostgreSQL database",
-					Image:       "postgres:15",
-					Ports:       []types.PortConfig{{Container: 5432, Protocol: "tcp"}},
-					Volumes:     []types.VolumeConfig{{Source: "db_data", Target: "/var/lib/postgresql/data", Type: "volume"}},
-					Environment: map[string]string{"POSTGRES_DB": "webapp", "POSTGRES_USER": "admin"},
-					Optional:    false,
-				},
-			},
-		}, nil
-	case "microservice":
-		return &types.Preset{
-			Services: []types.PresetService{
-				{
-					Name:        "api-gateway",
-					Type:        "gateway",
-					Description: "API Gateway",
-					Image:       "nginx:alpine",
-					Ports:       []types.PortConfig{{Container: 80, Protocol: "tcp"}},
-					Environment: map[string]string{"UPSTREAM_SERVICE1": "service1:8081"},
-					Optional:    false,
-				},
-				{
-					Name:        "service1",
-					Type:        "microservice",
-					Description: "First microservice",
-					Image:       "node:18-alpine",
-					Ports:       []types.PortConfig{{Container: 8081, Protocol: "tcp"}},
-					Environment: map[string]string{"SERVICE_NAME": "service1"},
-					Optional:    false,
-				},
-				{
-					Name:        "redis",
-					Type:        "cache",
-					Description: "Redis cache",
-					Image:       "redis:7-alpine",
-					Ports:       []types.PortConfig{{Container: 6379, Protocol: "tcp"}},
-					Volumes:     []types.VolumeConfig{{Source: "redis_data", Target: "/data", Type: "volume"}},
-					Optional:    false,
-				},
-			},
-		}, nil
-	case "database":
-		return &types.Preset{
-			Services: []types.PresetService{
-				{
-					Name:        "postgres",
-					Type:        "database",
-					Description: "PostgreSQL database",
-					Image:       "postgres:15",
-					Ports:       []types.PortConfig{{Container: 5432, Protocol: "tcp"}},
-					Volumes:     []types.VolumeConfig{{Source: "postgres_data", Target: "/var/lib/postgresql/data", Type: "volume"}},
-					Environment: map[string]string{"POSTGRES_DB": "myapp", "POSTGRES_USER": "postgres"},
-					Optional:    false,
-				},
-				{
-					Name:        "mysql",
-					Type:        "database",
-					Description: "MySQL database",
-					Image:       "mysql:8.0",
-					Ports:       []types.PortConfig{{Container: 3306, Protocol: "tcp"}},
-					Volumes:     []types.VolumeConfig{{Source: "mysql_data", Target: "/var/lib/mysql", Type: "volume"}},
-					Environment: map[string]string{"MYSQL_DATABASE": "myapp", "MYSQL_USER": "mysql"},
-					Optional:    true,
-				},
-			},
-		}, nil
-	default:
-		return &types.Preset{Services: []types.PresetService{}}, nil
+		return nil, err
 	}
-}
 
-// Helper functions
-func (m *Manager) getProjectTypeFromPreset(presetID string) types.ProjectType {
-	switch presetID {
-	case "web-app":
-		return types.ProjectTypeWebApp
-	case "microservice":
-		return types.ProjectTypeMicroservice
-	case "database":
-		return types.ProjectTypeDatabase
-	case "ml":
-		return types.ProjectTypeML
-	case "infrastructure":
-		return types.ProjectTypeInfrastructure
-	default:
-		return types.ProjectTypeWebApp // Default fallback
-	}
+	return decodeProject(document, filePath)
 }
@@ -0,0 +1,149 @@
+package presets
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveProjectKeepsBundledFiles(t *testing.T) {
+	files, err := filepath.Glob("../../examples/*.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, "../../infra-gen.yml")
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			original, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := writeProject(t, string(original))
+
+			manager := NewManager()
+			config, err := manager.LoadProject(path)
+			if err != nil {
+				t.Fatalf("LoadProject() error = %v", err)
+			}
+			if err := manager.SaveProject(config, path); err != nil {
+				t.Fatalf("SaveProject() error = %v", err)
+			}
+
+			saved, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(saved, original) {
+				t.Errorf("SaveProject() changed the file:\n%s", saved)
+			}
+		})
+	}
+}
+
+func TestSaveProjectKeepsInvalidFile(t *testing.T) {
+	const broken = "# my notes\nname: [unclosed\n"
+	path := writeProject(t, broken)
+
+	config, err := NewManager().CreateProjectFromPreset("database", ProjectOptions{Name: "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewManager().SaveProject(config, path)
+	if err == nil || !strings.Contains(err.Error(), "failed to parse") {
+		t.Fatalf("SaveProject() error = %v, want a parse error", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != broken {
+		t.Errorf("SaveProject() overwrote the file with:\n%s", content)
+	}
+}
+
+func TestSaveProjectEditKeepsFile(t *testing.T) {
+	path := writeProject(t, `# Shop project
+schema_version: 2
+type: web-app
+name: shop # the shop
+variables:
+  B: ""
+  A: "1"
+  OLD: gone
+services:
+  # The API comes first
+  - name: api
+    image: app:1
+    type: api
+    environment:
+      EMPTY: ""
+      LOG_LEVEL: info
+`)
+
+	manager := NewManager()
+	config, err := manager.LoadProject(path)
+	if err != nil {
+		t.Fatalf("LoadProject() error = %v", err)
+	}
+	config.Environment = "production"
+	delete(config.Variables, "OLD")
+	config.Variables["NEW"] = ""
+	config.Services[0].Environment["DEBUG"] = ""
+	if err := manager.SaveProject(config, path); err != nil {
+		t.Fatalf("SaveProject() error = %v", err)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(saved)
+	for _, want := range []string{
+		"# Shop project\n",
+		"name: shop # the shop\n",
+		"environment: production\n",
+		"  # The API comes first\n",
+		"  B: \"\"\n",
+		"  NEW: \"\"\n",
+		"    image: app:1\n    type: api\n",
+		"      EMPTY: \"\"\n",
+		"      DEBUG: \"\"\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("saved file does not contain %q:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "OLD") {
+		t.Errorf("saved file still holds the removed variable:\n%s", content)
+	}
+	if strings.Index(content, "type: web-app") > strings.Index(content, "name: shop") {
+		t.Errorf("saved file reordered type and name:\n%s", content)
+	}
+	if strings.Index(content, "  B:") > strings.Index(content, "  A:") {
+		t.Errorf("saved file reordered variables:\n%s", content)
+	}
+	for _, zero := range []string{"replicas", "depends_on", "enabled", "created_at"} {
+		if strings.Contains(content, zero) {
+			t.Errorf("saved file gained %s, which the struct leaves out:\n%s", zero, content)
+		}
+	}
+
+	reloaded, err := manager.LoadProject(path)
+	if err != nil {
+		t.Fatalf("LoadProject() after save error = %v", err)
+	}
+	for _, key := range []string{"A", "B", "NEW"} {
+		if _, exists := reloaded.Variables[key]; !exists {
+			t.Errorf("variable %s lost in the round trip: %v", key, reloaded.Variables)
+		}
+	}
+	for _, key := range []string{"EMPTY", "DEBUG", "LOG_LEVEL"} {
+		if _, exists := reloaded.Services[0].Environment[key]; !exists {
+			t.Errorf("environment %s lost in the round trip: %v", key, reloaded.Services[0].Environment)
+		}
+	}
+}
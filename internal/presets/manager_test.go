@@ -0,0 +1,173 @@
+package presets
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/secrets"
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+func TestCreateProjectFromPresetSelectsVariables(t *testing.T) {
+	tests := []struct {
+		name      string
+		include   []string
+		exclude   []string
+		variables []string
+		secrets   []string
+	}{
+		{
+			name:      "required services only",
+			variables: []string{"POSTGRES_PASSWORD"},
+			secrets:   []string{"postgres_password"},
+		},
+		{
+			name:      "optional service instead of required",
+			include:   []string{"mysql"},
+			exclude:   []string{"postgres"},
+			variables: []string{"MYSQL_PASSWORD", "MYSQL_ROOT_PASSWORD"},
+			secrets:   []string{"mysql_password", "mysql_root_password"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := NewManager().CreateProjectFromPreset("database", ProjectOptions{
+				Name:    "db",
+				Include: tt.include,
+				Exclude: tt.exclude,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(config.Variables) != len(tt.variables) {
+				t.Errorf("variables = %v, want %v", config.Variables, tt.variables)
+			}
+			for _, name := range tt.variables {
+				if _, exists := config.Variables[name]; !exists {
+					t.Errorf("variable %s missing from %v", name, config.Variables)
+				}
+			}
+
+			adopted := secrets.Adopt(config)
+			if len(adopted) != len(tt.secrets) {
+				t.Fatalf("secrets = %v, want %v", adopted, tt.secrets)
+			}
+			for i := range adopted {
+				if adopted[i] != tt.secrets[i] {
+					t.Errorf("secrets = %v, want %v", adopted, tt.secrets)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectServices(t *testing.T) {
+	services := []types.PresetService{
+		{Name: "web", Type: "frontend", DependsOn: []string{"api"}},
+		{Name: "api", Type: "api"},
+		{Name: "cache", Type: "cache", Optional: true},
+		{Name: "search", Type: "search", Optional: true, DependsOn: []string{"api"}},
+	}
+
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		selected []string
+		want     string
+		errors   []string
+	}{
+		{name: "required services by default", want: "web api"},
+		{name: "optional service included", include: []string{"search"}, want: "web api search"},
+		{name: "required service excluded", include: []string{"cache"}, exclude: []string{"web"}, want: "api cache"},
+		{name: "asked for optional services", selected: []string{"cache"}, want: "web api cache"},
+		{
+			name:    "unknown services",
+			include: []string{"queue"},
+			exclude: []string{"db"},
+			errors:  []string{"unknown service: queue", "unknown service: db"},
+		},
+		{
+			name:    "included and excluded",
+			include: []string{"cache"},
+			exclude: []string{"cache"},
+			errors:  []string{"service 'cache' is both included and excluded"},
+		},
+		{
+			name:    "dependency dropped",
+			include: []string{"search"},
+			exclude: []string{"api"},
+			errors: []string{
+				"service 'web' depends on 'api', which is not selected",
+				"service 'search' depends on 'api', which is not selected",
+			},
+		},
+		{
+			name:    "nothing selected",
+			exclude: []string{"web", "api"},
+			errors:  []string{"at least one service must be selected"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := ProjectOptions{Include: tt.include, Exclude: tt.exclude}
+			if tt.selected != nil {
+				opts.SelectOptional = func(optional []types.PresetService) ([]string, error) {
+					if len(optional) != 2 {
+						t.Errorf("asked about %d optional services, want 2", len(optional))
+					}
+					return tt.selected, nil
+				}
+			}
+
+			selected, err := NewManager().selectServices(services, opts)
+			if len(tt.errors) > 0 {
+				var validationErrors types.ValidationErrors
+				if !errors.As(err, &validationErrors) {
+					t.Fatalf("selectServices() error = %v, want ValidationErrors", err)
+				}
+				if len(validationErrors) != len(tt.errors) {
+					t.Errorf("selectServices() reported %d errors, want %d: %v", len(validationErrors), len(tt.errors), err)
+				}
+				for _, want := range tt.errors {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("selectServices() error = %v, want it to contain %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectServices() error = %v", err)
+			}
+
+			var names []string
+			for _, service := range selected {
+				names = append(names, service.Name)
+			}
+			if got := strings.Join(names, " "); got != tt.want {
+				t.Errorf("selected = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateProjectFromPresetLeavesOutOptionalServices(t *testing.T) {
+	config, err := NewManager().CreateProjectFromPreset("database", ProjectOptions{Name: "db", Include: []string{"mongodb"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, service := range config.Services {
+		if !service.Enabled {
+			t.Errorf("service %s is disabled, want unselected services left out", service.Name)
+		}
+		names = append(names, service.Name)
+	}
+	if got := strings.Join(names, " "); got != "postgres mongodb" {
+		t.Errorf("services = %s, want postgres mongodb", got)
+	}
+}
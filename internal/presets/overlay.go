@@ -0,0 +1,285 @@
+package presets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// OverlayPath returns the overlay file for an environment next to the base
+// config, e.g. infra-gen.production.yml for infra-gen.yml
+func OverlayPath(filePath, environment string) string {
+	ext := filepath.Ext(filePath)
+	return strings.TrimSuffix(filePath, ext) + "." + environment + ext
+}
+
+// ProjectEnvironments returns the sorted names of the environments a project
+// has an overlay for, from its environments block and overlay files
+func ProjectEnvironments(filePath string, config *types.ProjectConfig) []string {
+	names := make(map[string]bool)
+	for name := range config.Environments {
+		names[name] = true
+	}
+
+	ext := filepath.Ext(filePath)
+	prefix := strings.TrimSuffix(filePath, ext) + "."
+	matches, _ := filepath.Glob(prefix + "*" + ext)
+	for _, match := range matches {
+		if name := strings.TrimSuffix(strings.TrimPrefix(match, prefix), ext); name != "" {
+			names[name] = true
+		}
+	}
+
+	environments := make([]string, 0, len(names))
+	for name := range names {
+		environments = append(environments, name)
+	}
+	sort.Strings(environments)
+	return environments
+}
+
+// LoadProjectEnvironment loads a project configuration and deep-merges the
+// overlay for an environment onto it. The overlay comes from the
+// environments block of the base config and from the overlay file next to
+// it; when both exist the file is applied last. The result has its
+// environment set and no environments block. Fields an overlay sets are
+// positioned in that overlay and the others in the base file.
+func (m *Manager) LoadProjectEnvironment(filePath, environment string) (*types.ProjectConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project file: %w", err)
+	}
+
+	document, err := parseProject(data, filePath, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the base config, including its environments block, strictly
+	baseConfig, err := decodeProject(document, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var base map[string]interface{}
+	if err := document.Decode(&base); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal project config: %w", err)
+	}
+	if base == nil {
+		base = make(map[string]interface{})
+	}
+
+	var overlays []overlaySources
+
+	environments, _ := base["environments"].(map[string]interface{})
+	delete(base, "environments")
+	if overlay, exists := environments[environment]; exists {
+		overlayMap, ok := overlay.(map[string]interface{})
+		if !ok && overlay != nil {
+			return nil, fmt.Errorf("environments.%s must be a mapping", environment)
+		}
+		overlays = append(overlays, overlaySources{
+			sources:  subSources(baseConfig.Sources, "environments."+environment),
+			services: overlayServiceNames(overlayMap),
+		})
+		base = mergeOverlay(base, overlayMap)
+	}
+
+	overlayFile := OverlayPath(filePath, environment)
+	if overlayData, err := os.ReadFile(overlayFile); err == nil {
+		overlayDocument, err := parseProject(overlayData, overlayFile, false)
+		if err != nil {
+			return nil, err
+		}
+		overlayConfig, err := decodeProject(overlayDocument, overlayFile)
+		if err != nil {
+			return nil, err
+		}
+
+		var overlay map[string]interface{}
+		if err := overlayDocument.Decode(&overlay); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal overlay %s: %w", overlayFile, err)
+		}
+		overlays = append(overlays, overlaySources{
+			sources:  overlayConfig.Sources,
+			services: overlayServiceNames(overlay),
+		})
+		base = mergeOverlay(base, overlay)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read overlay %s: %w", overlayFile, err)
+	}
+
+	if len(overlays) == 0 {
+		return nil, fmt.Errorf("no overlay for environment '%s': add environments.%s to %s or create %s", environment, environment, filePath, overlayFile)
+	}
+
+	base["environment"] = environment
+
+	merged, err := yaml.Marshal(base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal merged config: %w", err)
+	}
+
+	mergedFile := fmt.Sprintf("%s (%s)", filePath, environment)
+	mergedDocument, err := parseProject(merged, mergedFile, false)
+	if err != nil {
+		return nil, err
+	}
+	config, err := decodeProject(mergedDocument, mergedFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Sources = mergeSources(config, baseConfig.Sources, overlays)
+
+	return config, nil
+}
+
+// overlaySources holds the positions of an overlay's fields, relative to the
+// overlay, and the names of its services by index
+type overlaySources struct {
+	sources  types.SourceMap
+	services []string
+}
+
+// mergeSources positions every field of a merged config: in the last
+// overlay that sets it, or else in the base file. Overlay services are
+// matched to their merged index by name.
+func mergeSources(merged *types.ProjectConfig, base types.SourceMap, overlays []overlaySources) types.SourceMap {
+	index := make(map[string]int)
+	for i, service := range merged.Services {
+		if _, exists := index[service.Name]; !exists {
+			index[service.Name] = i
+		}
+	}
+
+	sources := types.SourceMap{}
+	for path := range merged.Sources {
+		if pos, exists := base[path]; exists {
+			sources[path] = pos
+		}
+	}
+
+	for _, overlay := range overlays {
+		for path, pos := range overlay.sources {
+			if path == "" {
+				continue
+			}
+			if strings.HasPrefix(path, "services[") {
+				end := strings.Index(path, "]")
+				var i int
+				if _, err := fmt.Sscanf(path[len("services["):end], "%d", &i); err != nil || i >= len(overlay.services) {
+					continue
+				}
+				j, exists := index[overlay.services[i]]
+				if !exists || overlay.services[i] == "" {
+					continue
+				}
+				path = fmt.Sprintf("services[%d]%s", j, path[end+1:])
+			}
+			if _, exists := merged.Sources[path]; exists {
+				sources[path] = pos
+			}
+		}
+	}
+
+	return sources
+}
+
+// subSources returns the positions under prefix with the prefix removed
+func subSources(sources types.SourceMap, prefix string) types.SourceMap {
+	sub := types.SourceMap{}
+	for path, pos := range sources {
+		switch {
+		case path == prefix:
+			sub[""] = pos
+		case strings.HasPrefix(path, prefix+"."):
+			sub[path[len(prefix)+1:]] = pos
+		}
+	}
+	return sub
+}
+
+// overlayServiceNames returns the names of an overlay's services by index,
+// "" for services without one
+func overlayServiceNames(overlay map[string]interface{}) []string {
+	services, _ := overlay["services"].([]interface{})
+	names := make([]string, len(services))
+	for i, service := range services {
+		names[i], _ = serviceName(service)
+	}
+	return names
+}
+
+// mergeOverlay deep-merges overlay onto base and returns the result:
+//   - mappings merge key by key, recursively
+//   - a null value removes the key from base
+//   - services merge by name; unknown names are appended in overlay order
+//   - every other list (ports, volumes, depends_on, ...) replaces the base list
+//   - scalars replace the base value
+func mergeOverlay(base, overlay map[string]interface{}) map[string]interface{} {
+	for key, value := range overlay {
+		if value == nil {
+			delete(base, key)
+			continue
+		}
+
+		switch overlayValue := value.(type) {
+		case map[string]interface{}:
+			if baseValue, ok := base[key].(map[string]interface{}); ok {
+				base[key] = mergeOverlay(baseValue, overlayValue)
+				continue
+			}
+		case []interface{}:
+			if baseValue, ok := base[key].([]interface{}); ok && key == "services" {
+				base[key] = mergeServices(baseValue, overlayValue)
+				continue
+			}
+		}
+
+		base[key] = value
+	}
+
+	return base
+}
+
+// mergeServices merges overlay services onto base services matched by name
+func mergeServices(base, overlay []interface{}) []interface{} {
+	index := make(map[string]int)
+	for i, service := range base {
+		if name, ok := serviceName(service); ok {
+			index[name] = i
+		}
+	}
+
+	for _, service := range overlay {
+		name, ok := serviceName(service)
+		if i, exists := index[name]; ok && exists {
+			if baseService, isMap := base[i].(map[string]interface{}); isMap {
+				base[i] = mergeOverlay(baseService, service.(map[string]interface{}))
+				continue
+			}
+		}
+
+		base = append(base, service)
+		if ok {
+			index[name] = len(base) - 1
+		}
+	}
+
+	return base
+}
+
+func serviceName(service interface{}) (string, bool) {
+	fields, ok := service.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	name, ok := fields["name"].(string)
+	return name, ok
+}
@@ -0,0 +1,193 @@
+package presets
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func decodeMap(t *testing.T, text string) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := yaml.Unmarshal([]byte(text), &m); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return m
+}
+
+func TestMergeOverlay(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		overlay string
+		want    string
+	}{
+		{
+			name:    "scalars replaced",
+			base:    "name: shop\nversion: \"1\"\n",
+			overlay: "version: \"2\"\n",
+			want:    "name: shop\nversion: \"2\"\n",
+		},
+		{
+			name:    "mappings merged key by key",
+			base:    "variables:\n  A: \"1\"\n  B: \"1\"\n",
+			overlay: "variables:\n  B: \"2\"\n  C: \"2\"\n",
+			want:    "variables:\n  A: \"1\"\n  B: \"2\"\n  C: \"2\"\n",
+		},
+		{
+			name:    "null deletes a key",
+			base:    "description: shop\nvariables:\n  A: \"1\"\n  B: \"1\"\n",
+			overlay: "description: null\nvariables:\n  B: ~\n",
+			want:    "variables:\n  A: \"1\"\n",
+		},
+		{
+			name:    "ports list replaces the base list",
+			base:    "services:\n  - name: api\n    ports: [{container: 80}, {container: 443}]\n",
+			overlay: "services:\n  - name: api\n    ports: [{container: 8080}]\n",
+			want:    "services:\n  - name: api\n    ports: [{container: 8080}]\n",
+		},
+		{
+			name:    "services merged by name",
+			base:    "services:\n  - name: web\n    image: nginx\n  - name: api\n    image: app:1\n    environment: {A: \"1\", B: \"1\"}\n",
+			overlay: "services:\n  - name: api\n    image: app:2\n    environment: {B: null}\n",
+			want:    "services:\n  - name: web\n    image: nginx\n  - name: api\n    image: app:2\n    environment: {A: \"1\"}\n",
+		},
+		{
+			name:    "overlay-only services appended in order",
+			base:    "services:\n  - name: api\n",
+			overlay: "services:\n  - name: worker\n  - name: api\n    replicas: 2\n  - name: cron\n",
+			want:    "services:\n  - name: api\n    replicas: 2\n  - name: worker\n  - name: cron\n",
+		},
+		{
+			name:    "other lists replaced",
+			base:    "services:\n  - name: api\n    depends_on: [db, cache]\n",
+			overlay: "services:\n  - name: api\n    depends_on: [db]\n",
+			want:    "services:\n  - name: api\n    depends_on: [db]\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := mergeOverlay(decodeMap(t, test.base), decodeMap(t, test.overlay))
+			if want := decodeMap(t, test.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("mergeOverlay() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+const overlayBase = `schema_version: 2
+name: shop
+type: web-app
+variables:
+  LOG_LEVEL: debug
+services:
+  - name: web
+    type: web
+    image: nginx:1.25
+  - name: api
+    type: api
+    image: app:1
+`
+
+func TestLoadProjectEnvironment(t *testing.T) {
+	tests := []struct {
+		name    string
+		inline  string
+		file    string
+		image   string
+		level   string
+		wantErr string
+	}{
+		{
+			name:   "environments block",
+			inline: "environments:\n  production:\n    services:\n      - name: api\n        image: app:2\n",
+			image:  "app:2",
+			level:  "debug",
+		},
+		{
+			name:  "overlay file",
+			file:  "variables:\n  LOG_LEVEL: warn\n",
+			image: "app:1",
+			level: "warn",
+		},
+		{
+			name:   "overlay file applied after the environments block",
+			inline: "environments:\n  production:\n    variables:\n      LOG_LEVEL: info\n    services:\n      - name: api\n        image: app:2\n",
+			file:   "services:\n  - name: api\n    image: app:3\n",
+			image:  "app:3",
+			level:  "info",
+		},
+		{
+			name:    "no overlay",
+			wantErr: "no overlay for environment 'production'",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeProject(t, overlayBase+test.inline)
+			if test.file != "" {
+				if err := os.WriteFile(OverlayPath(path, "production"), []byte(test.file), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			config, err := NewManager().LoadProjectEnvironment(path, "production")
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("LoadProjectEnvironment() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadProjectEnvironment() error = %v", err)
+			}
+
+			if config.Environment != "production" || config.Environments != nil {
+				t.Errorf("environment = %q, environments = %v, want production and no block", config.Environment, config.Environments)
+			}
+			if config.Services[1].Image != test.image {
+				t.Errorf("api image = %s, want %s", config.Services[1].Image, test.image)
+			}
+			if config.Variables["LOG_LEVEL"] != test.level {
+				t.Errorf("LOG_LEVEL = %s, want %s", config.Variables["LOG_LEVEL"], test.level)
+			}
+		})
+	}
+}
+
+func TestLoadProjectEnvironmentSources(t *testing.T) {
+	path := writeProject(t, overlayBase+"environments:\n  production:\n    variables:\n      LOG_LEVEL: info\n")
+	overlay := OverlayPath(path, "production")
+	if err := os.WriteFile(overlay, []byte("services:\n  - name: worker\n    type: worker\n  - name: api\n    image: app:2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewManager().LoadProjectEnvironment(path, "production")
+	if err != nil {
+		t.Fatalf("LoadProjectEnvironment() error = %v", err)
+	}
+
+	tests := []struct {
+		field string
+		file  string
+		line  int
+	}{
+		{field: "name", file: path, line: 2},
+		{field: "services[0].image", file: path, line: 9},
+		{field: "services[1].type", file: path, line: 11},
+		{field: "variables.LOG_LEVEL", file: path, line: 16},
+		{field: "services[1].image", file: overlay, line: 5},
+		{field: "services[2].type", file: overlay, line: 3},
+		{field: "services[2]", file: overlay, line: 2},
+	}
+	for _, test := range tests {
+		pos, ok := config.Sources.Lookup(test.field)
+		if !ok || filepath.Clean(pos.File) != filepath.Clean(test.file) || pos.Line != test.line {
+			t.Errorf("%s at %s:%d, want %s:%d", test.field, pos.File, pos.Line, test.file, test.line)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package presets
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Types whose fields have absence rules of their own
+var (
+	serviceConfigType = reflect.TypeOf(types.ServiceConfig{})
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+// updateNode edits existing in place so it holds the same data as desired,
+// touching only what differs. Comments, key order, quoting and anchors of
+// unchanged parts are kept. t is the Go type desired was encoded from, which
+// tells struct fields apart from the keys of maps; nil means unknown. It
+// reports whether anything was modified.
+func updateNode(existing, desired *yaml.Node, t reflect.Type) bool {
+	if semanticEqual(existing, desired) {
+		return false
+	}
+
+	if existing.Kind != desired.Kind || existing.Kind == yaml.AliasNode {
+		replaceNode(existing, desired)
+		return true
+	}
+
+	switch existing.Kind {
+	case yaml.MappingNode:
+		return updateMapping(existing, desired, t)
+	case yaml.SequenceNode:
+		return updateSequence(existing, desired, t)
+	case yaml.ScalarNode:
+		if existing.Tag != desired.Tag && existing.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) == 0 {
+			existing.Style = desired.Style
+		}
+		existing.Value = desired.Value
+		existing.Tag = desired.Tag
+		return true
+	}
+
+	replaceNode(existing, desired)
+	return true
+}
+
+// updateMapping updates changed keys, inserts new keys after the key that
+// precedes them in desired and removes keys that are gone, keeping merge keys
+// (<<) and the zero values of struct fields that the struct leaves out. Keys
+// of maps, such as variables, are always written and removed as they are.
+func updateMapping(existing, desired *yaml.Node, t reflect.Type) bool {
+	changed := false
+	t = indirect(t)
+
+	wanted := make(map[string]*yaml.Node)
+	insertAt := 0
+	for i := 0; i+1 < len(desired.Content); i += 2 {
+		key, value := desired.Content[i], desired.Content[i+1]
+		wanted[key.Value] = value
+
+		if index := mappingIndex(existing, key.Value); index >= 0 {
+			if updateNode(existing.Content[index+1], value, valueType(t, key.Value)) {
+				changed = true
+			}
+			insertAt = index + 2
+		} else if !impliedByAbsence(t, key.Value, value) {
+			// A leading comment stays at the top of the mapping
+			if insertAt == 0 && len(existing.Content) > 0 {
+				key.HeadComment = existing.Content[0].HeadComment
+				existing.Content[0].HeadComment = ""
+			}
+			existing.Content = append(existing.Content[:insertAt], append([]*yaml.Node{key, value}, existing.Content[insertAt:]...)...)
+			insertAt += 2
+			changed = true
+		}
+	}
+
+	kept := existing.Content[:0]
+	for i := 0; i+1 < len(existing.Content); i += 2 {
+		key, value := existing.Content[i], existing.Content[i+1]
+		if _, exists := wanted[key.Value]; !exists && key.Value != "<<" && !impliedByAbsence(t, key.Value, value) {
+			changed = true
+			continue
+		}
+		kept = append(kept, key, value)
+	}
+	existing.Content = kept
+
+	return changed
+}
+
+// updateSequence matches mappings with a name key, such as services, by
+// name and everything else by index
+func updateSequence(existing, desired *yaml.Node, t reflect.Type) bool {
+	var item reflect.Type
+	if t = indirect(t); t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		item = t.Elem()
+	}
+
+	byName := make(map[string]*yaml.Node)
+	for _, item := range existing.Content {
+		if name := mappingValue(item, "name"); name != nil {
+			byName[name.Value] = item
+		}
+	}
+
+	changed := len(existing.Content) != len(desired.Content)
+	content := make([]*yaml.Node, 0, len(desired.Content))
+	for i, wanted := range desired.Content {
+		var current *yaml.Node
+		if name := mappingValue(wanted, "name"); name != nil {
+			current = byName[name.Value]
+		} else if i < len(existing.Content) {
+			current = existing.Content[i]
+		}
+
+		if current == nil {
+			content = append(content, wanted)
+			changed = true
+			continue
+		}
+		if current != existingItem(existing, i) {
+			changed = true
+		}
+		if updateNode(current, wanted, item) {
+			changed = true
+		}
+		content = append(content, current)
+	}
+	existing.Content = content
+
+	return changed
+}
+
+// mappingIndex returns the index of key in a mapping node's content, or -1
+func mappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func existingItem(sequence *yaml.Node, i int) *yaml.Node {
+	if i < len(sequence.Content) {
+		return sequence.Content[i]
+	}
+	return nil
+}
+
+// replaceNode overwrites a node with another, keeping its comments
+func replaceNode(existing, desired *yaml.Node) {
+	head, line, foot := existing.HeadComment, existing.LineComment, existing.FootComment
+	*existing = *desired
+	existing.HeadComment, existing.LineComment, existing.FootComment = head, line, foot
+}
+
+// impliedByAbsence reports whether leaving key out of a mapping of type t
+// means the same as value, so round trips neither add nor drop it. Only
+// struct fields qualify: omitempty fields holding their zero value,
+// timestamps that were never set, and the enabled field of services, which
+// defaults to true. Map keys never do, as an entry with an empty value
+// differs from no entry.
+func impliedByAbsence(t reflect.Type, key string, value *yaml.Node) bool {
+	field, ok := structField(t, key)
+	if !ok {
+		return false
+	}
+
+	// Services are enabled unless stated otherwise
+	if t == serviceConfigType && key == "enabled" {
+		return value.Tag == "!!bool" && value.Value == "true"
+	}
+
+	if field.Type == timeType {
+		return value.Tag == "!!timestamp" && value.Value == time.Time{}.Format(time.RFC3339)
+	}
+
+	if !strings.Contains(field.Tag.Get("yaml"), ",omitempty") {
+		return false
+	}
+
+	switch field.Type.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return value.Tag == "!!null"
+	case reflect.Slice, reflect.Map:
+		return value.Tag == "!!null" || value.Kind != yaml.ScalarNode && value.Kind != yaml.AliasNode && len(value.Content) == 0
+	}
+
+	switch value.Tag {
+	case "!!null":
+		return true
+	case "!!bool":
+		return value.Value == "false"
+	case "!!int":
+		return value.Value == "0"
+	case "!!str":
+		return value.Value == ""
+	}
+	return false
+}
+
+// structField returns the field of struct type t that key decodes into
+func structField(t reflect.Type, key string) (reflect.StructField, bool) {
+	if t == nil || t.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if name == key && field.IsExported() {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// valueType returns the type of the value under key in a mapping of type t,
+// or nil when it is not known
+func valueType(t reflect.Type, key string) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	if t.Kind() == reflect.Map {
+		return t.Elem()
+	}
+	if field, ok := structField(t, key); ok {
+		return field.Type
+	}
+	return nil
+}
+
+// indirect returns the type pointers of t point to
+func indirect(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// semanticEqual reports whether two nodes decode to the same data
+func semanticEqual(a, b *yaml.Node) bool {
+	var left, right interface{}
+	if a.Decode(&left) != nil || b.Decode(&right) != nil {
+		return false
+	}
+	return reflect.DeepEqual(normalize(left), normalize(right))
+}
+
+// normalize makes decoded values comparable across quoting styles, so that
+// "2024-01-01T00:00:00Z" equals the timestamp and "1.0" equals 1.0
+func normalize(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			normalized[key] = normalize(item)
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(typed))
+		for i, item := range typed {
+			normalized[i] = normalize(item)
+		}
+		return normalized
+	case time.Time:
+		return typed.UTC().Format(time.RFC3339Nano)
+	case nil:
+		return nil
+	}
+
+	var node yaml.Node
+	if node.Encode(value) == nil {
+		return node.Value
+	}
+	return value
+}
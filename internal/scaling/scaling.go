@@ -0,0 +1,101 @@
+package scaling
+
+import (
+	"fmt"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/ports"
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// Replicas returns the number of copies a service starts with: replicas
+// when set, otherwise the autoscaling minimum, otherwise 1
+func Replicas(service types.ServiceConfig) int {
+	switch {
+	case service.Replicas > 0:
+		return service.Replicas
+	case service.Autoscaling != nil && service.Autoscaling.Min > 0:
+		return service.Autoscaling.Min
+	}
+	return 1
+}
+
+// MaxReplicas returns the most copies a service can run
+func MaxReplicas(service types.ServiceConfig) int {
+	if service.Autoscaling != nil {
+		return max(service.Autoscaling.Max, Replicas(service))
+	}
+	return Replicas(service)
+}
+
+// Scaled reports whether a service runs, or may scale to, more than one copy
+func Scaled(service types.ServiceConfig) bool {
+	return service.Autoscaling != nil || MaxReplicas(service) > 1
+}
+
+// Validate reports replica counts and autoscaling settings out of range.
+// Constraints checks how they fit together.
+func Validate(config *types.ProjectConfig) types.ValidationErrors {
+	var errors types.ValidationErrors
+
+	for i, service := range config.Services {
+		field := fmt.Sprintf("services[%d]", i)
+		if service.Replicas < 0 {
+			errors.Add(field+".replicas", "must be at least 1", service.Replicas)
+		}
+		if autoscaling := service.Autoscaling; autoscaling != nil {
+			if autoscaling.Min < 1 {
+				errors.Add(field+".autoscaling.min", "must be at least 1", autoscaling.Min)
+			}
+			if autoscaling.Max < 1 {
+				errors.Add(field+".autoscaling.max", "must be at least 1", autoscaling.Max)
+			}
+			for _, target := range []struct {
+				name  string
+				value int
+			}{
+				{"target_cpu", autoscaling.TargetCPU},
+				{"target_memory", autoscaling.TargetMemory},
+			} {
+				if target.value < 0 || target.value > 100 {
+					errors.Add(field+".autoscaling."+target.name, "must be between 1 and 100", target.value)
+				}
+			}
+		}
+	}
+
+	return errors
+}
+
+// Constraints reports autoscaling ranges that are empty or exclude replicas,
+// autoscaling without a target, and scaled services that publish a fixed
+// host port, which only one copy per host can bind
+func Constraints(config *types.ProjectConfig) types.ValidationErrors {
+	var errors types.ValidationErrors
+
+	for i, service := range config.Services {
+		field := fmt.Sprintf("services[%d]", i)
+
+		if autoscaling := service.Autoscaling; autoscaling != nil {
+			if autoscaling.Max < autoscaling.Min {
+				errors.Add(field+".autoscaling.max", fmt.Sprintf("max %d is below min %d", autoscaling.Max, autoscaling.Min), autoscaling.Max)
+			}
+			if service.Replicas > 0 && (service.Replicas < autoscaling.Min || service.Replicas > autoscaling.Max) {
+				errors.Add(field+".replicas", fmt.Sprintf("%d replicas is outside autoscaling range %d-%d", service.Replicas, autoscaling.Min, autoscaling.Max), service.Replicas)
+			}
+			if autoscaling.TargetCPU == 0 && autoscaling.TargetMemory == 0 {
+				errors.Add(field+".autoscaling", "autoscaling needs target_cpu or target_memory", nil)
+			}
+		}
+
+		if !service.Enabled || !Scaled(service) {
+			continue
+		}
+		for j, port := range service.Ports {
+			if port.Host > 0 && ports.Exposure(port) == ports.Public {
+				errors.Add(fmt.Sprintf("%s.ports[%d].host", field, j), fmt.Sprintf("service '%s' runs up to %d replicas, which cannot all publish host port %s; remove host to let each replica get its own", service.Name, MaxReplicas(service), ports.Span(port.Host, ports.HostEnd(port))), port.Host)
+			}
+		}
+	}
+
+	return errors
+}
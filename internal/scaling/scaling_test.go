@@ -0,0 +1,154 @@
+package scaling
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+func TestReplicas(t *testing.T) {
+	tests := []struct {
+		name     string
+		service  types.ServiceConfig
+		replicas int
+		max      int
+		scaled   bool
+	}{
+		{name: "default", service: types.ServiceConfig{}, replicas: 1, max: 1},
+		{name: "replicas", service: types.ServiceConfig{Replicas: 3}, replicas: 3, max: 3, scaled: true},
+		{
+			name:     "autoscaling minimum",
+			service:  types.ServiceConfig{Autoscaling: &types.AutoscalingConfig{Min: 2, Max: 5}},
+			replicas: 2,
+			max:      5,
+			scaled:   true,
+		},
+		{
+			name:     "replicas within the autoscaling range",
+			service:  types.ServiceConfig{Replicas: 4, Autoscaling: &types.AutoscalingConfig{Min: 2, Max: 5}},
+			replicas: 4,
+			max:      5,
+			scaled:   true,
+		},
+		{
+			name:     "autoscaling to a single copy",
+			service:  types.ServiceConfig{Autoscaling: &types.AutoscalingConfig{Min: 1, Max: 1}},
+			replicas: 1,
+			max:      1,
+			scaled:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Replicas(tt.service); got != tt.replicas {
+				t.Errorf("Replicas() = %d, want %d", got, tt.replicas)
+			}
+			if got := MaxReplicas(tt.service); got != tt.max {
+				t.Errorf("MaxReplicas() = %d, want %d", got, tt.max)
+			}
+			if got := Scaled(tt.service); got != tt.scaled {
+				t.Errorf("Scaled() = %v, want %v", got, tt.scaled)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		service types.ServiceConfig
+		want    []string
+	}{
+		{
+			name:    "valid",
+			service: types.ServiceConfig{Replicas: 2, Autoscaling: &types.AutoscalingConfig{Min: 2, Max: 10, TargetCPU: 70}},
+		},
+		{name: "negative replicas", service: types.ServiceConfig{Replicas: -1}, want: []string{"replicas': must be at least 1"}},
+		{
+			name:    "autoscaling out of range",
+			service: types.ServiceConfig{Autoscaling: &types.AutoscalingConfig{Min: 0, Max: 0, TargetCPU: 101, TargetMemory: -5}},
+			want: []string{
+				"autoscaling.min': must be at least 1",
+				"autoscaling.max': must be at least 1",
+				"autoscaling.target_cpu': must be between 1 and 100",
+				"autoscaling.target_memory': must be between 1 and 100",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErrors(t, "Validate", Validate(&types.ProjectConfig{Services: []types.ServiceConfig{tt.service}}), tt.want)
+		})
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	tests := []struct {
+		name    string
+		service types.ServiceConfig
+		want    []string
+	}{
+		{
+			name:    "scaled without host ports",
+			service: types.ServiceConfig{Replicas: 3, Ports: []types.PortConfig{{Container: 80}}},
+		},
+		{
+			name:    "single copy on a host port",
+			service: types.ServiceConfig{Ports: []types.PortConfig{{Host: 80, Container: 80}}},
+		},
+		{
+			name:    "empty range",
+			service: types.ServiceConfig{Autoscaling: &types.AutoscalingConfig{Min: 5, Max: 2, TargetCPU: 50}},
+			want:    []string{"autoscaling.max': max 2 is below min 5"},
+		},
+		{
+			name:    "replicas outside the range",
+			service: types.ServiceConfig{Replicas: 8, Autoscaling: &types.AutoscalingConfig{Min: 2, Max: 5, TargetCPU: 50}},
+			want:    []string{"replicas': 8 replicas is outside autoscaling range 2-5"},
+		},
+		{
+			name:    "no target",
+			service: types.ServiceConfig{Autoscaling: &types.AutoscalingConfig{Min: 1, Max: 3}},
+			want:    []string{"autoscaling needs target_cpu or target_memory"},
+		},
+		{
+			name:    "replicas on a host port",
+			service: types.ServiceConfig{Replicas: 2, Ports: []types.PortConfig{{Host: 8080, Container: 80}}},
+			want:    []string{"ports[0].host': service 'api' runs up to 2 replicas, which cannot all publish host port 8080"},
+		},
+		{
+			name:    "autoscaling on a host port",
+			service: types.ServiceConfig{Autoscaling: &types.AutoscalingConfig{Min: 1, Max: 4, TargetCPU: 50}, Ports: []types.PortConfig{{Host: 8080, Container: 80}}},
+			want:    []string{"runs up to 4 replicas"},
+		},
+		{
+			name:    "internal port",
+			service: types.ServiceConfig{Replicas: 2, Ports: []types.PortConfig{{Container: 80, Exposure: "internal"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := tt.service
+			service.Name = "api"
+			service.Enabled = true
+			checkErrors(t, "Constraints", Constraints(&types.ProjectConfig{Services: []types.ServiceConfig{service}}), tt.want)
+		})
+	}
+}
+
+// checkErrors reports a mismatch between errors and the wanted substrings
+func checkErrors(t *testing.T, function string, errors types.ValidationErrors, want []string) {
+	t.Helper()
+	if len(errors) != len(want) {
+		t.Errorf("%s() reported %d errors, want %d: %v", function, len(errors), len(want), errors)
+	}
+	for _, w := range want {
+		if !strings.Contains(errors.Error(), w) {
+			t.Errorf("%s() = %v, want %q", function, errors, w)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// Draft is the JSON Schema dialect of the generated schemas
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// paramReference matches a whole "{{ params.name }}" preset placeholder
+const paramReference = `^\{\{\s*params\.[A-Za-z_][A-Za-z0-9_]*\s*\}\}$`
+
+// enums lists the allowed values of the string types of pkg/types
+var enums = map[reflect.Type][]string{
+	reflect.TypeOf(types.ProjectType("")): {
+		string(types.ProjectTypeWebApp),
+		string(types.ProjectTypeMicroservice),
+		string(types.ProjectTypeDatabase),
+		string(types.ProjectTypeML),
+		string(types.ProjectTypeInfrastructure),
+	},
+	reflect.TypeOf(types.PresetKind("")): {
+		string(types.PresetKindPreset),
+		string(types.PresetKindMixin),
+	},
+	reflect.TypeOf(types.ParameterType("")): {
+		string(types.ParameterTypeString),
+		string(types.ParameterTypeInt),
+		string(types.ParameterTypeBool),
+		string(types.ParameterTypeEnum),
+	},
+}
+
+// Schema is a JSON Schema document or subschema
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	Minimum              *int               `json:"minimum,omitempty"`
+	Maximum              *int               `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Closed               bool               `json:"-"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// MarshalJSON writes closed objects with "additionalProperties": false
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	if !s.Closed {
+		return json.Marshal((*plain)(s))
+	}
+	return json.Marshal(struct {
+		*plain
+		AdditionalProperties bool `json:"additionalProperties"`
+	}{(*plain)(s), false})
+}
+
+// generator builds schemas from Go types, collecting named structs in $defs
+type generator struct {
+	defs map[string]*Schema
+
+	// params allows "{{ params.name }}" wherever a non-string value is expected
+	params bool
+}
+
+// Project returns the JSON Schema of infra-gen.yml
+func Project() *Schema {
+	return document("https://github.com/kishininfosec/infra-gen/schema/project.json", "infra-gen project", reflect.TypeOf(types.ProjectConfig{}), false)
+}
+
+// Preset returns the JSON Schema of preset and mixin definition files
+func Preset() *Schema {
+	return document("https://github.com/kishininfosec/infra-gen/schema/preset.json", "infra-gen preset", reflect.TypeOf(types.Preset{}), true)
+}
+
+func document(id, title string, t reflect.Type, params bool) *Schema {
+	g := &generator{defs: make(map[string]*Schema), params: params}
+
+	root := g.structSchema(t)
+	root.Schema = Draft
+	root.ID = id
+	root.Title = title
+	root.Defs = g.defs
+
+	return root
+}
+
+// schemaFor returns the schema of a type, referencing named structs
+func (g *generator) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if values, exists := enums[t]; exists {
+		return g.allowParams(&Schema{Type: "string", Enum: values})
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		if _, exists := g.defs[t.Name()]; !exists {
+			g.defs[t.Name()] = nil // reserve the name while recursing
+			g.defs[t.Name()] = g.structSchema(t)
+		}
+		return &Schema{Ref: "#/$defs/" + t.Name()}
+	case reflect.Slice:
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return g.allowParams(&Schema{Type: "boolean"})
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return g.allowParams(&Schema{Type: "integer"})
+	case reflect.Float32, reflect.Float64:
+		return g.allowParams(&Schema{Type: "number"})
+	}
+
+	// interface{} and anything else accept any value
+	return &Schema{}
+}
+
+// structSchema returns the closed object schema of a struct from its yaml
+// and schema tags
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema), Closed: true}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		property := g.schemaFor(field.Type)
+		if tag := field.Tag.Get("schema"); tag != "" {
+			if err := g.applyTag(property, tag); err != nil {
+				panic(fmt.Sprintf("schema tag of %s.%s: %v", t.Name(), field.Name, err))
+			}
+			if hasRule(tag, "required") {
+				s.Required = append(s.Required, name)
+			}
+		}
+		s.Properties[name] = property
+	}
+
+	return s
+}
+
+// applyTag applies the rules of a schema struct tag, such as
+// `schema:"required,minimum=1,maximum=65535"` or `schema:"enum=tcp|udp"`
+func (g *generator) applyTag(s *Schema, tag string) error {
+	target := s
+	if len(s.AnyOf) > 0 {
+		target = s.AnyOf[0]
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			// Required strings and lists must also be non-empty
+			switch target.Type {
+			case "string":
+				target.MinLength = intPtr(1)
+			case "array":
+				target.MinItems = intPtr(1)
+			}
+		case "enum":
+			target.Enum = strings.Split(value, "|")
+		case "pattern":
+			target.Pattern = value
+		case "minimum", "maximum", "minItems":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+			switch key {
+			case "minimum":
+				target.Minimum = intPtr(n)
+			case "maximum":
+				target.Maximum = intPtr(n)
+			default:
+				target.MinItems = intPtr(n)
+			}
+		default:
+			return fmt.Errorf("unknown rule %q", key)
+		}
+	}
+
+	return nil
+}
+
+// allowParams lets preset files use a parameter placeholder for a value
+func (g *generator) allowParams(s *Schema) *Schema {
+	if !g.params {
+		return s
+	}
+	return &Schema{AnyOf: []*Schema{s, {Type: "string", Pattern: paramReference}}}
+}
+
+func hasRule(tag, name string) bool {
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+func intPtr(n int) *int {
+	return &n
+}
@@ -0,0 +1,136 @@
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func decode(t *testing.T, text string) interface{} {
+	t.Helper()
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(text), &value); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return value
+}
+
+const validProject = `schema_version: 2
+name: shop
+type: web-app
+services:
+  - name: api
+    type: api
+    ports:
+      - {container: 8080, host: 80, protocol: udp}
+`
+
+func TestProjectValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		project string
+		want    []string
+	}{
+		{name: "valid", project: validProject},
+		{
+			name:    "required fields",
+			project: "services: []\n",
+			want:    []string{"'name': name is required", "'type': type is required", "'services': at least 1 item(s) required"},
+		},
+		{
+			name:    "empty required string",
+			project: "name: \"\"\ntype: web-app\nservices: [{name: api, type: api}]\n",
+			want:    []string{"'name': name is required"},
+		},
+		{
+			name:    "unknown field",
+			project: "name: shop\ntype: web-app\nservices: [{name: api, type: api, replica: 2}]\n",
+			want:    []string{"'services[0].replica': unknown field 'replica'"},
+		},
+		{
+			name:    "wrong type",
+			project: "name: shop\ntype: web-app\nservices: [{name: api, type: api, ports: 8080}]\n",
+			want:    []string{"'services[0].ports': must be an array"},
+		},
+		{
+			name:    "enum",
+			project: "name: shop\ntype: website\nservices: [{name: api, type: api, ports: [{container: 80, protocol: icmp}]}]\n",
+			want:    []string{"'type': must be one of web-app, microservice", "'services[0].ports[0].protocol': must be one of tcp, udp"},
+		},
+		{
+			name:    "range",
+			project: "name: shop\ntype: web-app\nservices: [{name: api, type: api, replicas: 0, ports: [{container: 70000}]}]\n",
+			want:    []string{"'services[0].replicas': must be at least 1", "'services[0].ports[0].container': must be between 1 and 65535"},
+		},
+		{
+			name:    "integer",
+			project: "name: shop\ntype: web-app\nservices: [{name: api, type: api, ports: [{container: 80.5}]}]\n",
+			want:    []string{"'services[0].ports[0].container': must be an integer"},
+		},
+		{
+			name:    "map values",
+			project: "name: shop\ntype: web-app\nvariables: {A: [1]}\nservices: [{name: api, type: api}]\n",
+			want:    []string{"'variables.A': must be a string"},
+		},
+	}
+
+	document := Project()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errors := document.Validate(decode(t, tt.project))
+			if len(errors) != len(tt.want) {
+				t.Errorf("Validate() reported %d errors, want %d: %v", len(errors), len(tt.want), errors)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(errors.Error(), want) {
+					t.Errorf("Validate() = %v, want %q", errors, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPresetParams(t *testing.T) {
+	preset := "id: shop\nservices:\n  - name: api\n    replicas: \"{{ params.replicas }}\"\n    ports: [{container: \"{{params.port}}\"}]\n"
+	if errors := Preset().Validate(decode(t, preset)); errors.HasErrors() {
+		t.Errorf("Preset().Validate() = %v, want parameter placeholders accepted", errors)
+	}
+
+	bad := "id: shop\nservices:\n  - name: api\n    replicas: \"{{ replicas }}\"\n"
+	if errors := Preset().Validate(decode(t, bad)); !strings.Contains(errors.Error(), "'services[0].replicas': must be an integer") {
+		t.Errorf("Preset().Validate() = %v, want a malformed placeholder refused", errors)
+	}
+
+	project := "name: shop\ntype: web-app\nservices: [{name: api, type: api, replicas: \"{{ params.replicas }}\"}]\n"
+	if errors := Project().Validate(decode(t, project)); !strings.Contains(errors.Error(), "'services[0].replicas': must be an integer") {
+		t.Errorf("Project().Validate() = %v, want placeholders refused outside presets", errors)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(Project())
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatalf("schema is not JSON: %v", err)
+	}
+	if document["$schema"] != Draft || document["additionalProperties"] != false {
+		t.Errorf("schema = %s, want the draft and a closed root object", data)
+	}
+
+	defs, _ := document["$defs"].(map[string]interface{})
+	port, _ := defs["PortConfig"].(map[string]interface{})
+	if port["additionalProperties"] != false || !strings.Contains(string(data), `"#/$defs/PortConfig"`) {
+		t.Errorf("PortConfig = %v, want a closed definition referenced from services", port)
+	}
+	properties, _ := port["properties"].(map[string]interface{})
+	container, _ := properties["container"].(map[string]interface{})
+	if container["minimum"] != 1.0 || container["maximum"] != 65535.0 {
+		t.Errorf("container = %v, want minimum 1 and maximum 65535", container)
+	}
+}
@@ -0,0 +1,213 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// validator checks decoded YAML values against a schema document
+type validator struct {
+	root   *Schema
+	errors types.ValidationErrors
+}
+
+// Validate checks a decoded YAML value, such as the result of unmarshalling
+// into interface{}, against a root schema. Every violation is reported at its
+// field path, e.g. services[0].ports[1].container.
+func (s *Schema) Validate(value interface{}) types.ValidationErrors {
+	v := &validator{root: s}
+	v.check(s, value, "")
+	return v.errors
+}
+
+func (v *validator) check(s *Schema, value interface{}, path string) {
+	if s.Ref != "" {
+		s = v.resolve(s.Ref)
+	}
+
+	if len(s.AnyOf) > 0 {
+		var first types.ValidationErrors
+		for i, option := range s.AnyOf {
+			nested := &validator{root: v.root}
+			nested.check(option, value, path)
+			if !nested.errors.HasErrors() {
+				return
+			}
+			if i == 0 {
+				first = nested.errors
+			}
+		}
+		v.errors = append(v.errors, first...)
+		return
+	}
+
+	if s.Type != "" && !hasType(value, s.Type) {
+		v.errors.Add(path, fmt.Sprintf("must be %s %s", article(s.Type), s.Type), value)
+		return
+	}
+
+	switch typed := value.(type) {
+	case string:
+		v.checkString(s, typed, path)
+	case int, int64, uint64, float64:
+		v.checkNumber(s, toFloat(typed), path)
+	case []interface{}:
+		if s.MinItems != nil && len(typed) < *s.MinItems {
+			v.errors.Add(path, fmt.Sprintf("at least %d item(s) required", *s.MinItems), len(typed))
+		}
+		if s.Items != nil {
+			for i, item := range typed {
+				v.check(s.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case map[string]interface{}:
+		v.checkObject(s, typed, path)
+	}
+}
+
+func (v *validator) checkString(s *Schema, value, path string) {
+	if s.MinLength != nil && len(value) < *s.MinLength {
+		v.errors.Add(path, fmt.Sprintf("%s is required", fieldName(path)), value)
+		return
+	}
+	if len(s.Enum) > 0 && !contains(s.Enum, value) {
+		v.errors.Add(path, fmt.Sprintf("must be one of %s", strings.Join(s.Enum, ", ")), value)
+	}
+	if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(value) {
+		v.errors.Add(path, fmt.Sprintf("must match %s", s.Pattern), value)
+	}
+}
+
+func (v *validator) checkNumber(s *Schema, value float64, path string) {
+	outOfRange := (s.Minimum != nil && value < float64(*s.Minimum)) || (s.Maximum != nil && value > float64(*s.Maximum))
+	if !outOfRange {
+		return
+	}
+
+	switch {
+	case s.Minimum != nil && s.Maximum != nil:
+		v.errors.Add(path, fmt.Sprintf("must be between %d and %d", *s.Minimum, *s.Maximum), value)
+	case s.Minimum != nil:
+		v.errors.Add(path, fmt.Sprintf("must be at least %d", *s.Minimum), value)
+	default:
+		v.errors.Add(path, fmt.Sprintf("must be at most %d", *s.Maximum), value)
+	}
+}
+
+func (v *validator) checkObject(s *Schema, value map[string]interface{}, path string) {
+	for _, name := range s.Required {
+		if _, exists := value[name]; !exists {
+			v.errors.Add(joinPath(path, name), fmt.Sprintf("%s is required", name), nil)
+		}
+	}
+
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPath := joinPath(path, key)
+		if property, exists := s.Properties[key]; exists {
+			v.check(property, value[key], childPath)
+		} else if s.Closed {
+			v.errors.Add(childPath, fmt.Sprintf("unknown field '%s'", key), key)
+		} else if s.AdditionalProperties != nil {
+			v.check(s.AdditionalProperties, value[key], childPath)
+		}
+	}
+}
+
+// resolve returns the schema a local "#/$defs/Name" reference points to
+func (v *validator) resolve(ref string) *Schema {
+	name := strings.TrimPrefix(ref, "#/$defs/")
+	if def, exists := v.root.Defs[name]; exists {
+		return def
+	}
+	panic(fmt.Sprintf("unresolved schema reference %s", ref))
+}
+
+// hasType reports whether a decoded YAML value has a JSON Schema type
+func hasType(value interface{}, schemaType string) bool {
+	switch schemaType {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		switch value.(type) {
+		case string, time.Time:
+			return true
+		}
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		switch typed := value.(type) {
+		case int, int64, uint64:
+			return true
+		case float64:
+			return typed == math.Trunc(typed)
+		}
+	case "number":
+		switch value.(type) {
+		case int, int64, uint64, float64:
+			return true
+		}
+	}
+	return false
+}
+
+func toFloat(value interface{}) float64 {
+	switch typed := value.(type) {
+	case int:
+		return float64(typed)
+	case int64:
+		return float64(typed)
+	case uint64:
+		return float64(typed)
+	case float64:
+		return typed
+	}
+	return 0
+}
+
+func article(schemaType string) string {
+	if schemaType == "object" || schemaType == "array" || schemaType == "integer" {
+		return "an"
+	}
+	return "a"
+}
+
+// fieldName returns the last key of a field path
+func fieldName(path string) string {
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,96 @@
+package secrets
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// plainValue matches .env values that need no quotes
+var plainValue = regexp.MustCompile(`^[A-Za-z0-9_./:@+=-]*$`)
+
+// ReadEnv reads the KEY=VALUE lines of a .env file, skipping comments and
+// unquoting quoted values. A missing file has no values.
+func ReadEnv(path string) (map[string]string, error) {
+	values := make(map[string]string)
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !found {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil && strings.HasPrefix(value, "\"") {
+			value = unquoted
+		} else if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return values, nil
+}
+
+// EnvLine formats a KEY=VALUE line of a .env file. Values with characters
+// Compose would interpret are single-quoted, which keeps them literal, or
+// double-quoted when they contain a single quote.
+func EnvLine(key, value string) string {
+	switch {
+	case plainValue.MatchString(value):
+		return key + "=" + value
+	case !strings.Contains(value, "'"):
+		return key + "='" + value + "'"
+	}
+	return key + "=" + strconv.Quote(value)
+}
+
+// Values returns the values of the generated secrets that enabled services
+// use, keyed by secret name. A value already in existing, a .env keyed by
+// variable, is kept unless rotate is set; all others are generated, and
+// their names returned.
+func Values(config *types.ProjectConfig, existing map[string]string, rotate bool) (map[string]string, []string, error) {
+	values := make(map[string]string)
+	var generated []string
+
+	for _, name := range Used(config) {
+		secret := config.Secrets[name]
+		if Source(secret) != Generate {
+			continue
+		}
+		if value, exists := existing[EnvVar(name, secret)]; exists && value != "" && !rotate {
+			values[name] = value
+			continue
+		}
+
+		value, err := NewValue(secret.Generate)
+		if err != nil {
+			return nil, nil, err
+		}
+		values[name] = value
+		generated = append(generated, name)
+	}
+
+	return values, generated, nil
+}
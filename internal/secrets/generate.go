@@ -0,0 +1,228 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/interpolation"
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// Defaults of generated secrets that do not set a length or alphabet
+const (
+	DefaultLength   = 32
+	DefaultAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// MinEntropy is the fewest bits of entropy a generated secret may have
+const MinEntropy = 128
+
+// sensitiveKeywords mark variable names that hold secrets
+var sensitiveKeywords = []string{"password", "secret", "key", "token", "auth"}
+
+// placeholders are values presets use for secrets the user has to set
+var placeholders = regexp.MustCompile(`(?i)^(|change[-_]?me|change[-_]?it|password|secret|todo|x+|secure-[a-z0-9-]*-pass)$`)
+
+// nonIdentifier matches the characters secret names cannot contain
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// Length returns the number of characters of a generated secret
+func Length(generate *types.GenerateConfig) int {
+	if generate.Length > 0 {
+		return generate.Length
+	}
+	return DefaultLength
+}
+
+// Alphabet returns the characters a generated secret is drawn from
+func Alphabet(generate *types.GenerateConfig) []rune {
+	if generate.Alphabet != "" {
+		return []rune(generate.Alphabet)
+	}
+	return []rune(DefaultAlphabet)
+}
+
+// Entropy returns the bits of entropy of a generated secret
+func Entropy(generate *types.GenerateConfig) float64 {
+	return float64(Length(generate)) * math.Log2(float64(len(Alphabet(generate))))
+}
+
+// NewValue returns a random value for a generated secret, drawing each
+// character uniformly from its alphabet with crypto/rand
+func NewValue(generate *types.GenerateConfig) (string, error) {
+	alphabet := Alphabet(generate)
+	size := big.NewInt(int64(len(alphabet)))
+
+	var value strings.Builder
+	for i := 0; i < Length(generate); i++ {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate secret: %w", err)
+		}
+		value.WriteRune(alphabet[n.Int64()])
+	}
+	return value.String(), nil
+}
+
+// Sensitive reports whether a variable name looks like it holds a secret
+func Sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, keyword := range sensitiveKeywords {
+		if strings.Contains(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPlaceholder reports whether a value is unset or a stand-in such as
+// change-me that must never be deployed
+func IsPlaceholder(value string) bool {
+	return placeholders.MatchString(strings.TrimSpace(value))
+}
+
+// Adopt turns sensitive project variables and service environment values
+// that hold placeholders into generated secrets. Services that use such a
+// variable as a whole environment value, such as ${DB_PASSWORD}, get a
+// reference to the secret instead; variables used any other way are kept.
+// It returns the names of the new secrets, sorted.
+func Adopt(config *types.ProjectConfig) []string {
+	var adopted []string
+	add := func(name, description string) bool {
+		if _, exists := config.Secrets[name]; exists {
+			return false
+		}
+		if config.Secrets == nil {
+			config.Secrets = make(map[string]types.SecretConfig)
+		}
+		config.Secrets[name] = types.SecretConfig{Description: description, Generate: &types.GenerateConfig{}}
+		adopted = append(adopted, name)
+		return true
+	}
+
+	for _, key := range sortedKeys(config.Variables) {
+		if !Sensitive(key) || !IsPlaceholder(config.Variables[key]) {
+			continue
+		}
+		uses, whole := variableUses(config, key)
+		if !whole {
+			continue
+		}
+
+		var users []string
+		for _, use := range uses {
+			users = append(users, config.Services[use.service].Name)
+		}
+		description := fmt.Sprintf("%s (variable %s)", kind(key), key)
+		if len(users) > 0 {
+			description = fmt.Sprintf("%s for %s", kind(key), strings.Join(users, ", "))
+		}
+
+		name := strings.ToLower(key)
+		if !add(name, description) {
+			continue
+		}
+		delete(config.Variables, key)
+		for _, use := range uses {
+			service := &config.Services[use.service]
+			delete(service.Environment, use.env)
+			service.Secrets = append(service.Secrets, types.SecretRef{Name: name, Env: use.env})
+		}
+	}
+
+	for i := range config.Services {
+		service := &config.Services[i]
+		for _, key := range sortedKeys(service.Environment) {
+			value := service.Environment[key]
+			if !Sensitive(key) || !IsPlaceholder(value) {
+				continue
+			}
+			name := strings.ToLower(nonIdentifier.ReplaceAllString(service.Name+"_"+key, "_"))
+			if !add(name, fmt.Sprintf("%s for %s", kind(key), service.Name)) {
+				continue
+			}
+			delete(service.Environment, key)
+			service.Secrets = append(service.Secrets, types.SecretRef{Name: name, Env: key})
+		}
+	}
+
+	sort.Strings(adopted)
+	return adopted
+}
+
+// use is an environment variable of a service whose whole value is a
+// reference to a project variable
+type use struct {
+	service int
+	env     string
+}
+
+// variableUses returns the environment values that are exactly a reference
+// to a variable, and whether the variable is referenced nowhere else
+func variableUses(config *types.ProjectConfig, variable string) ([]use, bool) {
+	references := func(value string) bool {
+		for _, ref := range interpolation.Parse(value) {
+			if ref.Name == variable {
+				return true
+			}
+		}
+		return false
+	}
+
+	for key, value := range config.Variables {
+		if key != variable && references(value) {
+			return nil, false
+		}
+	}
+
+	var uses []use
+	for i, service := range config.Services {
+		if references(service.Image) {
+			return nil, false
+		}
+		for _, volume := range service.Volumes {
+			if references(volume.Source) || references(volume.Target) {
+				return nil, false
+			}
+		}
+		for _, key := range sortedKeys(service.Environment) {
+			value := service.Environment[key]
+			if !references(value) {
+				continue
+			}
+			if value != "${"+variable+"}" {
+				return nil, false
+			}
+			uses = append(uses, use{i, key})
+		}
+	}
+	return uses, true
+}
+
+// kind names what a secret variable holds, for descriptions
+func kind(name string) string {
+	upper := strings.ToUpper(name)
+	switch {
+	case strings.Contains(upper, "PASSWORD"):
+		return "Password"
+	case strings.Contains(upper, "TOKEN"):
+		return "Token"
+	case strings.Contains(upper, "KEY"):
+		return "Key"
+	}
+	return "Secret"
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
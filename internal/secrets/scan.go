@@ -0,0 +1,189 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/interpolation"
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// Thresholds of the scanner
+const (
+	// MinValueLength is the length below which secret values are not
+	// searched for, as they would match ordinary text
+	MinValueLength = 6
+	// MinTokenLength is the length from which strings are checked for entropy
+	MinTokenLength = 24
+	// TokenEntropy is the Shannon entropy per character from which a string
+	// looks random
+	TokenEntropy = 4.0
+)
+
+// tokenPatterns match credentials with a well-known format
+var tokenPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"AWS access key ID", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	{"GitHub token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"Stripe key", regexp.MustCompile(`\b[rs]k_live_[A-Za-z0-9]{20,}\b`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"JSON web token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{"password in URL", regexp.MustCompile(`[a-z][a-z0-9+.-]*://[^/\s:@]+:[^/\s:@${}]{3,}@`)},
+}
+
+// token matches strings that are checked for entropy
+var token = regexp.MustCompile(`[A-Za-z0-9+/=_-]{` + fmt.Sprint(MinTokenLength) + `,}`)
+
+// Finding is a line of a generated file that looks like it holds a secret
+type Finding struct {
+	Path string
+	Line int
+	// Reason says what matched, without the matched text
+	Reason string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s", f.Path, f.Line, f.Reason)
+}
+
+// Scanner finds secrets in generated files: the values of the project's
+// secrets and sensitive variables, credentials with a known format and
+// random-looking strings
+type Scanner struct {
+	// values maps secret values to what they are the value of
+	values map[string]string
+}
+
+// NewScanner creates a scanner for the values of a project's sensitive
+// variables and environment values, as the resolver sees them, and of its
+// secrets. values holds the secret values known to infra-gen, keyed by
+// secret name; env secrets are read from the environment. Placeholders are
+// not secret and never searched for.
+func NewScanner(config *types.ProjectConfig, resolver *interpolation.Resolver, values map[string]string) (*Scanner, error) {
+	scanner := &Scanner{values: make(map[string]string)}
+
+	variables, err := resolver.Variables()
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range variables {
+		if Sensitive(name) {
+			scanner.add(value, "value of variable "+name)
+		}
+	}
+
+	for _, service := range config.Services {
+		for key, value := range service.Environment {
+			if !Sensitive(key) {
+				continue
+			}
+			resolved, err := resolver.Resolve(value)
+			if err != nil {
+				return nil, err
+			}
+			scanner.add(resolved, fmt.Sprintf("value of %s of service %s", key, service.Name))
+		}
+	}
+
+	for name, secret := range config.Secrets {
+		if Source(secret) == Env {
+			scanner.add(os.Getenv(secret.Env), "value of secret "+name)
+		}
+	}
+	for name, value := range values {
+		scanner.add(value, "value of secret "+name)
+	}
+
+	return scanner, nil
+}
+
+func (s *Scanner) add(value, description string) {
+	if len(value) < MinValueLength || IsPlaceholder(value) {
+		return
+	}
+	if _, exists := s.values[value]; !exists {
+		s.values[value] = description
+	}
+	// Kubernetes Secrets carry values base64 encoded
+	encoded := base64.StdEncoding.EncodeToString([]byte(value))
+	if _, exists := s.values[encoded]; !exists {
+		s.values[encoded] = "base64 " + description
+	}
+}
+
+// Scan returns the lines of a file that look like they hold a secret, at
+// most one finding per line. Sensitive files are meant to hold secrets and
+// vaults are encrypted, so neither is scanned.
+func (s *Scanner) Scan(file types.GeneratedFile) []Finding {
+	if file.Sensitive || IsVault(file.Content) {
+		return nil
+	}
+
+	// Longer values first, so a value is reported rather than its prefix
+	values := make([]string, 0, len(s.values))
+	for value := range s.values {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+
+	var findings []Finding
+	for i, line := range strings.Split(file.Content, "\n") {
+		if reason := s.check(line, values); reason != "" {
+			findings = append(findings, Finding{Path: file.Path, Line: i + 1, Reason: reason})
+		}
+	}
+	return findings
+}
+
+// check returns why a line looks like it holds a secret, or ""
+func (s *Scanner) check(line string, values []string) string {
+	for _, value := range values {
+		if strings.Contains(line, value) {
+			return s.values[value]
+		}
+	}
+	for _, known := range tokenPatterns {
+		if known.pattern.MatchString(line) {
+			return known.name
+		}
+	}
+	for _, candidate := range token.FindAllString(line, -1) {
+		if bits := shannonEntropy(candidate); bits >= TokenEntropy && hasLetterAndDigit(candidate) {
+			return fmt.Sprintf("random-looking string of %d characters (%.1f bits per character)", len(candidate), bits)
+		}
+	}
+	return ""
+}
+
+// shannonEntropy returns the entropy per character of a string
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var entropy float64
+	for _, count := range counts {
+		p := float64(count) / float64(len(s))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+func hasLetterAndDigit(s string) bool {
+	return strings.IndexFunc(s, unicode.IsLetter) >= 0 && strings.IndexFunc(s, unicode.IsDigit) >= 0
+}
@@ -0,0 +1,184 @@
+package secrets
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// Sources a secret value can come from
+const (
+	// Generate secrets get a random value generated by infra-gen
+	Generate = "generate"
+	// File secrets are read from a file
+	File = "file"
+	// Env secrets are read from an environment variable
+	Env = "env"
+	// External secrets live in a secret store and are read at deploy time
+	External = "external"
+)
+
+// identifier matches secret and environment variable names
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Source returns the source a secret names, or "" when it names none
+func Source(secret types.SecretConfig) string {
+	switch {
+	case secret.Generate != nil:
+		return Generate
+	case secret.File != "":
+		return File
+	case secret.Env != "":
+		return Env
+	case secret.External != "":
+		return External
+	}
+	return ""
+}
+
+// EnvVar returns the environment variable that carries a secret's value at
+// deploy time: the variable an env secret reads, otherwise the secret name
+// in upper case
+func EnvVar(name string, secret types.SecretConfig) string {
+	if secret.Env != "" {
+		return secret.Env
+	}
+	return strings.ToUpper(name)
+}
+
+// Used returns the names of the secrets that enabled services reference,
+// sorted
+func Used(config *types.ProjectConfig) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, service := range config.Services {
+		if !service.Enabled {
+			continue
+		}
+		for _, ref := range service.Secrets {
+			if _, exists := config.Secrets[ref.Name]; exists && !seen[ref.Name] {
+				seen[ref.Name] = true
+				names = append(names, ref.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate reports secrets that do not name exactly one source, generated
+// secrets that are too weak, invalid secret and variable names, project
+// variables that look like secrets or share a secret's name, references to
+// unknown secrets and environment variables set both by a secret and in a
+// service's environment
+func Validate(config *types.ProjectConfig) types.ValidationErrors {
+	var errors types.ValidationErrors
+
+	// Variables are written in plain text to generated files, so secrets
+	// must be declared as such rather than guessed from variable names
+	for _, key := range sortedKeys(config.Variables) {
+		field := "variables." + key
+		if _, exists := config.Secrets[key]; exists {
+			errors.Add(field, fmt.Sprintf("variable '%s' has the name of a secret; rename one of them", key), key)
+		}
+		if Sensitive(key) {
+			errors.Add(field, fmt.Sprintf("variable '%s' looks like a secret; declare it under secrets (e.g. %s: {generate: {}}) and reference it from services", key, strings.ToLower(key)), key)
+		}
+	}
+
+	names := make([]string, 0, len(config.Secrets))
+	for name := range config.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		secret := config.Secrets[name]
+		field := "secrets." + name
+
+		if !identifier.MatchString(name) {
+			errors.Add(field, fmt.Sprintf("secret name '%s' must start with a letter or underscore and contain only letters, digits and underscores", name), name)
+		}
+
+		var sources []string
+		for _, source := range []struct {
+			name string
+			set  bool
+		}{
+			{Generate, secret.Generate != nil},
+			{File, secret.File != ""},
+			{Env, secret.Env != ""},
+			{External, secret.External != ""},
+		} {
+			if source.set {
+				sources = append(sources, source.name)
+			}
+		}
+		switch len(sources) {
+		case 0:
+			errors.Add(field, "secret needs one of generate, file, env or external", nil)
+		case 1:
+		default:
+			errors.Add(field, fmt.Sprintf("secret sets %s; use only one", strings.Join(sources, " and ")), sources)
+		}
+
+		if generate := secret.Generate; generate != nil {
+			if err := checkAlphabet(generate.Alphabet); err != nil {
+				errors.Add(field+".generate.alphabet", err.Error(), generate.Alphabet)
+			} else if bits := Entropy(generate); bits < MinEntropy {
+				errors.Add(field+".generate", fmt.Sprintf("%d characters from %d symbols give %.0f bits of entropy, below %d; raise length or alphabet", Length(generate), len(Alphabet(generate)), bits, MinEntropy), generate.Length)
+			}
+		}
+
+		if secret.Env != "" && !identifier.MatchString(secret.Env) {
+			errors.Add(field+".env", fmt.Sprintf("'%s' is not an environment variable name", secret.Env), secret.Env)
+		}
+	}
+
+	for i, service := range config.Services {
+		seen := make(map[string]bool)
+		for j, ref := range service.Secrets {
+			field := fmt.Sprintf("services[%d].secrets[%d]", i, j)
+
+			if _, exists := config.Secrets[ref.Name]; !exists && ref.Name != "" {
+				errors.Add(field+".name", fmt.Sprintf("unknown secret '%s'", ref.Name), ref.Name)
+			}
+			if seen[ref.Name] {
+				errors.Add(field+".name", fmt.Sprintf("secret '%s' is referenced more than once", ref.Name), ref.Name)
+			}
+			seen[ref.Name] = true
+
+			if ref.Env == "" {
+				continue
+			}
+			if !identifier.MatchString(ref.Env) {
+				errors.Add(field+".env", fmt.Sprintf("'%s' is not an environment variable name", ref.Env), ref.Env)
+			}
+			if _, exists := service.Environment[ref.Env]; exists {
+				errors.Add(field+".env", fmt.Sprintf("%s is also set in the environment of service '%s'; remove it there", ref.Env, service.Name), ref.Env)
+			}
+		}
+	}
+
+	return errors
+}
+
+// checkAlphabet reports alphabets with repeated characters, which skew
+// generated values, and characters that need escaping in .env files
+func checkAlphabet(alphabet string) error {
+	seen := make(map[rune]bool)
+	for _, r := range alphabet {
+		switch {
+		case seen[r]:
+			return fmt.Errorf("alphabet repeats '%c'", r)
+		case unicode.IsSpace(r) || !unicode.IsPrint(r) || strings.ContainsRune("'\"`\\", r):
+			return fmt.Errorf("alphabet may not contain whitespace, quotes or backslashes")
+		}
+		seen[r] = true
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package secrets
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+func TestNewValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		generate types.GenerateConfig
+		length   int
+		alphabet string
+	}{
+		{"defaults", types.GenerateConfig{}, DefaultLength, DefaultAlphabet},
+		{"custom", types.GenerateConfig{Length: 40, Alphabet: "0123456789abcdef"}, 40, "0123456789abcdef"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, err := NewValue(&tt.generate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := NewValue(&tt.generate)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(first) != tt.length {
+				t.Errorf("len(NewValue()) = %d, want %d", len(first), tt.length)
+			}
+			if strings.Trim(first, tt.alphabet) != "" {
+				t.Errorf("NewValue() = %q uses characters outside %q", first, tt.alphabet)
+			}
+			if first == second {
+				t.Errorf("NewValue() returned %q twice", first)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret types.SecretConfig
+		want   string
+	}{
+		{"generated", types.SecretConfig{Generate: &types.GenerateConfig{}}, ""},
+		{"no source", types.SecretConfig{}, "needs one of generate, file, env or external"},
+		{"two sources", types.SecretConfig{Env: "TOKEN", File: "./token"}, "use only one"},
+		{"weak", types.SecretConfig{Generate: &types.GenerateConfig{Length: 8}}, "below 128"},
+		{"repeated alphabet", types.SecretConfig{Generate: &types.GenerateConfig{Alphabet: "aab"}}, "secrets.s.generate.alphabet"},
+		{"bad env name", types.SecretConfig{Env: "NOT-A-NAME"}, "is not an environment variable name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &types.ProjectConfig{Secrets: map[string]types.SecretConfig{"s": tt.secret}}
+			errors := Validate(config)
+			if tt.want == "" {
+				if errors.HasErrors() {
+					t.Errorf("Validate() = %v, want no errors", errors)
+				}
+				return
+			}
+			if !strings.Contains(errors.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want %q", errors, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateVariables(t *testing.T) {
+	tests := []struct {
+		name      string
+		variables map[string]string
+		want      string
+	}{
+		{"plain", map[string]string{"LOG_LEVEL": "info"}, ""},
+		{"secret-like", map[string]string{"DB_PASSWORD": "hunter22"}, "variable 'DB_PASSWORD' looks like a secret; declare it under secrets"},
+		{"secret name", map[string]string{"s": "value"}, "variable 's' has the name of a secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &types.ProjectConfig{
+				Variables: tt.variables,
+				Secrets:   map[string]types.SecretConfig{"s": {Generate: &types.GenerateConfig{}}},
+			}
+			errors := Validate(config)
+			if tt.want == "" {
+				if errors.HasErrors() {
+					t.Errorf("Validate() = %v, want no errors", errors)
+				}
+				return
+			}
+			if !strings.Contains(errors.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want %q", errors, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPlaceholder(t *testing.T) {
+	tests := map[string]bool{
+		"":                     true,
+		"change-me":            true,
+		"CHANGEME":             true,
+		"secure-postgres-pass": true,
+		"xxxx":                 true,
+		"hunter22":             false,
+		"secure-password":      false,
+	}
+	for value, want := range tests {
+		if got := IsPlaceholder(value); got != want {
+			t.Errorf("IsPlaceholder(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
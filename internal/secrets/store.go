@@ -0,0 +1,228 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StoreFile is the name of the encrypted secrets file next to infra-gen.yml
+const StoreFile = "secrets.enc.yml"
+
+// Parameters of the store encryption
+const (
+	storeVersion = 1
+	storeCipher  = "aes-256-gcm"
+	storeKDF     = "pbkdf2-sha256"
+	// StoreIterations is the PBKDF2 iteration count of new stores
+	StoreIterations = 600000
+	saltSize        = 16
+	keySize         = 32
+)
+
+// storeHeader is the comment at the top of the encrypted file
+const storeHeader = "# Secret values encrypted by infra-gen; change them with 'infra-gen secrets'\n"
+
+// ErrWrongKey is returned when a store cannot be decrypted, because the
+// passphrase or key file is wrong or the file was modified
+var ErrWrongKey = errors.New("cannot decrypt secrets: wrong passphrase or key file, or the file was modified")
+
+// storeFile is the YAML layout of an encrypted store. Everything but the
+// ciphertext is authenticated as additional data.
+type storeFile struct {
+	Version    int    `yaml:"version"`
+	Cipher     string `yaml:"cipher"`
+	KDF        string `yaml:"kdf"`
+	Iterations int    `yaml:"iterations"`
+	Salt       string `yaml:"salt"`
+	Nonce      string `yaml:"nonce"`
+	Data       string `yaml:"data"`
+}
+
+// Store holds secret values, keyed by secret name, that are encrypted at
+// rest with AES-256-GCM under a key derived from a passphrase with PBKDF2.
+// Values are only ever decrypted in memory.
+type Store struct {
+	Values map[string]string
+
+	path       string
+	salt       []byte
+	iterations int
+	key        []byte
+}
+
+// StorePath returns the path of the store that belongs to a project file
+func StorePath(configFile string) string {
+	return filepath.Join(filepath.Dir(configFile), StoreFile)
+}
+
+// NewStore creates an empty store at path, encrypted with a key derived
+// from passphrase and a fresh salt. Nothing is written until Save.
+func NewStore(path string, passphrase []byte) (*Store, error) {
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return &Store{
+		Values:     make(map[string]string),
+		path:       path,
+		salt:       salt,
+		iterations: StoreIterations,
+		key:        pbkdf2(sha256.New, passphrase, salt, StoreIterations, keySize),
+	}, nil
+}
+
+// OpenStore reads and decrypts the store at path. A missing file gives an
+// error satisfying errors.Is(err, os.ErrNotExist).
+func OpenStore(path string, passphrase []byte) (*Store, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file storeFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if file.Version != storeVersion || file.Cipher != storeCipher || file.KDF != storeKDF {
+		return nil, fmt.Errorf("%s: unsupported store version %d (%s, %s)", path, file.Version, file.Cipher, file.KDF)
+	}
+	if file.Iterations < 1 {
+		return nil, fmt.Errorf("%s: invalid iteration count %d", path, file.Iterations)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(file.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid salt: %w", path, err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(file.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid nonce: %w", path, err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(file.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid data: %w", path, err)
+	}
+
+	store := &Store{
+		path:       path,
+		salt:       salt,
+		iterations: file.Iterations,
+		key:        pbkdf2(sha256.New, passphrase, salt, file.Iterations, keySize),
+	}
+
+	aead, err := store.aead()
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%s: invalid nonce size %d", path, len(nonce))
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData(file))
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+
+	if err := yaml.Unmarshal(plaintext, &store.Values); err != nil {
+		return nil, fmt.Errorf("%s: invalid secret values: %w", path, err)
+	}
+	if store.Values == nil {
+		store.Values = make(map[string]string)
+	}
+	return store, nil
+}
+
+// Path returns the file the store is read from and saved to
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Save encrypts the values with a fresh nonce and writes the store
+func (s *Store) Save() error {
+	plaintext, err := yaml.Marshal(s.Values)
+	if err != nil {
+		return fmt.Errorf("failed to encode secret values: %w", err)
+	}
+
+	aead, err := s.aead()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	file := storeFile{
+		Version:    storeVersion,
+		Cipher:     storeCipher,
+		KDF:        storeKDF,
+		Iterations: s.iterations,
+		Salt:       base64.StdEncoding.EncodeToString(s.salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+	}
+	file.Data = base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plaintext, additionalData(file)))
+
+	data, err := yaml.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", s.path, err)
+	}
+	return os.WriteFile(s.path, append([]byte(storeHeader), data...), 0644)
+}
+
+func (s *Store) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// additionalData binds the parameters of a store to its ciphertext, so
+// that changing them makes decryption fail
+func additionalData(file storeFile) []byte {
+	return []byte(fmt.Sprintf("infra-gen secrets v%d %s %s %d %s", file.Version, file.Cipher, file.KDF, file.Iterations, file.Salt))
+}
+
+// pbkdf2 derives a key from a password as specified in RFC 8018, with HMAC
+// over h as the pseudorandom function
+func pbkdf2(h func() hash.Hash, password, salt []byte, iterations, keyLength int) []byte {
+	prf := hmac.New(h, password)
+	blocks := (keyLength + prf.Size() - 1) / prf.Size()
+
+	key := make([]byte, 0, blocks*prf.Size())
+	u := make([]byte, prf.Size())
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, uint32(block)))
+		u = prf.Sum(u[:0])
+
+		t := make([]byte, len(u))
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLength]
+}
@@ -0,0 +1,94 @@
+package secrets
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPBKDF2(t *testing.T) {
+	tests := []struct {
+		name       string
+		hash       func() hash.Hash
+		password   string
+		salt       string
+		iterations int
+		want       string
+	}{
+		// RFC 6070, section 2
+		{"rfc6070 1", sha1.New, "password", "salt", 1, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{"rfc6070 2", sha1.New, "password", "salt", 2, "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+		{"rfc6070 4096", sha1.New, "password", "salt", 4096, "4b007901b765489abead49d926f721d065a429c1"},
+		{"rfc6070 two blocks", sha1.New, "passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, "3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038"},
+		{"rfc6070 nul", sha1.New, "pass\x00word", "sa\x00lt", 4096, "56fa6aa75548099dcc37d7f03425e0c3"},
+		// RFC 7914, section 11
+		{"rfc7914 1", sha256.New, "passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"rfc7914 80000", sha256.New, "Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, _ := hex.DecodeString(tt.want)
+			got := pbkdf2(tt.hash, []byte(tt.password), []byte(tt.salt), tt.iterations, len(want))
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("pbkdf2() = %x, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), StoreFile)
+	passphrase := []byte("correct horse battery staple")
+
+	store, err := NewStore(path, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Values["db_password"] = "s3cr3t-value"
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cr3t-value") {
+		t.Fatalf("store holds the value in plaintext:\n%s", data)
+	}
+
+	opened, err := OpenStore(path, passphrase)
+	if err != nil {
+		t.Fatalf("OpenStore() error = %v", err)
+	}
+	if got := opened.Values["db_password"]; got != "s3cr3t-value" {
+		t.Errorf("db_password = %q, want s3cr3t-value", got)
+	}
+
+	if _, err := OpenStore(path, []byte("wrong")); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("OpenStore() with a wrong passphrase error = %v, want ErrWrongKey", err)
+	}
+
+	// Lowering the iteration count to speed up guessing breaks decryption
+	tampered := strings.Replace(string(data), "iterations: 600000", "iterations: 1", 1)
+	if tampered == string(data) {
+		t.Fatal("iteration count not found in store")
+	}
+	if err := os.WriteFile(path, []byte(tampered), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenStore(path, passphrase); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("OpenStore() of a tampered store error = %v, want ErrWrongKey", err)
+	}
+
+	if _, err := OpenStore(filepath.Join(t.TempDir(), StoreFile), passphrase); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("OpenStore() of a missing store error = %v, want os.ErrNotExist", err)
+	}
+}
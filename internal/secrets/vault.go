@@ -0,0 +1,136 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// VaultHeader starts every file encrypted in the Ansible Vault 1.1 format
+const VaultHeader = "$ANSIBLE_VAULT;1.1;AES256"
+
+// Parameters of the Ansible Vault 1.1 format
+const (
+	vaultIterations = 10000
+	vaultSaltSize   = 32
+	vaultLineLength = 80
+)
+
+// ErrWrongVaultPassword is returned when a vault's HMAC does not match,
+// because the password is wrong or the file was modified
+var ErrWrongVaultPassword = errors.New("cannot decrypt vault: wrong password or the file was modified")
+
+// IsVault reports whether content is encrypted with Ansible Vault
+func IsVault(content string) bool {
+	return strings.HasPrefix(content, "$ANSIBLE_VAULT;")
+}
+
+// EncryptVault encrypts plaintext in the Ansible Vault 1.1 format that
+// ansible-vault reads: AES-256-CTR and HMAC-SHA256 with keys and IV derived
+// from the password and a random salt by PBKDF2-HMAC-SHA256
+func EncryptVault(plaintext, password []byte) (string, error) {
+	if len(password) == 0 {
+		return "", fmt.Errorf("empty vault password")
+	}
+	salt := make([]byte, vaultSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	cipherKey, hmacKey, iv := vaultKeys(password, salt)
+	block, err := aes.NewCipher(cipherKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	// PKCS#7 padding to the AES block size, as Ansible pads before CTR
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append(append([]byte{}, plaintext...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCTR(block, iv).XORKeyStream(ciphertext, padded)
+
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write(ciphertext)
+
+	body := hex.EncodeToString([]byte(hex.EncodeToString(salt) + "\n" + hex.EncodeToString(mac.Sum(nil)) + "\n" + hex.EncodeToString(ciphertext)))
+
+	var builder strings.Builder
+	builder.WriteString(VaultHeader)
+	builder.WriteString("\n")
+	for len(body) > 0 {
+		n := min(len(body), vaultLineLength)
+		builder.WriteString(body[:n])
+		builder.WriteString("\n")
+		body = body[n:]
+	}
+	return builder.String(), nil
+}
+
+// DecryptVault decrypts content in the Ansible Vault 1.1 format, checking its
+// HMAC before decrypting
+func DecryptVault(content string, password []byte) ([]byte, error) {
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	header := strings.Split(strings.TrimSpace(lines[0]), ";")
+	if len(header) < 3 || header[0] != "$ANSIBLE_VAULT" {
+		return nil, fmt.Errorf("not an Ansible Vault file")
+	}
+	if header[1] != "1.1" && header[1] != "1.2" || header[2] != "AES256" {
+		return nil, fmt.Errorf("unsupported vault format %s;%s", header[1], header[2])
+	}
+
+	var body strings.Builder
+	for _, line := range lines[1:] {
+		body.WriteString(strings.TrimSpace(line))
+	}
+	decoded, err := hex.DecodeString(body.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid vault body: %w", err)
+	}
+	parts := strings.Split(string(decoded), "\n")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid vault body: expected salt, HMAC and ciphertext")
+	}
+	var fields [3][]byte
+	for i, part := range parts {
+		if fields[i], err = hex.DecodeString(strings.TrimSpace(part)); err != nil {
+			return nil, fmt.Errorf("invalid vault body: %w", err)
+		}
+	}
+	salt, expected, ciphertext := fields[0], fields[1], fields[2]
+
+	cipherKey, hmacKey, iv := vaultKeys(password, salt)
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write(ciphertext)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return nil, ErrWrongVaultPassword
+	}
+
+	block, err := aes.NewCipher(cipherKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCTR(block, iv).XORKeyStream(plaintext, ciphertext)
+
+	if len(plaintext) == 0 {
+		return plaintext, nil
+	}
+	padding := int(plaintext[len(plaintext)-1])
+	if padding < 1 || padding > aes.BlockSize || padding > len(plaintext) {
+		return nil, fmt.Errorf("invalid vault padding")
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+// vaultKeys derives the AES key, HMAC key and counter IV of a vault
+func vaultKeys(password, salt []byte) (cipherKey, hmacKey, iv []byte) {
+	derived := pbkdf2(sha256.New, password, salt, vaultIterations, 2*keySize+aes.BlockSize)
+	return derived[:keySize], derived[keySize : 2*keySize], derived[2*keySize:]
+}
@@ -0,0 +1,95 @@
+package secrets
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// referenceVault was encrypted outside infra-gen, with Python's hashlib and
+// openssl enc -aes-256-ctr following the Ansible Vault 1.1 format, under the
+// password test-vault-pass and the salt 00 01 02 ... 1f
+const referenceVault = `$ANSIBLE_VAULT;1.1;AES256
+30303031303230333034303530363037303830393061306230633064306530663130313131323133
+3134313531363137313831393161316231633164316531660a343635313962636163396630616461
+35303635333033353565383330333962306263646531366661623835653466333830306665346535
+6465626464616333340a376437383931376232336563656230336438323631396437333931333365
+65303234663966306532643935613630303430636466343165363437643932616338656230386231
+3832303165316631353665663030316131336164633835353863
+`
+
+func TestDecryptVaultReference(t *testing.T) {
+	plaintext, err := DecryptVault(referenceVault, []byte("test-vault-pass"))
+	if err != nil {
+		t.Fatalf("DecryptVault() error = %v", err)
+	}
+	if want := "---\nvault_DB_PASSWORD: known-answer-value\n"; string(plaintext) != want {
+		t.Errorf("DecryptVault() = %q, want %q", plaintext, want)
+	}
+
+	if _, err := DecryptVault(referenceVault, []byte("wrong")); !errors.Is(err, ErrWrongVaultPassword) {
+		t.Errorf("DecryptVault() with a wrong password error = %v, want ErrWrongVaultPassword", err)
+	}
+
+	// Flip a ciphertext digit
+	tampered := strings.Replace(referenceVault, "3832303165", "3832303166", 1)
+	if _, err := DecryptVault(tampered, []byte("test-vault-pass")); !errors.Is(err, ErrWrongVaultPassword) {
+		t.Errorf("DecryptVault() of a modified vault error = %v, want ErrWrongVaultPassword", err)
+	}
+}
+
+func TestEncryptVault(t *testing.T) {
+	password := []byte("vault password")
+
+	// Lengths around the AES block size exercise the padding
+	for _, plaintext := range []string{"", "a", "exactly 16 bytes", "seventeen bytes!!", strings.Repeat("x", 100)} {
+		content, err := EncryptVault([]byte(plaintext), password)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+		if lines[0] != VaultHeader || !IsVault(content) {
+			t.Errorf("vault starts with %q, want %q", lines[0], VaultHeader)
+		}
+		for _, line := range lines[1:] {
+			if len(line) > vaultLineLength {
+				t.Errorf("vault line of %d characters, want at most %d", len(line), vaultLineLength)
+			}
+		}
+
+		decrypted, err := DecryptVault(content, password)
+		if err != nil {
+			t.Fatalf("DecryptVault() error = %v", err)
+		}
+		if string(decrypted) != plaintext {
+			t.Errorf("round trip of %q gave %q", plaintext, decrypted)
+		}
+	}
+
+	if _, err := EncryptVault([]byte("x"), nil); err == nil {
+		t.Error("EncryptVault() with an empty password error = nil")
+	}
+}
+
+func TestDecryptVaultInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"not a vault", "plain: text\n", "not an Ansible Vault file"},
+		{"unsupported version", "$ANSIBLE_VAULT;2.0;AES256\n00\n", "unsupported vault format"},
+		{"body not hex", "$ANSIBLE_VAULT;1.1;AES256\nzz\n", "invalid vault body"},
+		{"missing parts", "$ANSIBLE_VAULT;1.1;AES256\n3030\n", "expected salt, HMAC and ciphertext"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecryptVault(tt.content, []byte("password"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("DecryptVault() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
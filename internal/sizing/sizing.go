@@ -0,0 +1,226 @@
+package sizing
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// Size is the resolved resources of a service. Memory is in MiB and disk in
+// GiB; a zero disk means the target's default.
+type Size struct {
+	CPU    float64
+	Memory int
+	Disk   int
+}
+
+// Profiles are the named sizes a service can use
+var Profiles = map[string]Size{
+	"small":  {CPU: 0.5, Memory: 512},
+	"medium": {CPU: 1, Memory: 2048},
+	"large":  {CPU: 2, Memory: 4096},
+	"xlarge": {CPU: 4, Memory: 16384},
+}
+
+// instanceSize is a cloud instance type with its vCPUs and memory in MiB
+type instanceSize struct {
+	name   string
+	cpu    float64
+	memory int
+}
+
+// instanceTypes and dbInstanceClasses are ordered from smallest to largest
+var instanceTypes = []instanceSize{
+	{"t3.micro", 2, 1024},
+	{"t3.small", 2, 2048},
+	{"t3.medium", 2, 4096},
+	{"t3.large", 2, 8192},
+	{"t3.xlarge", 4, 16384},
+	{"t3.2xlarge", 8, 32768},
+}
+
+var dbInstanceClasses = []instanceSize{
+	{"db.t3.micro", 2, 1024},
+	{"db.t3.small", 2, 2048},
+	{"db.t3.medium", 2, 4096},
+	{"db.t3.large", 2, 8192},
+	{"db.t3.xlarge", 4, 16384},
+	{"db.t3.2xlarge", 8, 32768},
+}
+
+// Resolve returns the size of a service, starting from its profile and
+// applying explicit limits. It reports false when the service sets no
+// resources.
+func Resolve(resources *types.ResourcesConfig) (Size, bool) {
+	if resources == nil || (resources.Profile == "" && resources.CPU == "" && resources.Memory == "" && resources.Disk == "") {
+		return Size{}, false
+	}
+
+	size := Profiles[resources.Profile]
+	if cpu, err := ParseCPU(resources.CPU); err == nil && resources.CPU != "" {
+		size.CPU = cpu
+	}
+	if memory, err := ParseSize(resources.Memory, "M"); err == nil && resources.Memory != "" {
+		size.Memory = memory
+	}
+	if disk, err := ParseSize(resources.Disk, "G"); err == nil && resources.Disk != "" {
+		size.Disk = disk
+	}
+	return size, true
+}
+
+// InstanceType returns the smallest EC2 instance type that fits a size
+func InstanceType(size Size) string {
+	return fit(instanceTypes, size)
+}
+
+// DBInstanceClass returns the smallest RDS instance class that fits a size
+func DBInstanceClass(size Size) string {
+	return fit(dbInstanceClasses, size)
+}
+
+func fit(sizes []instanceSize, size Size) string {
+	for _, candidate := range sizes {
+		if candidate.cpu >= size.CPU && candidate.memory >= size.Memory {
+			return candidate.name
+		}
+	}
+	return sizes[len(sizes)-1].name
+}
+
+// FormatCPU formats cores without trailing zeros, such as 0.5 or 2
+func FormatCPU(cpu float64) string {
+	return strconv.FormatFloat(cpu, 'f', -1, 64)
+}
+
+// FormatMemory formats MiB with the largest whole unit, such as 512M or 2G
+func FormatMemory(memory int) string {
+	if memory >= 1024 && memory%1024 == 0 {
+		return fmt.Sprintf("%dG", memory/1024)
+	}
+	return fmt.Sprintf("%dM", memory)
+}
+
+// KubernetesCPU formats cores as a Kubernetes quantity, such as 500m or 2
+func KubernetesCPU(cpu float64) string {
+	millicores := int(cpu*1000 + 0.5)
+	if millicores%1000 == 0 {
+		return fmt.Sprintf("%d", millicores/1000)
+	}
+	return fmt.Sprintf("%dm", millicores)
+}
+
+// KubernetesMemory formats MiB as a Kubernetes quantity, such as 512Mi or 2Gi
+func KubernetesMemory(memory int) string {
+	return FormatMemory(memory) + "i"
+}
+
+// Reservation returns the share of a size a service reserves: half of its
+// CPU and memory limits
+func Reservation(size Size) Size {
+	return Size{CPU: size.CPU / 2, Memory: size.Memory / 2}
+}
+
+// ParseCPU parses a positive number of cores
+func ParseCPU(value string) (float64, error) {
+	cpu, err := strconv.ParseFloat(value, 64)
+	if err != nil || cpu <= 0 {
+		return 0, fmt.Errorf("'%s' is not a number of CPU cores such as 0.5 or 2", value)
+	}
+	return cpu, nil
+}
+
+// units are the size suffixes in MiB; decimal and binary suffixes are
+// treated alike
+var units = map[string]int{
+	"m": 1, "mb": 1, "mi": 1, "mib": 1,
+	"g": 1024, "gb": 1024, "gi": 1024, "gib": 1024,
+	"t": 1024 * 1024, "tb": 1024 * 1024, "ti": 1024 * 1024, "tib": 1024 * 1024,
+}
+
+// ParseSize parses a positive size such as 512M or 2G into the given unit,
+// M or G, rounding up
+func ParseSize(value, unit string) (int, error) {
+	number := strings.TrimRightFunc(value, func(r rune) bool { return r < '0' || r > '9' })
+	suffix := strings.ToLower(strings.TrimSpace(value[len(number):]))
+	size, err := strconv.Atoi(number)
+	factor, known := units[suffix]
+	if err != nil || !known || size <= 0 {
+		return 0, fmt.Errorf("'%s' is not a size such as 512M or 2G", value)
+	}
+
+	mib := size * factor
+	divisor := units[strings.ToLower(unit)]
+	return (mib + divisor - 1) / divisor, nil
+}
+
+// Validate reports unknown profiles. Limits checks the values of limits.
+func Validate(config *types.ProjectConfig) types.ValidationErrors {
+	var errors types.ValidationErrors
+
+	for i, service := range config.Services {
+		resources := service.Resources
+		if resources == nil {
+			continue
+		}
+		if _, known := Profiles[resources.Profile]; resources.Profile != "" && !known {
+			names := make([]string, 0, len(Profiles))
+			for name := range Profiles {
+				names = append(names, name)
+			}
+			sort.Slice(names, func(a, b int) bool { return Profiles[names[a]].Memory < Profiles[names[b]].Memory })
+			errors.Add(fmt.Sprintf("services[%d].resources.profile", i), fmt.Sprintf("unknown profile '%s' (use %s)", resources.Profile, strings.Join(names, ", ")), resources.Profile)
+		}
+	}
+
+	return errors
+}
+
+// Limits reports cpu, memory and disk limits and volume sizes that do not
+// parse
+func Limits(config *types.ProjectConfig) types.ValidationErrors {
+	var errors types.ValidationErrors
+
+	for i, service := range config.Services {
+		resources := service.Resources
+		if resources == nil {
+			continue
+		}
+		field := fmt.Sprintf("services[%d].resources", i)
+
+		if resources.CPU != "" {
+			if _, err := ParseCPU(resources.CPU); err != nil {
+				errors.Add(field+".cpu", err.Error(), resources.CPU)
+			}
+		}
+		if resources.Memory != "" {
+			if _, err := ParseSize(resources.Memory, "M"); err != nil {
+				errors.Add(field+".memory", err.Error(), resources.Memory)
+			}
+		}
+		if resources.Disk != "" {
+			if _, err := ParseSize(resources.Disk, "G"); err != nil {
+				errors.Add(field+".disk", err.Error(), resources.Disk)
+			}
+		}
+	}
+
+	for i, service := range config.Services {
+		for j, volume := range service.Volumes {
+			if volume.Size == "" {
+				continue
+			}
+			field := fmt.Sprintf("services[%d].volumes[%d].size", i, j)
+			if volume.Type != "volume" {
+				errors.Add(field, "size only applies to volumes of type volume", volume.Size)
+			} else if _, err := ParseSize(volume.Size, "G"); err != nil {
+				errors.Add(field, err.Error(), volume.Size)
+			}
+		}
+	}
+
+	return errors
+}
@@ -0,0 +1,159 @@
+package sizing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources *types.ResourcesConfig
+		want      Size
+		ok        bool
+	}{
+		{name: "none", resources: nil},
+		{name: "empty", resources: &types.ResourcesConfig{}},
+		{name: "profile", resources: &types.ResourcesConfig{Profile: "medium"}, want: Size{CPU: 1, Memory: 2048}, ok: true},
+		{
+			name:      "limits override the profile",
+			resources: &types.ResourcesConfig{Profile: "small", Memory: "1G", Disk: "500M"},
+			want:      Size{CPU: 0.5, Memory: 1024, Disk: 1},
+			ok:        true,
+		},
+		{name: "limits only", resources: &types.ResourcesConfig{CPU: "0.25"}, want: Size{CPU: 0.25}, ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Resolve(tt.resources)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Resolve() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	tests := []struct {
+		profile, instance, dbClass, cpu, memory string
+	}{
+		{profile: "small", instance: "t3.micro", dbClass: "db.t3.micro", cpu: "500m", memory: "512Mi"},
+		{profile: "medium", instance: "t3.small", dbClass: "db.t3.small", cpu: "1", memory: "2Gi"},
+		{profile: "large", instance: "t3.medium", dbClass: "db.t3.medium", cpu: "2", memory: "4Gi"},
+		{profile: "xlarge", instance: "t3.xlarge", dbClass: "db.t3.xlarge", cpu: "4", memory: "16Gi"},
+	}
+
+	for _, tt := range tests {
+		size := Profiles[tt.profile]
+		if got := InstanceType(size); got != tt.instance {
+			t.Errorf("InstanceType(%s) = %s, want %s", tt.profile, got, tt.instance)
+		}
+		if got := DBInstanceClass(size); got != tt.dbClass {
+			t.Errorf("DBInstanceClass(%s) = %s, want %s", tt.profile, got, tt.dbClass)
+		}
+		if got := KubernetesCPU(size.CPU); got != tt.cpu {
+			t.Errorf("KubernetesCPU(%s) = %s, want %s", tt.profile, got, tt.cpu)
+		}
+		if got := KubernetesMemory(size.Memory); got != tt.memory {
+			t.Errorf("KubernetesMemory(%s) = %s, want %s", tt.profile, got, tt.memory)
+		}
+	}
+
+	if got := InstanceType(Size{CPU: 64, Memory: 1 << 20}); got != "t3.2xlarge" {
+		t.Errorf("InstanceType() = %s, want the largest type for sizes beyond it", got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if got := FormatCPU(0.5); got != "0.5" {
+		t.Errorf("FormatCPU(0.5) = %s, want 0.5", got)
+	}
+	if got := FormatCPU(2); got != "2" {
+		t.Errorf("FormatCPU(2) = %s, want 2", got)
+	}
+	if got := FormatMemory(1536); got != "1536M" {
+		t.Errorf("FormatMemory(1536) = %s, want 1536M", got)
+	}
+	if got := KubernetesCPU(0.25); got != "250m" {
+		t.Errorf("KubernetesCPU(0.25) = %s, want 250m", got)
+	}
+	if got := Reservation(Size{CPU: 1, Memory: 2048, Disk: 10}); got != (Size{CPU: 0.5, Memory: 1024}) {
+		t.Errorf("Reservation() = %+v, want half the CPU and memory", got)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value, unit string
+		want        int
+		wantErr     bool
+	}{
+		{value: "512M", unit: "M", want: 512},
+		{value: "2G", unit: "M", want: 2048},
+		{value: "2Gi", unit: "M", want: 2048},
+		{value: "1TB", unit: "G", want: 1024},
+		{value: "1536M", unit: "G", want: 2},
+		{value: "20 GiB", unit: "G", want: 20},
+		{value: "512", unit: "M", wantErr: true},
+		{value: "1.5G", unit: "M", wantErr: true},
+		{value: "0G", unit: "G", wantErr: true},
+		{value: "2X", unit: "G", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSize(tt.value, tt.unit)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSize(%q, %s) = %d, %v, want %d, error %v", tt.value, tt.unit, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources types.ResourcesConfig
+		volume    types.VolumeConfig
+		want      []string
+	}{
+		{name: "valid", resources: types.ResourcesConfig{Profile: "large", CPU: "3", Memory: "6G", Disk: "50G"}},
+		{name: "unknown profile", resources: types.ResourcesConfig{Profile: "huge"}, want: []string{"unknown profile 'huge' (use small, medium, large, xlarge)"}},
+		{
+			name:      "unparsable limits",
+			resources: types.ResourcesConfig{CPU: "two", Memory: "lots", Disk: "-1G"},
+			want: []string{
+				"resources.cpu': 'two' is not a number of CPU cores",
+				"resources.memory': 'lots' is not a size",
+				"resources.disk': '-1G' is not a size",
+			},
+		},
+		{name: "volume size", volume: types.VolumeConfig{Type: "volume", Source: "data", Target: "/data", Size: "10G"}},
+		{
+			name:   "size on a bind mount",
+			volume: types.VolumeConfig{Type: "bind", Source: "/srv", Target: "/data", Size: "10G"},
+			want:   []string{"size only applies to volumes of type volume"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := types.ServiceConfig{Name: "api", Resources: &tt.resources}
+			if tt.volume.Target != "" {
+				service.Volumes = []types.VolumeConfig{tt.volume}
+			}
+			config := &types.ProjectConfig{Services: []types.ServiceConfig{service}}
+
+			errors := append(Validate(config), Limits(config)...)
+			if len(errors) != len(tt.want) {
+				t.Errorf("Validate() and Limits() reported %d errors, want %d: %v", len(errors), len(tt.want), errors)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(errors.Error(), want) {
+					t.Errorf("Validate() and Limits() = %v, want %q", errors, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package templates
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// chainParameters merges the parameters of an extends chain, root first.
+// A parameter redeclared by a child replaces the parent declaration in place.
+func chainParameters(chain []definition) []types.PresetParameter {
+	var parameters []types.PresetParameter
+	index := make(map[string]int)
+
+	for _, def := range chain {
+		for _, param := range def.Parameters {
+			if i, exists := index[param.Name]; exists {
+				parameters[i] = param
+				continue
+			}
+			index[param.Name] = len(parameters)
+			parameters = append(parameters, param)
+		}
+	}
+
+	return parameters
+}
+
+// mergePresets applies a child preset on top of the preset it extends:
+//   - name, type, description and category are taken from the child when set
+//   - services are matched by name; a matching child service overrides the
+//     parent's image, type and description when set, replaces its ports,
+//     volumes and depends_on when given, merges environment key by key and
+//     takes the child's optional flag
+//   - new child services are appended after the parent services, in order
+//   - variables are merged key by key, the child winning
+//   - tags are the union of both, parent tags first
+func mergePresets(parent, child types.Preset) types.Preset {
+	merged := parent
+
+	if child.Name != "" {
+		merged.Name = child.Name
+	}
+	if child.Type != "" {
+		merged.Type = child.Type
+	}
+	if child.Description != "" {
+		merged.Description = child.Description
+	}
+	if child.Category != "" {
+		merged.Category = child.Category
+	}
+
+	merged.Services = make([]types.PresetService, len(parent.Services))
+	copy(merged.Services, parent.Services)
+
+	index := make(map[string]int)
+	for i, service := range merged.Services {
+		index[service.Name] = i
+	}
+
+	for _, service := range child.Services {
+		i, exists := index[service.Name]
+		if !exists {
+			index[service.Name] = len(merged.Services)
+			merged.Services = append(merged.Services, service)
+			continue
+		}
+		merged.Services[i] = mergeService(merged.Services[i], service)
+	}
+
+	merged.Variables = mergeStrings(parent.Variables, child.Variables)
+
+	seen := make(map[string]bool)
+	merged.Tags = nil
+	for _, tag := range append(append([]string{}, parent.Tags...), child.Tags...) {
+		if !seen[tag] {
+			seen[tag] = true
+			merged.Tags = append(merged.Tags, tag)
+		}
+	}
+
+	return merged
+}
+
+func mergeService(parent, child types.PresetService) types.PresetService {
+	merged := parent
+
+	if child.Type != "" {
+		merged.Type = child.Type
+	}
+	if child.Description != "" {
+		merged.Description = child.Description
+	}
+	if child.Image != "" {
+		merged.Image = child.Image
+	}
+	if len(child.Ports) > 0 {
+		merged.Ports = child.Ports
+	}
+	if len(child.Volumes) > 0 {
+		merged.Volumes = child.Volumes
+	}
+	if len(child.DependsOn) > 0 {
+		merged.DependsOn = child.DependsOn
+	}
+	if child.Healthcheck != nil {
+		merged.Healthcheck = child.Healthcheck
+	}
+	if child.Resources != nil {
+		merged.Resources = child.Resources
+	}
+	if child.Replicas > 0 {
+		merged.Replicas = child.Replicas
+	}
+	if child.Autoscaling != nil {
+		merged.Autoscaling = child.Autoscaling
+	}
+	merged.Environment = mergeStrings(parent.Environment, child.Environment)
+	merged.Optional = child.Optional
+
+	return merged
+}
+
+func mergeStrings(base, overlay map[string]string) map[string]string {
+	if len(base) == 0 && len(overlay) == 0 {
+		return nil
+	}
+
+	merged := make(map[string]string)
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overlay {
+		merged[key] = value
+	}
+
+	return merged
+}
+
+// origins records which preset or mixin contributed each service and variable
+type origins struct {
+	services  map[string]string
+	variables map[string]string
+}
+
+func newOrigins(preset types.Preset, origin string) origins {
+	o := origins{
+		services:  make(map[string]string),
+		variables: make(map[string]string),
+	}
+
+	for _, service := range preset.Services {
+		o.services[service.Name] = origin
+	}
+	for key := range preset.Variables {
+		o.variables[key] = origin
+	}
+
+	return o
+}
+
+// applyMixin adds the services and variables of a mixin to a preset. Mixins
+// never override: a service name already present, or a variable already set
+// to a different value, is reported as a conflict naming both sources.
+func applyMixin(preset *types.Preset, mixin types.Preset, o origins, origin string) types.ValidationErrors {
+	var errors types.ValidationErrors
+
+	for _, service := range mixin.Services {
+		if existing, exists := o.services[service.Name]; exists {
+			errors.Add("services", fmt.Sprintf("service '%s' is defined by both %s and %s", service.Name, existing, origin), service.Name)
+			continue
+		}
+		o.services[service.Name] = origin
+		preset.Services = append(preset.Services, service)
+	}
+
+	for _, key := range sortedKeys(mixin.Variables) {
+		value := mixin.Variables[key]
+		if existing, exists := o.variables[key]; exists {
+			if preset.Variables[key] != value {
+				errors.Add("variables", fmt.Sprintf("variable '%s' is set to different values by %s and %s", key, existing, origin), key)
+			}
+			continue
+		}
+		if preset.Variables == nil {
+			preset.Variables = make(map[string]string)
+		}
+		o.variables[key] = origin
+		preset.Variables[key] = value
+	}
+
+	return errors
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
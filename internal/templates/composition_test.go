@@ -0,0 +1,196 @@
+package templates
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+func serviceNames(preset *types.Preset) string {
+	var names []string
+	for _, service := range preset.Services {
+		names = append(names, service.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestMergePresets(t *testing.T) {
+	parent := types.Preset{
+		Name:     "Parent",
+		Category: "Base",
+		Services: []types.PresetService{
+			{Name: "api", Type: "api", Image: "api:1", Environment: map[string]string{"A": "1", "B": "1"}, DependsOn: []string{"db"}},
+			{Name: "db", Type: "postgres", Image: "postgres:15"},
+		},
+		Variables: map[string]string{"X": "parent", "Y": "parent"},
+		Tags:      []string{"web", "api"},
+	}
+	child := types.Preset{
+		Name: "Child",
+		Services: []types.PresetService{
+			{Name: "worker", Type: "worker", Image: "worker:1"},
+			{Name: "api", Image: "api:2", Environment: map[string]string{"B": "2", "C": "2"}, Optional: true},
+		},
+		Variables: map[string]string{"Y": "child"},
+		Tags:      []string{"api", "jobs"},
+	}
+
+	merged := mergePresets(parent, child)
+
+	if merged.Name != "Child" || merged.Category != "Base" {
+		t.Errorf("name, category = %s, %s, want Child, Base", merged.Name, merged.Category)
+	}
+	if got := serviceNames(&merged); got != "api db worker" {
+		t.Errorf("services = %s, want api db worker", got)
+	}
+	api := merged.Services[0]
+	if api.Type != "api" || api.Image != "api:2" || !api.Optional {
+		t.Errorf("api = %+v, want type kept, image and optional from child", api)
+	}
+	if want := map[string]string{"A": "1", "B": "2", "C": "2"}; !reflect.DeepEqual(api.Environment, want) {
+		t.Errorf("api environment = %v, want %v", api.Environment, want)
+	}
+	if !reflect.DeepEqual(api.DependsOn, []string{"db"}) {
+		t.Errorf("api depends_on = %v, want parent's [db]", api.DependsOn)
+	}
+	if want := map[string]string{"X": "parent", "Y": "child"}; !reflect.DeepEqual(merged.Variables, want) {
+		t.Errorf("variables = %v, want %v", merged.Variables, want)
+	}
+	if want := []string{"web", "api", "jobs"}; !reflect.DeepEqual(merged.Tags, want) {
+		t.Errorf("tags = %v, want %v", merged.Tags, want)
+	}
+	if parent.Services[0].Image != "api:1" {
+		t.Errorf("merge modified the parent preset")
+	}
+}
+
+func TestExtendsEmbeddedPreset(t *testing.T) {
+	dir := t.TempDir()
+	writePreset(t, dir, "shop.yaml", `id: shop
+name: Shop
+extends: web-app
+description: Web application with a worker
+category: Custom
+services:
+  - name: api
+    image: "node:{{ params.node_version }}-slim"
+  - name: worker
+    type: worker
+    image: worker:1
+`)
+
+	tm := NewTemplateManager()
+	if err := tm.LoadCustomTemplate(dir); err != nil {
+		t.Fatalf("LoadCustomTemplate() error = %v", err)
+	}
+
+	preset, err := tm.Compose("shop", nil, map[string]string{"node_version": "20"})
+	if err != nil {
+		t.Fatalf("Compose() error = %v", err)
+	}
+	if got := serviceNames(preset); got != "frontend api database worker" {
+		t.Errorf("services = %s, want frontend api database worker", got)
+	}
+	if preset.Services[1].Image != "node:20-slim" || preset.Services[1].Type != "api" {
+		t.Errorf("api = %+v, want image from shop with the parent parameter, type from web-app", preset.Services[1])
+	}
+}
+
+func TestComposeMixins(t *testing.T) {
+	tm := NewTemplateManager()
+
+	preset, err := tm.Compose("web-app", []string{"redis-cache", "prometheus-monitoring"}, nil)
+	if err != nil {
+		t.Fatalf("Compose() error = %v", err)
+	}
+	if got := serviceNames(preset); got != "frontend api database redis prometheus grafana" {
+		t.Errorf("services = %s, want the preset's followed by each mixin's, in order", got)
+	}
+	for _, key := range []string{"REDIS_PASSWORD", "GRAFANA_ADMIN_PASSWORD"} {
+		if preset.Variables[key] == "" {
+			t.Errorf("variables = %v, want %s from a mixin", preset.Variables, key)
+		}
+	}
+
+	again, err := tm.Compose("web-app", []string{"redis-cache", "prometheus-monitoring"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(preset, again) {
+		t.Errorf("Compose() is not deterministic")
+	}
+}
+
+func TestComposeConflicts(t *testing.T) {
+	dir := t.TempDir()
+	writePreset(t, dir, "other-redis.yaml", `id: other-redis
+name: Other Redis
+kind: mixin
+description: Redis password that disagrees with redis-cache
+category: Add-ons
+services:
+  - name: redis-exporter
+    type: monitoring
+    image: oliver006/redis_exporter
+variables:
+  REDIS_PASSWORD: different
+`)
+	tm := NewTemplateManager()
+	if err := tm.LoadCustomTemplate(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		preset string
+		mixins []string
+		want   []string
+	}{
+		{
+			name:   "service defined twice",
+			preset: "microservice",
+			mixins: []string{"redis-cache"},
+			want:   []string{"service 'redis' is defined by both preset 'microservice' and mixin 'redis-cache'"},
+		},
+		{
+			name:   "variable set to different values",
+			preset: "web-app",
+			mixins: []string{"redis-cache", "other-redis"},
+			want:   []string{"variable 'REDIS_PASSWORD' is set to different values by mixin 'redis-cache' and mixin 'other-redis'"},
+		},
+		{
+			name:   "unknown mixin and preset used as mixin",
+			preset: "web-app",
+			mixins: []string{"kafka", "database"},
+			want:   []string{"mixin 'kafka' not found", "'database' is a preset, not a mixin"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := tm.Compose(test.preset, test.mixins, nil)
+
+			var validationErrors types.ValidationErrors
+			if !errors.As(err, &validationErrors) {
+				t.Fatalf("Compose() error = %v, want ValidationErrors", err)
+			}
+			if len(validationErrors) != len(test.want) {
+				t.Errorf("Compose() reported %d errors, want %d: %v", len(validationErrors), len(test.want), err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Compose() error = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestComposeMixinAsPreset(t *testing.T) {
+	_, err := NewTemplateManager().Compose("redis-cache", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "'redis-cache' is a mixin") {
+		t.Errorf("Compose() error = %v, want mixin rejected as preset", err)
+	}
+}
@@ -1,34 +1,60 @@
 package templates
 
 import (
+	"bytes"
 	"embed"
 	"fmt"
 	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+	"gopkg.in/yaml.v3"
 )
 
 //go:embed embedded/*.yaml
 var embeddedTemplates embed.FS
 
+// definition is a preset file as loaded, before parameters and extends are resolved
+type definition struct {
+	ID         string                  `yaml:"id"`
+	Kind       types.PresetKind        `yaml:"kind"`
+	Extends    string                  `yaml:"extends"`
+	Parameters []types.PresetParameter `yaml:"parameters"`
+
+	data   []byte
+	source string
+	custom bool
+}
+
 // TemplateManager manages embedded and custom templates
 type TemplateManager struct {
-	embedded map[string]types.Preset
-	custom   map[string]types.Preset
+	embedded     map[string]types.Preset
+	custom       map[string]types.Preset
+	embeddedDefs map[string]definition
+	customDefs   map[string]definition
+	warnings     []string
 }
 
-// NewTemplateManager creates a new template manager
+// NewTemplateManager creates a new template manager.
+// It panics if an embedded preset cannot be parsed, since those ship with the binary.
 func NewTemplateManager() *TemplateManager {
 	tm := &TemplateManager{
-		embedded: make(map[string]types.Preset),
-		custom:   make(map[string]types.Preset),
+		embedded:     make(map[string]types.Preset),
+		custom:       make(map[string]types.Preset),
+		embeddedDefs: make(map[string]definition),
+		customDefs:   make(map[string]definition),
 	}
 
 	// Load embedded templates
 	if err := tm.loadEmbeddedTemplates(); err != nil {
-		// Log error but continue with empty templates
-		fmt.Printf("Warning: Failed to load embedded templates: %v\n", err)
+		panic(fmt.Sprintf("invalid embedded preset: %v", err))
+	}
+
+	if err := tm.refresh(); err != nil {
+		panic(fmt.Sprintf("invalid embedded preset: %v", err))
 	}
 
 	return tm
@@ -45,118 +71,454 @@ func (tm *TemplateManager) loadEmbeddedTemplates() error {
 			return nil
 		}
 
-		// Parse YAML content to get preset
-		// For now, we'll create simple presets
-		preset := types.Preset{
-			ID:          strings.TrimSuffix(d.Name(), ".yaml"),
-			Name:        tm.generatePresetName(d.Name()),
-			Description: tm.generatePresetDescription(d.Name()),
-			Category:    tm.getCategoryFromPath(path),
+		data, err := embeddedTemplates.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
 		}
 
-		tm.embedded[preset.ID] = preset
+		def, err := parseDefinition(data, path)
+		if err != nil {
+			return err
+		}
+
+		if _, exists := tm.embeddedDefs[def.ID]; exists {
+			return fmt.Errorf("%s: duplicate preset id '%s'", path, def.ID)
+		}
+
+		tm.embeddedDefs[def.ID] = def
 		return nil
 	})
 }
 
-// GetPreset returns a preset by ID
+// parseDefinition reads the header of a preset file. The full preset is
+// decoded once parameters and extends can be resolved.
+func parseDefinition(data []byte, source string) (definition, error) {
+	var def definition
+
+	if err := yaml.Unmarshal(data, &def); err != nil {
+		return def, fmt.Errorf("%s: failed to parse preset: %w", source, err)
+	}
+
+	if def.ID == "" {
+		return def, fmt.Errorf("%s: %w", source, types.ValidationErrors{{Field: "id", Message: "preset id is required"}})
+	}
+
+	switch def.Kind {
+	case "", types.PresetKindPreset, types.PresetKindMixin:
+	default:
+		return def, fmt.Errorf("%s: %w", source, types.ValidationErrors{{Field: "kind", Message: "preset kind must be preset or mixin", Value: def.Kind}})
+	}
+
+	if err := validateParameters(def.Parameters); err != nil {
+		return def, fmt.Errorf("%s: %w", source, err)
+	}
+
+	def.data = data
+	def.source = source
+	return def, nil
+}
+
+// refresh resolves every loaded definition with default parameter values so
+// that presets can be listed, and reports the first definition that fails
+func (tm *TemplateManager) refresh() error {
+	tm.embedded = make(map[string]types.Preset)
+	tm.custom = make(map[string]types.Preset)
+
+	for _, def := range sortedDefinitions(tm.embeddedDefs) {
+		preset, err := tm.render(def, nil)
+		if err != nil {
+			return err
+		}
+		tm.embedded[def.ID] = preset
+	}
+
+	for _, def := range sortedDefinitions(tm.customDefs) {
+		preset, err := tm.render(def, nil)
+		if err != nil {
+			return err
+		}
+		tm.custom[def.ID] = preset
+	}
+
+	return nil
+}
+
+// lookup finds the definition a preset ID refers to. A custom preset that
+// extends its own ID extends the embedded preset it shadows.
+func (tm *TemplateManager) lookup(id string, from *definition) (definition, bool) {
+	if from == nil || !from.custom || from.ID != id {
+		if def, exists := tm.customDefs[id]; exists {
+			return def, true
+		}
+	}
+
+	def, exists := tm.embeddedDefs[id]
+	return def, exists
+}
+
+// chain returns the extends chain of a definition, root first
+func (tm *TemplateManager) chain(def definition) ([]definition, error) {
+	chain := []definition{def}
+	seen := map[string]bool{def.source: true}
+
+	current := def
+	for current.Extends != "" {
+		parent, exists := tm.lookup(current.Extends, &current)
+		if !exists {
+			return nil, fmt.Errorf("%s: extends unknown preset '%s'", current.source, current.Extends)
+		}
+
+		if seen[parent.source] {
+			var ids []string
+			for i := len(chain) - 1; i >= 0; i-- {
+				ids = append(ids, chain[i].ID)
+			}
+			return nil, fmt.Errorf("%s: extends cycle: %s -> %s", def.source, strings.Join(ids, " -> "), parent.ID)
+		}
+		seen[parent.source] = true
+
+		chain = append([]definition{parent}, chain...)
+		current = parent
+	}
+
+	return chain, nil
+}
+
+// render resolves a definition and its ancestors into a preset. When values
+// is nil, parameter defaults (or placeholders for required parameters) are used.
+func (tm *TemplateManager) render(def definition, values map[string]string) (types.Preset, error) {
+	chain, err := tm.chain(def)
+	if err != nil {
+		return types.Preset{}, err
+	}
+
+	parameters := chainParameters(chain)
+	if values == nil {
+		values = placeholderParameters(parameters)
+	}
+
+	var preset types.Preset
+	for i, link := range chain {
+		decoded, err := decodePreset(link, parameters, values)
+		if err != nil {
+			return types.Preset{}, err
+		}
+
+		if i == 0 {
+			preset = decoded
+		} else {
+			preset = mergePresets(preset, decoded)
+		}
+	}
+
+	preset.ID = def.ID
+	preset.Kind = def.Kind
+	preset.Extends = def.Extends
+	preset.Parameters = parameters
+	if preset.Kind == "" {
+		preset.Kind = types.PresetKindPreset
+	}
+
+	preset.Source = def.source
+	if !def.custom {
+		preset.Source = "embedded"
+	}
+
+	if err := validatePreset(&preset); err != nil {
+		return types.Preset{}, fmt.Errorf("%s: %w", def.source, err)
+	}
+
+	return preset, nil
+}
+
+// decodePreset substitutes parameter values and strictly decodes the result
+func decodePreset(def definition, parameters []types.PresetParameter, values map[string]string) (types.Preset, error) {
+	var preset types.Preset
+
+	rendered, err := renderParameters(def.data, parameters, values)
+	if err != nil {
+		return preset, fmt.Errorf("%s: %w", def.source, err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(rendered))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&preset); err != nil {
+		return preset, fmt.Errorf("%s: failed to parse preset: %w", def.source, err)
+	}
+
+	return preset, nil
+}
+
+// validatePreset checks the structural rules every preset must satisfy
+func validatePreset(preset *types.Preset) error {
+	var errors types.ValidationErrors
+
+	if preset.ID == "" {
+		errors.Add("id", "preset id is required", preset.ID)
+	}
+	if preset.Name == "" {
+		errors.Add("name", "preset name is required", preset.Name)
+	}
+	if preset.Category == "" {
+		errors.Add("category", "preset category is required", preset.Category)
+	}
+	if len(preset.Services) == 0 {
+		errors.Add("services", "at least one service is required", len(preset.Services))
+	}
+
+	serviceNames := make(map[string]bool)
+	for i, service := range preset.Services {
+		if service.Name == "" {
+			errors.Add(fmt.Sprintf("services[%d].name", i), "service name is required", service.Name)
+		}
+		if service.Type == "" {
+			errors.Add(fmt.Sprintf("services[%d].type", i), "service type is required", service.Type)
+		}
+		if serviceNames[service.Name] {
+			errors.Add(fmt.Sprintf("services[%d].name", i), fmt.Sprintf("duplicate service name: %s", service.Name), service.Name)
+		}
+		serviceNames[service.Name] = true
+	}
+
+	// Mixins may depend on services of the preset they are applied to
+	if preset.Kind != types.PresetKindMixin {
+		for i, service := range preset.Services {
+			for _, dep := range service.DependsOn {
+				if !serviceNames[dep] {
+					errors.Add(fmt.Sprintf("services[%d].depends_on", i), fmt.Sprintf("unknown service: %s", dep), dep)
+				}
+			}
+		}
+	}
+
+	if errors.HasErrors() {
+		return errors
+	}
+
+	return nil
+}
+
+// Compose renders a preset with the given parameter values and applies the
+// given mixins on top of it, in order. Parameter problems and conflicts
+// between the preset and mixins are all reported as types.ValidationErrors.
+func (tm *TemplateManager) Compose(presetID string, mixins []string, params map[string]string) (*types.Preset, error) {
+	var errors types.ValidationErrors
+
+	def, exists := tm.lookup(presetID, nil)
+	if !exists {
+		return nil, fmt.Errorf("preset '%s' not found", presetID)
+	}
+	if def.Kind == types.PresetKindMixin {
+		return nil, fmt.Errorf("'%s' is a mixin; apply it to a preset with --with", presetID)
+	}
+
+	declared := make(map[string]bool)
+	renderWith := func(def definition) (types.Preset, bool) {
+		chain, err := tm.chain(def)
+		if err != nil {
+			errors.Add(def.ID, err.Error(), nil)
+			return types.Preset{}, false
+		}
+
+		parameters := chainParameters(chain)
+		for _, param := range parameters {
+			declared[param.Name] = true
+		}
+
+		values, err := resolveParameters(parameters, params)
+		if err != nil {
+			if paramErrors, ok := err.(types.ValidationErrors); ok {
+				errors = append(errors, paramErrors...)
+			} else {
+				errors.Add("params", err.Error(), nil)
+			}
+			return types.Preset{}, false
+		}
+
+		preset, err := tm.render(def, values)
+		if err != nil {
+			errors.Add(def.ID, err.Error(), nil)
+			return types.Preset{}, false
+		}
+		return preset, true
+	}
+
+	result, ok := renderWith(def)
+	origins := newOrigins(result, fmt.Sprintf("preset '%s'", presetID))
+
+	for _, mixinID := range mixins {
+		mixinDef, exists := tm.lookup(mixinID, nil)
+		if !exists {
+			errors.Add("mixins", fmt.Sprintf("mixin '%s' not found", mixinID), mixinID)
+			continue
+		}
+		if mixinDef.Kind != types.PresetKindMixin {
+			errors.Add("mixins", fmt.Sprintf("'%s' is a preset, not a mixin", mixinID), mixinID)
+			continue
+		}
+
+		mixin, rendered := renderWith(mixinDef)
+		if rendered && ok {
+			errors = append(errors, applyMixin(&result, mixin, origins, fmt.Sprintf("mixin '%s'", mixinID))...)
+		}
+	}
+
+	var names []string
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !declared[name] {
+			errors.Add("params."+name, "unknown parameter", params[name])
+		}
+	}
+
+	if errors.HasErrors() {
+		return nil, errors
+	}
+
+	if err := validatePreset(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetPreset returns a preset or mixin by ID. Custom presets take precedence over embedded ones.
 func (tm *TemplateManager) GetPreset(id string) (*types.Preset, error) {
-	if preset, exists := tm.embedded[id]; exists {
+	if preset, exists := tm.custom[id]; exists {
 		return &preset, nil
 	}
 
-	if preset, exists := tm.custom[id]; exists {
+	if preset, exists := tm.embedded[id]; exists {
 		return &preset, nil
 	}
 
 	return nil, fmt.Errorf("preset '%s' not found", id)
 }
 
-// ListPresets returns all available presets
+// ListPresets returns all available presets, excluding mixins
 func (tm *TemplateManager) ListPresets() []types.Preset {
-	var presets []types.Preset
+	return tm.list(types.PresetKindPreset)
+}
 
-	for _, preset := range tm.embedded {
-		presets = append(presets, preset)
-	}
+// ListMixins returns all available add-on mixins
+func (tm *TemplateManager) ListMixins() []types.Preset {
+	return tm.list(types.PresetKindMixin)
+}
 
-	for _, preset := range tm.custom {
-		presets = append(presets, preset)
+// ListPresetsByCategory returns presets filtered by category
+func (tm *TemplateManager) ListPresetsByCategory(category string) []types.Preset {
+	var presets []types.Preset
+
+	for _, preset := range tm.ListPresets() {
+		if preset.Category == category {
+			presets = append(presets, preset)
+		}
 	}
 
 	return presets
 }
 
-// ListPresetsByCategory returns presets filtered by category
-func (tm *TemplateManager) ListPresetsByCategory(category string) []types.Preset {
+func (tm *TemplateManager) list(kind types.PresetKind) []types.Preset {
 	var presets []types.Preset
 
-	for _, preset := range tm.embedded {
-		if preset.Category == category {
+	for id, preset := range tm.embedded {
+		if _, shadowed := tm.custom[id]; !shadowed && preset.Kind == kind {
 			presets = append(presets, preset)
 		}
 	}
 
 	for _, preset := range tm.custom {
-		if preset.Category == category {
+		if preset.Kind == kind {
 			presets = append(presets, preset)
 		}
 	}
 
+	sort.Slice(presets, func(i, j int) bool {
+		return presets[i].ID < presets[j].ID
+	})
+
 	return presets
 }
 
-// LoadCustomTemplate loads a custom template from file
-func (tm *TemplateManager) LoadCustomTemplate(path string) error {
-	// This would load templates from filesystem
-	// For now, return not implemented
-	return fmt.Errorf("custom template loading not implemented yet")
+// LoadCustomTemplate loads custom presets from preset files or from every
+// .yaml/.yml file in directories, in the order given. A loaded preset replaces
+// any embedded or previously loaded preset with the same ID, and a warning is
+// recorded. Presets are resolved once all paths are loaded, so a preset may
+// extend one from a later path.
+func (tm *TemplateManager) LoadCustomTemplate(paths ...string) error {
+	for _, path := range paths {
+		if err := tm.loadCustomPath(path); err != nil {
+			return err
+		}
+	}
+	return tm.refresh()
 }
 
-// Helper functions
-func (tm *TemplateManager) generatePresetName(filename string) string {
-	name := strings.TrimSuffix(filename, ".yaml")
-	name = strings.ReplaceAll(name, "-", " ")
-	name = strings.ReplaceAll(name, "_", " ")
+// loadCustomPath reads the preset definitions of a file or directory
+func (tm *TemplateManager) loadCustomPath(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read preset path: %w", err)
+	}
 
-	// Capitalize first letter
-	if len(name) > 0 {
-		name = strings.ToUpper(name[:1]) + name[1:]
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return fmt.Errorf("failed to read preset directory: %w", err)
+		}
+
+		files = files[:0]
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+				continue
+			}
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
 	}
 
-	return name
-}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read preset file: %w", err)
+		}
 
-func (tm *TemplateManager) generatePresetDescription(filename string) string {
-	base := strings.TrimSuffix(filename, ".yaml")
+		def, err := parseDefinition(data, file)
+		if err != nil {
+			return err
+		}
+		def.custom = true
 
-	descriptions := map[string]string{
-		"web-app":        "Basic web application with frontend, backend, and database",
-		"microservice":   "Microservice architecture with API gateway and services",
-		"database":       "Database services (PostgreSQL, MySQL, MongoDB)",
-		"ml":             "Machine learning pipeline with model serving",
-		"infrastructure": "Core infrastructure components (monitoring, logging)",
-	}
+		if existing, exists := tm.customDefs[def.ID]; exists {
+			tm.warnings = append(tm.warnings, fmt.Sprintf("preset '%s' from %s shadows %s", def.ID, file, existing.source))
+		} else if _, exists := tm.embeddedDefs[def.ID]; exists {
+			tm.warnings = append(tm.warnings, fmt.Sprintf("preset '%s' from %s shadows the embedded preset", def.ID, file))
+		}
 
-	if desc, exists := descriptions[base]; exists {
-		return desc
+		tm.customDefs[def.ID] = def
 	}
 
-	return fmt.Sprintf("%s project template", base)
+	return nil
+}
+
+// Warnings returns the warnings recorded while loading custom presets
+func (tm *TemplateManager) Warnings() []string {
+	return tm.warnings
 }
 
-func (tm *TemplateManager) getCategoryFromPath(path string) string {
-	// Extract category from path structure
-	// For now, return generic category
-	if strings.Contains(path, "web") {
-		return "Web Applications"
-	} else if strings.Contains(path, "database") {
-		return "Databases"
-	} else if strings.Contains(path, "ml") {
-		return "Machine Learning"
-	} else if strings.Contains(path, "infrastructure") {
-		return "Infrastructure"
+func sortedDefinitions(defs map[string]definition) []definition {
+	sorted := make([]definition, 0, len(defs))
+	for _, def := range defs {
+		sorted = append(sorted, def)
 	}
 
-	return "General"
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	return sorted
 }
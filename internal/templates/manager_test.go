@@ -0,0 +1,154 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+func writePreset(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadCustomTemplateExtendsAcrossPaths(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	writePreset(t, first, "child.yaml", `id: child
+name: Child
+extends: base
+description: Extends a preset loaded after it
+category: Custom
+services:
+  - name: worker
+    type: worker
+    image: worker:1
+`)
+	writePreset(t, second, "base.yaml", `id: base
+name: Base
+description: Base preset
+category: Custom
+services:
+  - name: api
+    type: api
+    image: api:1
+`)
+
+	tm := NewTemplateManager()
+	if err := tm.LoadCustomTemplate(first, second); err != nil {
+		t.Fatalf("LoadCustomTemplate() error = %v", err)
+	}
+
+	preset, err := tm.GetPreset("child")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, service := range preset.Services {
+		names = append(names, service.Name)
+	}
+	if len(names) != 2 || names[0] != "api" || names[1] != "worker" {
+		t.Errorf("child services = %v, want [api worker]", names)
+	}
+}
+
+func TestLoadCustomTemplateUnknownParent(t *testing.T) {
+	dir := t.TempDir()
+	writePreset(t, dir, "orphan.yaml", `id: orphan
+name: Orphan
+extends: missing
+description: Extends nothing
+category: Custom
+services: []
+`)
+
+	if err := NewTemplateManager().LoadCustomTemplate(dir); err == nil {
+		t.Fatal("LoadCustomTemplate() error = nil, want unknown preset error")
+	}
+}
+
+func presetIDs(presets []types.Preset) []string {
+	var ids []string
+	for _, preset := range presets {
+		ids = append(ids, preset.ID)
+	}
+	return ids
+}
+
+func TestEmbeddedPresets(t *testing.T) {
+	tm := NewTemplateManager()
+
+	if got := strings.Join(presetIDs(tm.ListPresets()), " "); got != "database microservice web-app" {
+		t.Errorf("ListPresets() = %s, want database microservice web-app", got)
+	}
+	if got := strings.Join(presetIDs(tm.ListMixins()), " "); got != "prometheus-monitoring redis-cache" {
+		t.Errorf("ListMixins() = %s, want prometheus-monitoring redis-cache", got)
+	}
+
+	preset, err := tm.GetPreset("microservice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preset.Category != "Microservices" || preset.Description == "" || preset.Source != "embedded" {
+		t.Errorf("microservice = %+v, want category, description and embedded source from its YAML", preset)
+	}
+	if preset.Variables["REDIS_PASSWORD"] == "" || len(preset.Tags) == 0 {
+		t.Errorf("microservice variables = %v, tags = %v, want them from its YAML", preset.Variables, preset.Tags)
+	}
+	var service2 *types.PresetService
+	for i := range preset.Services {
+		if preset.Services[i].Name == "service2" {
+			service2 = &preset.Services[i]
+		}
+	}
+	if service2 == nil {
+		t.Fatalf("microservice has no service2")
+	}
+	if len(service2.DependsOn) != 1 || service2.DependsOn[0] != "redis" {
+		t.Errorf("service2 depends_on = %v, want [redis]", service2.DependsOn)
+	}
+}
+
+func TestMalformedPreset(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "missing id",
+			content: "name: No ID\ncategory: Custom\nservices: []\n",
+			want:    "preset id is required",
+		},
+		{
+			name:    "unknown field",
+			content: "id: typo\nname: Typo\ncategory: Custom\nservices:\n  - name: api\n    type: api\n    depend_on: [db]\n",
+			want:    "field depend_on not found",
+		},
+		{
+			name:    "unknown dependency",
+			content: "id: deps\nname: Deps\ncategory: Custom\nservices:\n  - name: api\n    type: api\n    depends_on: [db]\n",
+			want:    "unknown service: db",
+		},
+		{
+			name:    "not YAML",
+			content: "id: [broken\n",
+			want:    "failed to parse preset",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writePreset(t, dir, "preset.yaml", test.content)
+
+			err := NewTemplateManager().LoadCustomTemplate(dir)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("LoadCustomTemplate() error = %v, want it to contain %q", err, test.want)
+			}
+		})
+	}
+}
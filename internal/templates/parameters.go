@@ -0,0 +1,238 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// parameterReference matches "{{ params.<name> }}" inside preset values
+var parameterReference = regexp.MustCompile(`\{\{\s*params\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// parameterName matches valid parameter names
+var parameterName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateParameters checks parameter declarations and their defaults
+func validateParameters(parameters []types.PresetParameter) error {
+	var errors types.ValidationErrors
+
+	seen := make(map[string]bool)
+	for i, param := range parameters {
+		field := fmt.Sprintf("parameters[%d]", i)
+
+		if !parameterName.MatchString(param.Name) {
+			errors.Add(field+".name", "parameter name must start with a letter or underscore and contain only letters, digits and underscores", param.Name)
+			continue
+		}
+		if seen[param.Name] {
+			errors.Add(field+".name", fmt.Sprintf("duplicate parameter: %s", param.Name), param.Name)
+		}
+		seen[param.Name] = true
+
+		switch param.Type {
+		case types.ParameterTypeString, types.ParameterTypeInt, types.ParameterTypeBool:
+		case types.ParameterTypeEnum:
+			if len(param.Values) == 0 {
+				errors.Add(field+".values", "enum parameters must list their allowed values", param.Values)
+			}
+		default:
+			errors.Add(field+".type", "parameter type must be one of string, int, bool, enum", param.Type)
+			continue
+		}
+
+		if param.Pattern != "" {
+			if _, err := regexp.Compile(param.Pattern); err != nil {
+				errors.Add(field+".pattern", fmt.Sprintf("invalid pattern: %v", err), param.Pattern)
+				continue
+			}
+		}
+
+		if !param.Required {
+			if _, err := parameterValue(param, param.Default); err != nil {
+				errors.Add(field+".default", fmt.Sprintf("invalid default (set a valid default or mark the parameter required): %v", err), param.Default)
+			}
+		}
+	}
+
+	if errors.HasErrors() {
+		return errors
+	}
+
+	return nil
+}
+
+// resolveParameters combines provided values with defaults. Every missing or
+// invalid parameter is reported in the returned ValidationErrors. Provided
+// values for undeclared parameters are ignored here, since they may belong to
+// a mixin applied alongside.
+func resolveParameters(parameters []types.PresetParameter, provided map[string]string) (map[string]string, error) {
+	var errors types.ValidationErrors
+	values := make(map[string]string)
+
+	for _, param := range parameters {
+		field := "params." + param.Name
+
+		raw, ok := provided[param.Name]
+		if !ok {
+			if param.Required {
+				errors.Add(field, fmt.Sprintf("required %s parameter is missing", param.Type), nil)
+				continue
+			}
+			raw = param.Default
+		}
+
+		value, err := parameterValue(param, raw)
+		if err != nil {
+			errors.Add(field, err.Error(), raw)
+			continue
+		}
+		values[param.Name] = value
+	}
+
+	if errors.HasErrors() {
+		return nil, errors
+	}
+
+	return values, nil
+}
+
+// placeholderParameters returns values used to check a preset at load time,
+// before any user input is known
+func placeholderParameters(parameters []types.PresetParameter) map[string]string {
+	values := make(map[string]string)
+
+	for _, param := range parameters {
+		value := param.Default
+		if param.Required && value == "" {
+			switch param.Type {
+			case types.ParameterTypeInt:
+				value = "0"
+				if param.Min != nil {
+					value = strconv.Itoa(*param.Min)
+				}
+			case types.ParameterTypeBool:
+				value = "false"
+			case types.ParameterTypeEnum:
+				value = param.Values[0]
+			default:
+				value = param.Name
+			}
+		}
+		values[param.Name] = value
+	}
+
+	return values
+}
+
+// parameterValue checks a raw value against the parameter type and
+// constraints and returns its normalized form
+func parameterValue(param types.PresetParameter, raw string) (string, error) {
+	switch param.Type {
+	case types.ParameterTypeInt:
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return "", fmt.Errorf("expected an integer, got %q", raw)
+		}
+		if param.Min != nil && n < *param.Min {
+			return "", fmt.Errorf("must be at least %d", *param.Min)
+		}
+		if param.Max != nil && n > *param.Max {
+			return "", fmt.Errorf("must be at most %d", *param.Max)
+		}
+		return strconv.Itoa(n), nil
+	case types.ParameterTypeBool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return "", fmt.Errorf("expected true or false, got %q", raw)
+		}
+		return strconv.FormatBool(b), nil
+	case types.ParameterTypeEnum:
+		for _, allowed := range param.Values {
+			if raw == allowed {
+				return raw, nil
+			}
+		}
+		return "", fmt.Errorf("must be one of %s, got %q", strings.Join(param.Values, ", "), raw)
+	default:
+		if param.Pattern != "" && !regexp.MustCompile(param.Pattern).MatchString(raw) {
+			return "", fmt.Errorf("must match pattern %s", param.Pattern)
+		}
+		return raw, nil
+	}
+}
+
+// renderParameters substitutes parameter references in every preset value
+// except the parameters block itself. A scalar consisting of a single int or
+// bool reference takes that type, so ports can be parameterized.
+func renderParameters(data []byte, parameters []types.PresetParameter, values map[string]string) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return data, nil
+	}
+
+	paramTypes := make(map[string]types.ParameterType)
+	for _, param := range parameters {
+		paramTypes[param.Name] = param.Type
+	}
+
+	var unknown []string
+	document := root.Content[0]
+	for i := 0; i+1 < len(document.Content); i += 2 {
+		if document.Content[i].Value == "parameters" {
+			continue
+		}
+		substituteParameters(document.Content[i+1], paramTypes, values, &unknown)
+	}
+
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("reference to undeclared parameter(s): %s", strings.Join(unknown, ", "))
+	}
+
+	return yaml.Marshal(&root)
+}
+
+func substituteParameters(node *yaml.Node, paramTypes map[string]types.ParameterType, values map[string]string, unknown *[]string) {
+	switch node.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			substituteParameters(child, paramTypes, values, unknown)
+		}
+	case yaml.ScalarNode:
+		if !parameterReference.MatchString(node.Value) {
+			return
+		}
+
+		whole := parameterReference.FindStringSubmatch(node.Value)
+		exact := whole[0] == strings.TrimSpace(node.Value)
+
+		node.Value = parameterReference.ReplaceAllStringFunc(node.Value, func(ref string) string {
+			name := parameterReference.FindStringSubmatch(ref)[1]
+			value, ok := values[name]
+			if !ok {
+				*unknown = append(*unknown, name)
+			}
+			return value
+		})
+
+		node.Tag = "!!str"
+		node.Style = yaml.DoubleQuotedStyle
+		if exact {
+			switch paramTypes[whole[1]] {
+			case types.ParameterTypeInt:
+				node.Tag = "!!int"
+				node.Style = 0
+			case types.ParameterTypeBool:
+				node.Tag = "!!bool"
+				node.Style = 0
+			}
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package templates
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// hasError reports whether errs holds an error on field whose message starts with message
+func hasError(errs types.ValidationErrors, field, message string) bool {
+	for _, err := range errs {
+		if err.Field == field && strings.HasPrefix(err.Message, message) {
+			return true
+		}
+	}
+	return false
+}
+
+var testParameters = []types.PresetParameter{
+	{Name: "port", Type: types.ParameterTypeInt, Default: "8080", Min: intPtr(1), Max: intPtr(65535)},
+	{Name: "debug", Type: types.ParameterTypeBool, Default: "false"},
+	{Name: "version", Type: types.ParameterTypeEnum, Values: []string{"18", "20"}, Default: "18"},
+	{Name: "domain", Type: types.ParameterTypeString, Required: true, Pattern: `^[a-z.]+$`},
+}
+
+func TestResolveParameters(t *testing.T) {
+	tests := []struct {
+		name     string
+		provided map[string]string
+		want     map[string]string
+		errors   [][2]string
+	}{
+		{
+			name:     "defaults",
+			provided: map[string]string{"domain": "example.com"},
+			want:     map[string]string{"port": "8080", "debug": "false", "version": "18", "domain": "example.com"},
+		},
+		{
+			name:     "normalized values",
+			provided: map[string]string{"port": " 0443 ", "debug": "1", "version": "20", "domain": "a.b"},
+			want:     map[string]string{"port": "443", "debug": "true", "version": "20", "domain": "a.b"},
+		},
+		{
+			name:     "undeclared values are left to mixins",
+			provided: map[string]string{"domain": "a.b", "redis_port": "6379"},
+			want:     map[string]string{"port": "8080", "debug": "false", "version": "18", "domain": "a.b"},
+		},
+		{
+			name:     "every problem reported",
+			provided: map[string]string{"port": "70000", "debug": "maybe", "version": "16"},
+			errors: [][2]string{
+				{"params.port", "must be at most 65535"},
+				{"params.debug", "expected true or false"},
+				{"params.version", "must be one of 18, 20"},
+				{"params.domain", "required string parameter is missing"},
+			},
+		},
+		{
+			name:     "not an integer",
+			provided: map[string]string{"port": "http", "domain": "a.b"},
+			errors:   [][2]string{{"params.port", `expected an integer, got "http"`}},
+		},
+		{
+			name:     "pattern mismatch",
+			provided: map[string]string{"domain": "Example.com"},
+			errors:   [][2]string{{"params.domain", "must match pattern ^[a-z.]+$"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := resolveParameters(testParameters, test.provided)
+			if len(test.errors) == 0 {
+				if err != nil {
+					t.Fatalf("resolveParameters() error = %v", err)
+				}
+				for name, want := range test.want {
+					if got[name] != want {
+						t.Errorf("%s = %q, want %q", name, got[name], want)
+					}
+				}
+				if len(got) != len(test.want) {
+					t.Errorf("resolveParameters() = %v, want %v", got, test.want)
+				}
+				return
+			}
+
+			var validationErrors types.ValidationErrors
+			if !errors.As(err, &validationErrors) {
+				t.Fatalf("resolveParameters() error = %v, want ValidationErrors", err)
+			}
+			if len(validationErrors) != len(test.errors) {
+				t.Errorf("resolveParameters() reported %d errors, want %d: %v", len(validationErrors), len(test.errors), err)
+			}
+			for _, want := range test.errors {
+				if !hasError(validationErrors, want[0], want[1]) {
+					t.Errorf("resolveParameters() error = %v, want %s: %s", err, want[0], want[1])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateParameters(t *testing.T) {
+	tests := []struct {
+		name      string
+		parameter types.PresetParameter
+		want      string
+	}{
+		{
+			name:      "bad name",
+			parameter: types.PresetParameter{Name: "api-port", Type: types.ParameterTypeInt, Default: "1"},
+			want:      "parameter name must start with a letter",
+		},
+		{
+			name:      "unknown type",
+			parameter: types.PresetParameter{Name: "size", Type: "float", Default: "1"},
+			want:      "parameter type must be one of",
+		},
+		{
+			name:      "enum without values",
+			parameter: types.PresetParameter{Name: "tier", Type: types.ParameterTypeEnum},
+			want:      "enum parameters must list their allowed values",
+		},
+		{
+			name:      "invalid pattern",
+			parameter: types.PresetParameter{Name: "host", Type: types.ParameterTypeString, Pattern: "["},
+			want:      "invalid pattern",
+		},
+		{
+			name:      "invalid default",
+			parameter: types.PresetParameter{Name: "port", Type: types.ParameterTypeInt, Default: "0", Min: intPtr(1)},
+			want:      "invalid default",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateParameters([]types.PresetParameter{test.parameter})
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("validateParameters() error = %v, want it to contain %q", err, test.want)
+			}
+		})
+	}
+
+	if err := validateParameters(testParameters); err != nil {
+		t.Errorf("validateParameters() error = %v for valid parameters", err)
+	}
+}
+
+func TestComposeRendersParameters(t *testing.T) {
+	preset, err := NewTemplateManager().Compose("web-app", nil, map[string]string{"api_port": "9090", "node_version": "22"})
+	if err != nil {
+		t.Fatalf("Compose() error = %v", err)
+	}
+
+	for _, service := range preset.Services {
+		if service.Name != "api" {
+			continue
+		}
+		if service.Image != "node:22-alpine" {
+			t.Errorf("api image = %s, want node:22-alpine", service.Image)
+		}
+		if len(service.Ports) == 0 || service.Ports[0].Container != 9090 {
+			t.Errorf("api ports = %+v, want container port 9090", service.Ports)
+		}
+		return
+	}
+	t.Fatal("web-app has no api service")
+}
+
+func TestComposeRejectsParameters(t *testing.T) {
+	_, err := NewTemplateManager().Compose("web-app", nil, map[string]string{"api_port": "0", "colour": "blue"})
+
+	var validationErrors types.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		t.Fatalf("Compose() error = %v, want ValidationErrors", err)
+	}
+	if !hasError(validationErrors, "params.api_port", "must be at least 1") {
+		t.Errorf("Compose() error = %v, want api_port out of range", err)
+	}
+	if !hasError(validationErrors, "params.colour", "unknown parameter") {
+		t.Errorf("Compose() error = %v, want colour unknown", err)
+	}
+}
@@ -0,0 +1,80 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/graph"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/health"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/interpolation"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/ports"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/scaling"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/secrets"
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/sizing"
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+// Project reports the problems of a project that every target shares:
+// missing names and types, fields out of range, unresolved ${VAR}
+// references and the Rules. Generators add their own checks on top.
+func Project(config *types.ProjectConfig, resolver *interpolation.Resolver) types.ValidationErrors {
+	var errors types.ValidationErrors
+
+	if config.Name == "" {
+		errors.Add("name", "project name is required", config.Name)
+	}
+
+	if len(config.Services) == 0 {
+		errors.Add("services", "at least one service is required", len(config.Services))
+	}
+
+	// Validate each service
+	for i, service := range config.Services {
+		if service.Name == "" {
+			errors.Add(fmt.Sprintf("services[%d].name", i), "service name is required", service.Name)
+		}
+		if service.Type == "" {
+			errors.Add(fmt.Sprintf("services[%d].type", i), "service type is required", service.Type)
+		}
+	}
+
+	// Check ${VAR} references
+	errors = append(errors, resolver.Validate(config)...)
+
+	// Check port numbers, protocols and exposure levels
+	errors = append(errors, ports.Validate(config)...)
+
+	// Check resource profiles
+	errors = append(errors, sizing.Validate(config)...)
+
+	// Check replica counts and autoscaling ranges
+	errors = append(errors, scaling.Validate(config)...)
+
+	return append(errors, Rules(config)...)
+}
+
+// Rules reports the problems of a project that its schema cannot express,
+// as they span several fields or services
+func Rules(config *types.ProjectConfig) types.ValidationErrors {
+	var errors types.ValidationErrors
+
+	// Check service dependencies
+	errors = append(errors, graph.New(config).Validate()...)
+
+	// Check port bindings and host port conflicts
+	errors = append(errors, ports.Bindings(config)...)
+	errors = append(errors, ports.Conflicts(config)...)
+
+	// Check healthchecks
+	errors = append(errors, health.Validate(config)...)
+
+	// Check resource limits
+	errors = append(errors, sizing.Limits(config)...)
+
+	// Check replicas and autoscaling
+	errors = append(errors, scaling.Constraints(config)...)
+
+	// Check secrets
+	errors = append(errors, secrets.Validate(config)...)
+
+	return errors
+}
@@ -0,0 +1,75 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/kishininfosec/infra-gen/infra-gen/internal/interpolation"
+	"github.com/kishininfosec/infra-gen/infra-gen/pkg/types"
+)
+
+func TestProject(t *testing.T) {
+	tests := []struct {
+		name    string
+		service types.ServiceConfig
+		// field is reported by Project; rule tells whether Rules reports it too
+		field string
+		rule  bool
+	}{
+		{
+			name:    "missing type",
+			service: types.ServiceConfig{Name: "api", Enabled: true},
+			field:   "services[0].type",
+		},
+		{
+			name:    "unresolved reference",
+			service: types.ServiceConfig{Name: "api", Type: "api", Image: "${MISSING}", Enabled: true},
+			field:   "services[0].image",
+		},
+		{
+			name:    "port out of range",
+			service: types.ServiceConfig{Name: "api", Type: "api", Ports: []types.PortConfig{{Container: 70000}}, Enabled: true},
+			field:   "services[0].ports[0].container",
+		},
+		{
+			name:    "unknown dependency",
+			service: types.ServiceConfig{Name: "api", Type: "api", DependsOn: []string{"db"}, Enabled: true},
+			field:   "services[0].depends_on[0]",
+			rule:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &types.ProjectConfig{Name: "app", Services: []types.ServiceConfig{tt.service}}
+
+			errors := Project(config, interpolation.NewResolver(nil, nil))
+			if !reports(errors, tt.field) {
+				t.Errorf("Project() = %v, want an error on %s", errors, tt.field)
+			}
+			if got := reports(Rules(config), tt.field); got != tt.rule {
+				t.Errorf("Rules() reports %s = %v, want %v", tt.field, got, tt.rule)
+			}
+		})
+	}
+}
+
+func TestProjectValid(t *testing.T) {
+	config := &types.ProjectConfig{
+		Name: "app",
+		Services: []types.ServiceConfig{
+			{Name: "api", Type: "api", Image: "node:18", Ports: []types.PortConfig{{Container: 8080}}, Enabled: true},
+		},
+	}
+	if errors := Project(config, interpolation.NewResolver(nil, nil)); errors.HasErrors() {
+		t.Errorf("Project() = %v, want no errors", errors)
+	}
+}
+
+func reports(errors types.ValidationErrors, field string) bool {
+	for _, err := range errors {
+		if err.Field == field {
+			return true
+		}
+	}
+	return false
+}
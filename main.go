@@ -3,7 +3,7 @@ Copyright © 2026 NAME HERE <EMAIL ADDRESS>
 */
 package main
 
-import "github.com/kishininfosec/infra-gen/cmd"
+import "github.com/kishininfosec/infra-gen/infra-gen/cmd"
 
 func main() {
 	cmd.Execute()
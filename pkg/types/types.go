@@ -6,10 +6,10 @@ import "time"
 type ProjectType string
 
 const (
-	ProjectTypeWebApp       ProjectType = "web-app"
-	ProjectTypeMicroservice ProjectType = "microservice"
-	ProjectTypeDatabase     ProjectType = "database"
-	ProjectTypeML           ProjectType = "ml"
+	ProjectTypeWebApp         ProjectType = "web-app"
+	ProjectTypeMicroservice   ProjectType = "microservice"
+	ProjectTypeDatabase       ProjectType = "database"
+	ProjectTypeML             ProjectType = "ml"
 	ProjectTypeInfrastructure ProjectType = "infrastructure"
 )
 
@@ -17,49 +17,140 @@ const (
 type Target string
 
 const (
-	TargetDocker    Target = "docker"
-	TargetAnsible   Target = "ansible"
-	TargetTerraform Target = "terraform"
+	TargetDocker     Target = "docker"
+	TargetAnsible    Target = "ansible"
+	TargetTerraform  Target = "terraform"
+	TargetKubernetes Target = "kubernetes"
+	TargetHelm       Target = "helm"
 )
 
 // ProjectConfig holds the configuration for a project
 type ProjectConfig struct {
-	Name        string            `yaml:"name"`
-	Type        ProjectType       `yaml:"type"`
-	Description string            `yaml:"description,omitempty"`
-	Version     string            `yaml:"version,omitempty"`
-	Environment string            `yaml:"environment,omitempty"`
-	Services    []ServiceConfig   `yaml:"services"`
-	Variables   map[string]string `yaml:"variables,omitempty"`
-	CreatedAt   time.Time         `yaml:"created_at"`
-	UpdatedAt   time.Time         `yaml:"updated_at"`
+	SchemaVersion int               `yaml:"schema_version,omitempty" schema:"minimum=1"`
+	Name          string            `yaml:"name" schema:"required"`
+	Type          ProjectType       `yaml:"type" schema:"required"`
+	Description   string            `yaml:"description,omitempty"`
+	Version       string            `yaml:"version,omitempty"`
+	Environment   string            `yaml:"environment,omitempty"`
+	Services      []ServiceConfig   `yaml:"services" schema:"required"`
+	Variables     map[string]string `yaml:"variables,omitempty"`
+	// Secrets are sensitive values services use by reference, keyed by name
+	Secrets map[string]SecretConfig `yaml:"secrets,omitempty"`
+	// Environments holds per-environment overlays merged by generate --env
+	Environments map[string]map[string]interface{} `yaml:"environments,omitempty"`
+	// Sources records where each field was defined when loaded from a file
+	Sources   SourceMap `yaml:"-"`
+	CreatedAt time.Time `yaml:"created_at"`
+	UpdatedAt time.Time `yaml:"updated_at"`
 }
 
 // ServiceConfig represents a single service in the project
 type ServiceConfig struct {
-	Name        string            `yaml:"name"`
-	Type        string            `yaml:"type"`
-	Image       string            `yaml:"image,omitempty"`
-	Ports       []PortConfig      `yaml:"ports,omitempty"`
-	Volumes     []VolumeConfig    `yaml:"volumes,omitempty"`
-	Environment map[string]string `yaml:"environment,omitempty"`
-	DependsOn   []string          `yaml:"depends_on,omitempty"`
-	Enabled     bool              `yaml:"enabled"`
+	Name        string             `yaml:"name" schema:"required"`
+	Type        string             `yaml:"type" schema:"required"`
+	Image       string             `yaml:"image,omitempty"`
+	Ports       []PortConfig       `yaml:"ports,omitempty"`
+	Volumes     []VolumeConfig     `yaml:"volumes,omitempty"`
+	Environment map[string]string  `yaml:"environment,omitempty"`
+	DependsOn   []string           `yaml:"depends_on,omitempty"`
+	Healthcheck *HealthcheckConfig `yaml:"healthcheck,omitempty"`
+	Resources   *ResourcesConfig   `yaml:"resources,omitempty"`
+	Replicas    int                `yaml:"replicas,omitempty" schema:"minimum=1"`
+	Autoscaling *AutoscalingConfig `yaml:"autoscaling,omitempty"`
+	Secrets     []SecretRef        `yaml:"secrets,omitempty"`
+	Enabled     bool               `yaml:"enabled"`
 }
 
-// PortConfig represents a port mapping
+// PortConfig represents a port mapping. ContainerEnd and HostEnd turn it into
+// a range of ports; Exposure defaults to public.
 type PortConfig struct {
-	Host      int    `yaml:"host,omitempty"`
-	Container int    `yaml:"container"`
-	Protocol  string `yaml:"protocol,omitempty"`
+	Host         int    `yaml:"host,omitempty" schema:"minimum=1,maximum=65535"`
+	HostEnd      int    `yaml:"host_end,omitempty" schema:"minimum=1,maximum=65535"`
+	HostIP       string `yaml:"host_ip,omitempty"`
+	Container    int    `yaml:"container" schema:"required,minimum=1,maximum=65535"`
+	ContainerEnd int    `yaml:"container_end,omitempty" schema:"minimum=1,maximum=65535"`
+	Protocol     string `yaml:"protocol,omitempty" schema:"enum=tcp|udp"`
+	Exposure     string `yaml:"exposure,omitempty" schema:"enum=public|internal|none"`
+}
+
+// HealthcheckConfig tells when a service is ready, with exactly one of a
+// command run in the container, an HTTP probe or a TCP probe. Durations use
+// Go syntax such as 10s or 1m30s.
+type HealthcheckConfig struct {
+	Command     string     `yaml:"command,omitempty"`
+	HTTP        *HTTPProbe `yaml:"http,omitempty"`
+	TCP         *TCPProbe  `yaml:"tcp,omitempty"`
+	Interval    string     `yaml:"interval,omitempty"`
+	Timeout     string     `yaml:"timeout,omitempty"`
+	Retries     int        `yaml:"retries,omitempty" schema:"minimum=1"`
+	StartPeriod string     `yaml:"start_period,omitempty"`
+}
+
+// HTTPProbe checks that a container port answers an HTTP GET with a 2xx or
+// 3xx status
+type HTTPProbe struct {
+	Path string `yaml:"path,omitempty"`
+	Port int    `yaml:"port" schema:"required,minimum=1,maximum=65535"`
+}
+
+// TCPProbe checks that a container port accepts connections
+type TCPProbe struct {
+	Port int `yaml:"port" schema:"required,minimum=1,maximum=65535"`
+}
+
+// ResourcesConfig sizes a service by a named profile, explicit limits, or a
+// profile with some limits overridden. CPU is in cores such as 0.5 or 2,
+// memory and disk take a unit such as 512M or 20G.
+type ResourcesConfig struct {
+	Profile string `yaml:"profile,omitempty" schema:"enum=small|medium|large|xlarge"`
+	CPU     string `yaml:"cpu,omitempty"`
+	Memory  string `yaml:"memory,omitempty"`
+	Disk    string `yaml:"disk,omitempty"`
+}
+
+// AutoscalingConfig scales a service between Min and Max copies to keep its
+// average CPU or memory utilization near a target percentage
+type AutoscalingConfig struct {
+	Min          int `yaml:"min" schema:"required,minimum=1"`
+	Max          int `yaml:"max" schema:"required,minimum=1"`
+	TargetCPU    int `yaml:"target_cpu,omitempty" schema:"minimum=1,maximum=100"`
+	TargetMemory int `yaml:"target_memory,omitempty" schema:"minimum=1,maximum=100"`
+}
+
+// SecretConfig is a sensitive value that generated files only reference. It
+// names exactly one source: a generated value, a file, an environment
+// variable of the machine running the tools, or a reference to an external
+// secret store such as an AWS Secrets Manager secret.
+type SecretConfig struct {
+	Description string          `yaml:"description,omitempty"`
+	Generate    *GenerateConfig `yaml:"generate,omitempty"`
+	File        string          `yaml:"file,omitempty"`
+	Env         string          `yaml:"env,omitempty"`
+	External    string          `yaml:"external,omitempty"`
+}
+
+// GenerateConfig describes a random secret value infra-gen generates. Both
+// fields have defaults: 32 characters of letters and digits.
+type GenerateConfig struct {
+	Length   int    `yaml:"length,omitempty" schema:"minimum=1"`
+	Alphabet string `yaml:"alphabet,omitempty"`
+}
+
+// SecretRef gives a service a project secret, optionally as the value of an
+// environment variable
+type SecretRef struct {
+	Name string `yaml:"name" schema:"required"`
+	Env  string `yaml:"env,omitempty"`
 }
 
 // VolumeConfig represents a volume mapping
 type VolumeConfig struct {
-	Source   string `yaml:"source"`
-	Target   string `yaml:"target"`
+	Source   string `yaml:"source" schema:"required"`
+	Target   string `yaml:"target" schema:"required"`
 	ReadOnly bool   `yaml:"read_only,omitempty"`
-	Type     string `yaml:"type,omitempty"`
+	Type     string `yaml:"type,omitempty" schema:"enum=volume|bind"`
+	// Size is the storage a named volume requests, such as 10G
+	Size string `yaml:"size,omitempty"`
 }
 
 // Generator interface for different infrastructure generators
@@ -75,27 +166,72 @@ type GeneratedFile struct {
 	Content  string `yaml:"content"`
 	Type     Target `yaml:"type"`
 	Encoding string `yaml:"encoding,omitempty"`
+	// Sensitive files hold secret values; they are written readable by
+	// their owner only and must not be committed
+	Sensitive bool `yaml:"sensitive,omitempty"`
 }
 
+// PresetKind distinguishes full project presets from add-on mixins
+type PresetKind string
+
+const (
+	PresetKindPreset PresetKind = "preset"
+	PresetKindMixin  PresetKind = "mixin"
+)
+
 // Preset represents a project preset
 type Preset struct {
-	ID          string              `yaml:"id"`
-	Name        string              `yaml:"name"`
-	Description string              `yaml:"description"`
-	Category    string              `yaml:"category"`
-	Services    []PresetService     `yaml:"services"`
-	Variables   map[string]string   `yaml:"variables,omitempty"`
-	Tags        []string            `yaml:"tags,omitempty"`
+	ID          string            `yaml:"id" schema:"required"`
+	Name        string            `yaml:"name"`
+	Type        ProjectType       `yaml:"type,omitempty"`
+	Kind        PresetKind        `yaml:"kind,omitempty"`
+	Extends     string            `yaml:"extends,omitempty"`
+	Description string            `yaml:"description"`
+	Category    string            `yaml:"category"`
+	Parameters  []PresetParameter `yaml:"parameters,omitempty"`
+	Services    []PresetService   `yaml:"services"`
+	Variables   map[string]string `yaml:"variables,omitempty"`
+	Tags        []string          `yaml:"tags,omitempty"`
+	Source      string            `yaml:"-"`
 }
 
 // PresetService represents a service in a preset
 type PresetService struct {
-	Name        string            `yaml:"name"`
-	Type        string            `yaml:"type"`
-	Description string            `yaml:"description"`
-	Image       string            `yaml:"image,omitempty"`
-	Ports       []PortConfig      `yaml:"ports,omitempty"`
-	Volumes     []VolumeConfig    `yaml:"volumes,omitempty"`
-	Environment map[string]string `yaml:"environment,omitempty"`
-	Optional    bool              `yaml:"optional,omitempty"`
-}
\ No newline at end of file
+	Name        string             `yaml:"name" schema:"required"`
+	Type        string             `yaml:"type"`
+	Description string             `yaml:"description"`
+	Image       string             `yaml:"image,omitempty"`
+	Ports       []PortConfig       `yaml:"ports,omitempty"`
+	Volumes     []VolumeConfig     `yaml:"volumes,omitempty"`
+	Environment map[string]string  `yaml:"environment,omitempty"`
+	DependsOn   []string           `yaml:"depends_on,omitempty"`
+	Healthcheck *HealthcheckConfig `yaml:"healthcheck,omitempty"`
+	Resources   *ResourcesConfig   `yaml:"resources,omitempty"`
+	Replicas    int                `yaml:"replicas,omitempty" schema:"minimum=1"`
+	Autoscaling *AutoscalingConfig `yaml:"autoscaling,omitempty"`
+	Optional    bool               `yaml:"optional,omitempty"`
+}
+
+// ParameterType represents the type of a preset parameter
+type ParameterType string
+
+const (
+	ParameterTypeString ParameterType = "string"
+	ParameterTypeInt    ParameterType = "int"
+	ParameterTypeBool   ParameterType = "bool"
+	ParameterTypeEnum   ParameterType = "enum"
+)
+
+// PresetParameter represents a typed input of a preset. Parameters are
+// referenced from preset values as "{{ params.<name> }}".
+type PresetParameter struct {
+	Name        string        `yaml:"name" schema:"required"`
+	Type        ParameterType `yaml:"type" schema:"required"`
+	Description string        `yaml:"description,omitempty"`
+	Default     string        `yaml:"default,omitempty"`
+	Required    bool          `yaml:"required,omitempty"`
+	Values      []string      `yaml:"values,omitempty"`
+	Pattern     string        `yaml:"pattern,omitempty"`
+	Min         *int          `yaml:"min,omitempty"`
+	Max         *int          `yaml:"max,omitempty"`
+}
@@ -2,17 +2,71 @@ package types
 
 import (
 	"fmt"
+	"strings"
 )
 
 // ValidationError represents a validation error
 type ValidationError struct {
-	Field   string
-	Message string
-	Value   interface{}
+	Field    string
+	Message  string
+	Value    interface{}
+	Position Position
 }
 
 func (e ValidationError) Error() string {
-	return fmt.Sprintf("validation error on field '%s': %s", e.Field, e.Message)
+	msg := e.Message
+	if e.Field != "" {
+		msg = fmt.Sprintf("validation error on field '%s': %s", e.Field, e.Message)
+	}
+	if e.Position.IsValid() {
+		return fmt.Sprintf("%s: %s", e.Position, msg)
+	}
+	return msg
+}
+
+// Position is a location in a config file
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position points into a file
+func (p Position) IsValid() bool {
+	return p.File != "" || p.Line > 0
+}
+
+// String formats the position as file:line:col, leaving out unknown parts
+func (p Position) String() string {
+	switch {
+	case p.Line == 0:
+		return p.File
+	case p.Column == 0:
+		return fmt.Sprintf("%s:%d", p.File, p.Line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+}
+
+// SourceMap maps field paths such as services[0].image to where they are
+// defined in a config file
+type SourceMap map[string]Position
+
+// Lookup returns the position of a field, or of its nearest parent when the
+// field itself is not in the file
+func (sm SourceMap) Lookup(field string) (Position, bool) {
+	for field != "" {
+		if pos, exists := sm[field]; exists {
+			return pos, true
+		}
+		cut := strings.LastIndexAny(field, ".[")
+		if cut < 0 {
+			break
+		}
+		field = field[:cut]
+	}
+	pos, exists := sm[""]
+	return pos, exists
 }
 
 // ValidationErrors represents multiple validation errors
@@ -39,6 +93,18 @@ func (ve *ValidationErrors) Add(field, message string, value interface{}) {
 	})
 }
 
+// Locate fills in the position of every error that does not have one yet
+func (ve ValidationErrors) Locate(sources SourceMap) ValidationErrors {
+	located := make(ValidationErrors, len(ve))
+	for i, err := range ve {
+		if !err.Position.IsValid() {
+			err.Position, _ = sources.Lookup(err.Field)
+		}
+		located[i] = err
+	}
+	return located
+}
+
 // HasErrors returns true if there are validation errors
 func (ve ValidationErrors) HasErrors() bool {
 	return len(ve) > 0